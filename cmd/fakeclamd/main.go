@@ -0,0 +1,39 @@
+// Command fakeclamd runs a minimal clamd for local development, so uploads
+// can be scanned without installing ClamAV. It reports the EICAR test file
+// as infected and everything else as clean.
+//
+//	go run ./cmd/fakeclamd -listen 127.0.0.1:3310
+//	CLAMD_ADDRESS=tcp://127.0.0.1:3310 go run main.go
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"vasvault/pkg/scanner"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:3310", "tcp address or unix:///path to listen on")
+	flag.Parse()
+
+	network, address := "tcp", *listen
+	if strings.HasPrefix(address, "unix://") {
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+		os.Remove(address)
+	}
+
+	server, err := scanner.ListenFakeClamd(network, address)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	log.Printf("fake clamd listening on %s", server.Addr())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	server.Close()
+}
@@ -0,0 +1,57 @@
+// Command reconcile compares the blobs in ./uploads and ./quarantine with the
+// files table and prints a JSON report of blobs without a row, rows without a
+// blob and thumbnails without a blob. With -fix they are deleted.
+//
+//	go run ./cmd/reconcile
+//	go run ./cmd/reconcile -fix -min-age 24h
+//
+// Run it from the app's working directory, with the same DATABASE_URL.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"vasvault/internal/repositories"
+	"vasvault/internal/services"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "delete orphan blobs, orphan thumbnails and rows with a missing blob")
+	minAge := flag.Duration("min-age", services.DefaultReconcileMinAge, "ignore blobs and thumbnails modified more recently than this")
+	uploads := flag.String("uploads", "./uploads", "upload folder")
+	quarantine := flag.String("quarantine", "./quarantine", "quarantine folder")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, "No .env file found, using system environment")
+	}
+	db, err := repositories.Connect()
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	service, err := services.NewReconcileService(repositories.NewFileRepository(db.DB), nil, *uploads, *quarantine)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := service.Reconcile(context.Background(), services.ReconcileOptions{Fix: *fix, MinAge: *minAge})
+	if err != nil {
+		log.Fatalf("reconcile failed: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+}
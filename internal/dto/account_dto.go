@@ -0,0 +1,65 @@
+package dto
+
+import "time"
+
+type DeleteAccountRequest struct {
+	Password        string `json:"password" binding:"required"`
+	WorkspacePolicy string `json:"workspace_policy" binding:"required,oneof=transfer delete"`
+}
+
+type DataExportResponse struct {
+	ID          uint       `json:"id"`
+	Status      string     `json:"status"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+// Export file contents
+
+type ExportProfile struct {
+	ID         uint       `json:"id"`
+	Username   string     `json:"username"`
+	Email      string     `json:"email"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ExportFile struct {
+	ID          uint      `json:"id"`
+	FileName    string    `json:"file_name"`
+	MimeType    string    `json:"mime_type"`
+	Size        int64     `json:"size"`
+	WorkspaceId *uint     `json:"workspace_id,omitempty"`
+	CategoryIDs []uint    `json:"category_ids,omitempty"`
+	ArchivePath string    `json:"archive_path,omitempty"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+type ExportMembership struct {
+	WorkspaceID   uint      `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	Role          string    `json:"role"`
+	JoinedAt      time.Time `json:"joined_at"`
+}
+
+type ExportShare struct {
+	FileID           uint       `json:"file_id"`
+	SharedByUserID   uint       `json:"shared_by_user_id"`
+	SharedWithUserID uint       `json:"shared_with_user_id"`
+	Permission       string     `json:"permission"`
+	SharedAt         time.Time  `json:"shared_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+}
+
+type ExportPublicLink struct {
+	FileID      uint       `json:"file_id"`
+	Permission  string     `json:"permission"`
+	AccessCount int        `json:"access_count"`
+	IsActive    bool       `json:"is_active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+type CreateArchiveRequest struct {
+	FileIDs []uint `json:"file_ids" binding:"required,min=1,max=1000"`
+	Async   bool   `json:"async"` // bangun zip di background, cocok untuk arsip besar
+}
+
+type ArchiveResponse struct {
+	ID          uint       `json:"id"`
+	Status      string     `json:"status"`
+	FileCount   int        `json:"file_count"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+type CreateCategoryRuleRequest struct {
+	MimeType    string `json:"mime_type"`    // "application/pdf" atau "image/*"
+	NamePattern string `json:"name_pattern"` // glob ("invoice-*.pdf") atau regex
+	PatternType string `json:"pattern_type"` // glob (default) | regex
+	MinSize     *int64 `json:"min_size"`
+	MaxSize     *int64 `json:"max_size"`
+	UploaderID  *uint  `json:"uploader_id"` // hanya untuk kategori workspace
+}
+
+type ApplyCategoryRulesRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+type RuleMatchFile struct {
+	ID              uint   `json:"id"`
+	FileName        string `json:"file_name"`
+	AlreadyAssigned bool   `json:"already_assigned"`
+}
+
+type ApplyCategoryRulesResponse struct {
+	DryRun   bool            `json:"dry_run"`
+	Matched  int             `json:"matched"`
+	Assigned int             `json:"assigned"`
+	Files    []RuleMatchFile `json:"files,omitempty"`
+}
+
+type CategoryStatResponse struct {
+	CategoryID uint   `json:"category_id"`
+	Name       string `json:"name"`
+	Color      string `json:"color"`
+	ParentID   *uint  `json:"parent_id,omitempty"`
+	FileCount  int64  `json:"file_count"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+type MergeCategoryRequest struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventPayload adalah body yang dikirim ke webhook dan event stream
+type EventPayload struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	CreatedAt   time.Time   `json:"created_at"`
+	WorkspaceID *uint       `json:"workspace_id,omitempty"`
+	ActorID     uint        `json:"actor_id,omitempty"`
+	Data        interface{} `json:"data"`
+}
+
+type EventFile struct {
+	ID          uint   `json:"id"`
+	FileName    string `json:"file_name"`
+	MimeType    string `json:"mime_type"`
+	Size        int64  `json:"size"`
+	UserID      uint   `json:"user_id"`
+	WorkspaceID *uint  `json:"workspace_id,omitempty"`
+}
+
+// FileEventData: data event file.*
+type FileEventData struct {
+	File            EventFile `json:"file"`
+	OldName         string    `json:"old_name,omitempty"`          // file.renamed
+	FromWorkspaceID *uint     `json:"from_workspace_id,omitempty"` // file.moved
+	ToWorkspaceID   *uint     `json:"to_workspace_id,omitempty"`   // file.moved
+}
+
+// MemberEventData: data event workspace.member_*
+type MemberEventData struct {
+	WorkspaceID uint   `json:"workspace_id"`
+	UserID      uint   `json:"user_id"`
+	Role        string `json:"role,omitempty"`
+}
+
+// StreamEvent adalah satu pesan di GET /events; Data berisi EventPayload
+type StreamEvent struct {
+	ID   uint
+	Type string
+	Data json.RawMessage
+}
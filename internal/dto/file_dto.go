@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"time"
+	"vasvault/internal/models"
+)
 
 type UploadFileRequest struct {
 	WorkspaceId *uint  `json:"workspace_id" form:"workspace_id" binding:"omitempty"`
@@ -23,6 +26,11 @@ type FileResponse struct {
 	Size        int64            `json:"size"`
 	Categories  []CategorySimple `json:"categories,omitempty"`
 	CreatedAt   time.Time        `json:"created_at"`
+
+	Metadata *models.FileMetadata `json:"metadata,omitempty"`
+
+	ScanStatus    string `json:"scan_status"`
+	ScanSignature string `json:"scan_signature,omitempty"`
 }
 
 type AssignCategoriesRequest struct {
@@ -39,3 +47,30 @@ type StorageSummaryResponse struct {
 type RenameFileRequest struct {
 	NewName string `json:"new_name" binding:"required"`
 }
+
+type BulkFileRequest struct {
+	FileIDs      []uint `json:"file_ids" binding:"required,min=1,max=500"`
+	Action       string `json:"action" binding:"required"`
+	WorkspaceID  *uint  `json:"workspace_id"`   // tujuan untuk action move, null = personal space
+	CategoryIDs  []uint `json:"category_ids"`   // untuk assign_categories / remove_categories
+	FolderID     *uint  `json:"folder_id"`      // kategori tujuan untuk action move_folder
+	FromFolderID *uint  `json:"from_folder_id"` // kategori asal untuk move_folder, null = tanpa folder
+	AllOrNothing bool   `json:"all_or_nothing"` // batalkan semua jika ada item yang gagal
+}
+
+type BulkFileItemResult struct {
+	FileID  uint   `json:"file_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BulkFileResponse struct {
+	Action    string               `json:"action"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []BulkFileItemResult `json:"results"`
+}
+
+type FileTargetRequest struct {
+	WorkspaceID *uint `json:"workspace_id"` // null = personal space
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "vasvault/pkg/jobqueue"
+
+type JobListResponse struct {
+	Jobs   []jobqueue.Job `json:"jobs"`
+	Total  int64          `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
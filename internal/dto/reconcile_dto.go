@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+type ReconcileReport struct {
+	Fixed            bool              `json:"fixed"`
+	StartedAt        time.Time         `json:"started_at"`
+	FinishedAt       time.Time         `json:"finished_at"`
+	BlobsChecked     int               `json:"blobs_checked"`
+	FilesChecked     int               `json:"files_checked"`
+	OrphanBlobs      []OrphanBlob      `json:"orphan_blobs"`
+	MissingBlobs     []MissingBlobFile `json:"missing_blobs"`
+	OrphanThumbnails []string          `json:"orphan_thumbnails"`
+	Errors           []string          `json:"errors,omitempty"`
+}
+
+// OrphanBlob: blob di storage tanpa baris di tabel files
+type OrphanBlob struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// MissingBlobFile: baris di tabel files yang blob-nya tidak ada
+type MissingBlobFile struct {
+	FileID      uint   `json:"file_id"`
+	UserID      uint   `json:"user_id"`
+	WorkspaceID *uint  `json:"workspace_id,omitempty"`
+	Filepath    string `json:"filepath"`
+}
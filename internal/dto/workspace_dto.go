@@ -26,16 +26,20 @@ type WorkspaceMemberResponse struct {
 }
 
 type WorkspaceDetailResponse struct {
-	ID          uint                      `json:"id"`
-	Name        string                    `json:"name"`
-	Description string                    `json:"description"`
-	OwnerID     uint                      `json:"owner_id"`
-	Members     []WorkspaceMemberResponse `json:"members"`
+	ID                 uint                      `json:"id"`
+	Name               string                    `json:"name"`
+	Description        string                    `json:"description"`
+	OwnerID            uint                      `json:"owner_id"`
+	StripImageMetadata bool                      `json:"strip_image_metadata"`
+	RequireCleanScan   bool                      `json:"require_clean_scan"`
+	Members            []WorkspaceMemberResponse `json:"members"`
 }
 
 type UpdateWorkspaceRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name               string `json:"name"`
+	Description        string `json:"description"`
+	StripImageMetadata *bool  `json:"strip_image_metadata"` // null = tidak diubah
+	RequireCleanScan   *bool  `json:"require_clean_scan"`   // null = tidak diubah
 }
 
 type AddMemberRequest struct {
@@ -44,4 +48,48 @@ type AddMemberRequest struct {
 
 type UpdateMemberRoleRequest struct {
 	Role string `json:"role" binding:"required"`
-}
\ No newline at end of file
+}
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin editor viewer"`
+}
+
+type RespondInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+type InvitationResponse struct {
+	ID            uint      `json:"id"`
+	WorkspaceID   uint      `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name,omitempty"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	Status        string    `json:"status"`
+	InvitedBy     string    `json:"invited_by"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+type OwnershipTransferResponse struct {
+	ID          uint      `json:"id"`
+	WorkspaceID uint      `json:"workspace_id"`
+	FromUserID  uint      `json:"from_user_id"`
+	FromName    string    `json:"from_name"`
+	ToUserID    uint      `json:"to_user_id"`
+	ToName      string    `json:"to_name"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type DeletedWorkspaceResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	FilePolicy string    `json:"file_policy"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAt    time.Time `json:"purge_at"`
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"vasvault/internal/dto"
+	"vasvault/internal/services"
+	"vasvault/pkg/utils"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	accountService services.AccountServiceInterface
+}
+
+func NewAccountHandler(accountService services.AccountServiceInterface) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// RequestExport - POST /me/export
+func (h *AccountHandler) RequestExport(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	resp, err := h.accountService.RequestExport(userID)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusAccepted, resp, "export started")
+}
+
+// GetExport - GET /me/exports/:id
+func (h *AccountHandler) GetExport(c *gin.Context) {
+	userID := c.GetUint("userID")
+	exportID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid export id")
+		return
+	}
+
+	resp, err := h.accountService.GetExport(userID, uint(exportID))
+	if err != nil {
+		utils.RespondJSON(c, http.StatusNotFound, nil, "export not found")
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, resp, "ok")
+}
+
+// DownloadExport - GET /me/exports/:id/download
+func (h *AccountHandler) DownloadExport(c *gin.Context) {
+	userID := c.GetUint("userID")
+	exportID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid export id")
+		return
+	}
+
+	path, err := h.accountService.ExportFilePath(userID, uint(exportID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrExportNotReady) {
+			utils.RespondJSON(c, http.StatusConflict, nil, err.Error())
+			return
+		}
+		utils.RespondJSON(c, http.StatusNotFound, nil, "export not found")
+		return
+	}
+
+	c.FileAttachment(path, fmt.Sprintf("vasvault-export-%d.zip", exportID))
+}
+
+// DeleteAccount - DELETE /me
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req dto.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	if err := h.accountService.DeleteAccount(userID, req); err != nil {
+		if errors.Is(err, apperrors.ErrInvalidCredentials) {
+			utils.RespondJSON(c, http.StatusForbidden, nil, "invalid password")
+			return
+		}
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, nil, "account deleted")
+}
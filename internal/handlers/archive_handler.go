@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+	"vasvault/internal/services"
+	"vasvault/pkg/utils"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveHandler struct {
+	archiveService services.ArchiveServiceInterface
+}
+
+func NewArchiveHandler(archiveService services.ArchiveServiceInterface) *ArchiveHandler {
+	return &ArchiveHandler{archiveService: archiveService}
+}
+
+// ArchiveFiles - POST /files/archive
+func (h *ArchiveHandler) ArchiveFiles(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req dto.CreateArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	files, err := h.archiveService.ResolveFiles(userID, req.FileIDs)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+		return
+	}
+
+	if req.Async {
+		h.startAsync(c, userID, nil, files)
+		return
+	}
+	h.stream(c, "vasvault-files", files)
+}
+
+// ArchiveWorkspace - GET /workspaces/:id/archive
+func (h *ArchiveHandler) ArchiveWorkspace(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	workspaceID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid workspace id")
+		return
+	}
+
+	files, err := h.archiveService.ResolveWorkspaceFiles(userID, uint(workspaceID))
+	if err != nil {
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+		return
+	}
+
+	if c.Query("async") == "true" {
+		wsID := uint(workspaceID)
+		h.startAsync(c, userID, &wsID, files)
+		return
+	}
+	h.stream(c, fmt.Sprintf("vasvault-workspace-%d", workspaceID), files)
+}
+
+// GetArchive - GET /files/archives/:id
+func (h *ArchiveHandler) GetArchive(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	archiveID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid archive id")
+		return
+	}
+
+	resp, err := h.archiveService.GetArchive(userID, uint(archiveID))
+	if err != nil {
+		utils.RespondJSON(c, http.StatusNotFound, nil, "archive not found")
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, resp, "ok")
+}
+
+// DownloadArchive - GET /files/archives/:id/download
+func (h *ArchiveHandler) DownloadArchive(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	archiveID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid archive id")
+		return
+	}
+
+	path, err := h.archiveService.ArchiveFilePath(userID, uint(archiveID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrExportNotReady) {
+			utils.RespondJSON(c, http.StatusConflict, nil, "archive is not ready or has expired")
+			return
+		}
+		utils.RespondJSON(c, http.StatusNotFound, nil, "archive not found")
+		return
+	}
+
+	c.FileAttachment(path, fmt.Sprintf("vasvault-archive-%d.zip", archiveID))
+}
+
+func (h *ArchiveHandler) startAsync(c *gin.Context, userID uint, workspaceID *uint, files []models.File) {
+	resp, err := h.archiveService.RequestArchive(userID, workspaceID, files)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+	utils.RespondJSON(c, http.StatusAccepted, resp, "archive started")
+}
+
+// stream menulis zip langsung ke response tanpa file sementara. Setelah header
+// terkirim error tidak bisa lagi dikirim sebagai JSON, jadi hanya di-log.
+func (h *ArchiveHandler) stream(c *gin.Context, name string, files []models.File) {
+	filename := fmt.Sprintf("%s-%s.zip", name, time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if err := h.archiveService.WriteArchive(c.Writer, files); err != nil {
+		log.Printf("archive stream aborted: %v", err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
 	"vasvault/internal/services"
 )
 
@@ -17,8 +19,10 @@ func NewCategoryHandler(service *services.CategoryService) *CategoryHandler {
 }
 
 type CreateCategoryRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Color string `json:"color"`
+	Name        string `json:"name" binding:"required"`
+	Color       string `json:"color"`
+	WorkspaceID *uint  `json:"workspace_id"` // kosong = kategori personal
+	ParentID    *uint  `json:"parent_id"`    // kosong = root
 }
 
 // POST /categories
@@ -31,7 +35,7 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 
 	userID := c.GetUint("userID")
 
-	category, err := h.service.Create(req.Name, req.Color, userID)
+	category, err := h.service.Create(req.Name, req.Color, userID, req.WorkspaceID, req.ParentID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -45,9 +49,28 @@ func (h *CategoryHandler) List(c *gin.Context) {
 	userID := c.GetUint("userID")
 	search := c.Query("search")
 
-	categories, err := h.service.List(userID, search)
+	// ?workspace_id= untuk kategori workspace
+	var workspaceID *uint
+	if raw := c.Query("workspace_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace ID"})
+			return
+		}
+		wsID := uint(id)
+		workspaceID = &wsID
+	}
+
+	// ?tree=true untuk hasil bertingkat (children di dalam parent)
+	var categories []models.Category
+	var err error
+	if c.Query("tree") == "true" {
+		categories, err = h.service.Tree(userID, search, workspaceID)
+	} else {
+		categories, err = h.service.List(userID, search, workspaceID)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -133,3 +156,180 @@ func (h *CategoryHandler) Delete(c *gin.Context) {
 	})
 }
 
+type MoveCategoryRequest struct {
+	ParentID *uint `json:"parent_id"` // null = pindah ke root
+}
+
+// POST /categories/:id/move
+func (h *CategoryHandler) Move(c *gin.Context) {
+	var req MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	category, err := h.service.Move(userID, uint(categoryID), req.ParentID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// GET /categories/:id/rules
+func (h *CategoryHandler) ListRules(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	rules, err := h.service.ListRules(userID, uint(categoryID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// POST /categories/:id/rules
+func (h *CategoryHandler) CreateRule(c *gin.Context) {
+	var req dto.CreateCategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	rule, err := h.service.CreateRule(userID, uint(categoryID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DELETE /categories/:id/rules/:ruleId
+func (h *CategoryHandler) DeleteRule(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("ruleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule ID"})
+		return
+	}
+
+	if err := h.service.DeleteRule(userID, uint(categoryID), uint(ruleID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "rule deleted successfully",
+	})
+}
+
+// POST /categories/:id/rules/apply
+func (h *CategoryHandler) ApplyRules(c *gin.Context) {
+	var req dto.ApplyCategoryRulesRequest
+	// body opsional; tanpa body berarti langsung diterapkan
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID := c.GetUint("userID")
+
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	result, err := h.service.ApplyRules(userID, uint(categoryID), req.DryRun)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GET /categories/stats
+func (h *CategoryHandler) Stats(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var workspaceID *uint
+	if raw := c.Query("workspace_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workspace ID"})
+			return
+		}
+		wsID := uint(id)
+		workspaceID = &wsID
+	}
+
+	stats, err := h.service.Stats(userID, workspaceID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// POST /categories/:id/merge
+func (h *CategoryHandler) Merge(c *gin.Context) {
+	var req dto.MergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	sourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	category, err := h.service.Merge(userID, uint(sourceID), req.TargetID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "categories merged successfully",
+		"category": category,
+	})
+}
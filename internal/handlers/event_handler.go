@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/services"
+	"vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// komentar kosong supaya proxy tidak menutup koneksi yang diam
+	streamKeepAlive = 25 * time.Second
+	// token hanya dicek saat stream dibuka; setelah ini client harus
+	// reconnect dengan token yang masih berlaku (access token berlaku 15 menit)
+	streamMaxDuration = 15 * time.Minute
+	streamRetryMs     = 3000
+)
+
+type EventHandler struct {
+	service services.EventServiceInterface
+}
+
+func NewEventHandler(service services.EventServiceInterface) *EventHandler {
+	return &EventHandler{service: service}
+}
+
+// Stream - GET /events (Server-Sent Events)
+func (h *EventHandler) Stream(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	// header Last-Event-ID dari EventSource, atau ?last_event_id= untuk client
+	// yang tidak bisa mengirim header
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	var lastEventID uint
+	if raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = uint(id)
+	}
+
+	stream, err := h.service.Subscribe(userID, lastEventID)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+	defer stream.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMs)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	deadline := time.NewTimer(streamMaxDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		case event, ok := <-stream.C:
+			if !ok {
+				return
+			}
+			writeStreamEvent(c, event)
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeStreamEvent(c *gin.Context, event dto.StreamEvent) {
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -1,16 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"vasvault/internal/dto"
 	"vasvault/internal/services"
 	"vasvault/pkg/utils"
 
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,7 +40,10 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	}
 
 	var request dto.UploadFileRequest
-	_ = c.ShouldBind(&request)
+	if err := c.ShouldBind(&request); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
 
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
@@ -53,7 +54,12 @@ func (h *FileHandler) Upload(c *gin.Context) {
 
 	response, err := h.FileService.UploadFile(userID, file, header, request)
 	if err != nil {
-		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		status := http.StatusInternalServerError
+		if errors.Is(err, utils.ErrUploadRejected) {
+			status = http.StatusBadRequest
+		}
+		utils.RespondJSON(c, status, nil, err.Error())
+		return
 	}
 
 	utils.RespondJSON(c, http.StatusOK, response, "file uploaded successfully")
@@ -78,6 +84,7 @@ func (h *FileHandler) GetByID(c *gin.Context) {
 
 // Download - GET /files/:id/download
 func (h *FileHandler) Download(c *gin.Context) {
+	userID := c.GetUint("userID")
 	idParam := c.Param("id")
 	fileID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
@@ -85,19 +92,28 @@ func (h *FileHandler) Download(c *gin.Context) {
 		return
 	}
 
-	response, err := h.FileService.GetFileByID(uint(fileID))
-	if err != nil {
+	path, err := h.FileService.GetDownloadPath(userID, uint(fileID))
+	switch {
+	case err == nil:
+		// Serve file directly (Gin will set content-type)
+		c.File(path)
+	case errors.Is(err, utils.ErrFileQuarantined), errors.Is(err, utils.ErrFileAccessDenied):
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+	case errors.Is(err, utils.ErrScanPending):
+		// workspace mewajibkan scan bersih sebelum file bisa diunduh
+		c.Header("Retry-After", "30")
+		utils.RespondJSON(c, http.StatusLocked, nil, err.Error())
+	default:
 		utils.RespondJSON(c, http.StatusNotFound, nil, "file not found")
-		return
 	}
-
-	// Serve file directly (Gin will set content-type)
-	c.File(response.FilePath)
 }
 
-// Thumbnail - GET /files/:id/thumbnail
-// Generates a cached thumbnail (200x200) and serves it.
+// Thumbnail - GET /files/:id/thumbnail?size=small|medium|large
+// Thumbnail (gambar, halaman pertama PDF, potongan kode, atau ikon) dibuat di
+// background setelah upload. Selama belum siap, response 202 (atau gambar
+// placeholder jika ?placeholder=true).
 func (h *FileHandler) Thumbnail(c *gin.Context) {
+	userID := c.GetUint("userID")
 	idParam := c.Param("id")
 	fileID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
@@ -105,46 +121,144 @@ func (h *FileHandler) Thumbnail(c *gin.Context) {
 		return
 	}
 
-	response, err := h.FileService.GetFileByID(uint(fileID))
-	if err != nil {
-		utils.RespondJSON(c, http.StatusNotFound, nil, "file not found")
+	size := c.Query("size")
+	if _, ok := services.ThumbnailPresetByName(size); !ok {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "size must be one of small, medium, large")
 		return
 	}
+	format := thumbnailFormat(c)
+	c.Header("Vary", "Accept")
 
-	// only handle image types
-	if !strings.HasPrefix(response.MimeType, "image/") {
-		utils.RespondJSON(c, http.StatusBadRequest, nil, "thumbnail only supported for images")
+	path, status, err := h.FileService.GetThumbnail(userID, uint(fileID), size, format)
+	switch {
+	case errors.Is(err, utils.ErrFileQuarantined), errors.Is(err, utils.ErrFileAccessDenied):
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+		return
+	case errors.Is(err, utils.ErrScanPending):
+		c.Header("Retry-After", "30")
+		utils.RespondJSON(c, http.StatusLocked, nil, err.Error())
 		return
+	case errors.Is(err, utils.ErrFileNotFound):
+		utils.RespondJSON(c, http.StatusNotFound, nil, "file not found")
+		return
+	case err != nil:
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, "failed to load thumbnail")
+		return
+	}
+
+	switch status {
+	case services.ThumbnailReady:
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.File(path)
+	case services.ThumbnailFailed:
+		utils.RespondJSON(c, http.StatusUnprocessableEntity, nil, "failed to generate thumbnail")
+	default:
+		c.Header("Retry-After", "2")
+		c.Header("Cache-Control", "no-store")
+		if c.Query("placeholder") == "true" {
+			data, err := h.FileService.ThumbnailPlaceholder(size, format)
+			if err != nil {
+				utils.RespondJSON(c, http.StatusInternalServerError, nil, "failed to render placeholder")
+				return
+			}
+			c.Data(http.StatusOK, thumbnailContentType(data), data)
+			return
+		}
+		utils.RespondJSON(c, http.StatusAccepted, gin.H{"status": status}, "thumbnail is being generated")
 	}
+}
 
-	thumbDir := filepath.Join("./uploads", "thumbs")
-	if err := os.MkdirAll(thumbDir, os.ModePerm); err != nil {
-		utils.RespondJSON(c, http.StatusInternalServerError, nil, "failed to create thumb dir")
+// Image - GET /files/:id/image?w=&h=&fit=&format=&q=
+// Resize/crop/convert gambar dengan parameter dari allow-list, hasilnya di-cache.
+func (h *FileHandler) Image(c *gin.Context) {
+	userID := c.GetUint("userID")
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid file id")
 		return
 	}
 
-	base := filepath.Base(response.FilePath)
-	thumbPath := filepath.Join(thumbDir, base+".thumb.jpg")
-
-	// serve cached thumbnail if exists
-	if _, err := os.Stat(thumbPath); err == nil {
-		c.File(thumbPath)
+	opts := services.ImageTransformOptions{Fit: strings.ToLower(c.Query("fit"))}
+	for param, target := range map[string]*int{"w": &opts.Width, "h": &opts.Height, "q": &opts.Quality} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		if *target, err = strconv.Atoi(raw); err != nil {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid "+param)
+			return
+		}
+	}
+	if opts.Format, err = imageFormat(c); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
 		return
 	}
+	c.Header("Vary", "Accept")
 
-	// generate thumbnail
-	img, err := imaging.Open(response.FilePath)
-	if err != nil {
-		utils.RespondJSON(c, http.StatusInternalServerError, nil, "failed to open image")
-		return
+	path, err := h.FileService.TransformImage(userID, uint(fileID), opts)
+	switch {
+	case err == nil:
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.File(path)
+	case errors.Is(err, utils.ErrInvalidTransform):
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+	case errors.Is(err, utils.ErrImageTooLarge):
+		utils.RespondJSON(c, http.StatusUnprocessableEntity, nil, err.Error())
+	case errors.Is(err, utils.ErrImageBusy):
+		c.Header("Retry-After", "5")
+		utils.RespondJSON(c, http.StatusServiceUnavailable, nil, err.Error())
+	case errors.Is(err, utils.ErrFileQuarantined), errors.Is(err, utils.ErrFileAccessDenied):
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+	case errors.Is(err, utils.ErrScanPending):
+		c.Header("Retry-After", "30")
+		utils.RespondJSON(c, http.StatusLocked, nil, err.Error())
+	case errors.Is(err, utils.ErrFileNotFound):
+		utils.RespondJSON(c, http.StatusNotFound, nil, "file not found")
+	default:
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, "failed to transform image")
 	}
-	thumb := imaging.Thumbnail(img, 200, 200, imaging.Lanczos)
-	if err := imaging.Save(thumb, thumbPath, imaging.JPEGQuality(80)); err != nil {
-		utils.RespondJSON(c, http.StatusInternalServerError, nil, "failed to save thumbnail")
-		return
+}
+
+// imageFormat: ?format= menang, selain itu WebP jika client mengirim
+// Accept: image/webp
+func imageFormat(c *gin.Context) (string, error) {
+	switch strings.ToLower(c.Query("format")) {
+	case "":
+	case "webp":
+		return services.FormatWebP, nil
+	case "jpeg", "jpg":
+		return services.FormatJPEG, nil
+	case "png":
+		return services.FormatPNG, nil
+	default:
+		return "", errors.New("format must be jpeg, png or webp")
+	}
+	if strings.Contains(c.GetHeader("Accept"), "image/webp") {
+		return services.FormatWebP, nil
+	}
+	return services.FormatJPEG, nil
+}
+
+// thumbnailFormat: ?format= menang, selain itu WebP jika client mengirim
+// Accept: image/webp
+func thumbnailFormat(c *gin.Context) string {
+	switch strings.ToLower(c.Query("format")) {
+	case "webp":
+		return services.FormatWebP
+	case "jpeg", "jpg":
+		return services.FormatJPEG
+	}
+	if strings.Contains(c.GetHeader("Accept"), "image/webp") {
+		return services.FormatWebP
 	}
+	return services.FormatJPEG
+}
 
-	c.File(thumbPath)
+func thumbnailContentType(data []byte) string {
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "image/webp"
+	}
+	return "image/jpeg"
 }
 
 func (h *FileHandler) ListMyFiles(c *gin.Context) {
@@ -177,7 +291,10 @@ func (h *FileHandler) ListMyFiles(c *gin.Context) {
 		categoryID = &id
 	}
 
-	response, err := h.FileService.ListUserFilesWithOptionalCategory(userID, categoryID)
+	// includeDescendants=true ikut menampilkan file dari sub-kategori
+	includeDescendants := c.Query("includeDescendants") == "true"
+
+	response, err := h.FileService.ListUserFilesWithOptionalCategory(userID, categoryID, includeDescendants)
 	if err != nil {
 		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
 		return
@@ -219,6 +336,7 @@ func (h *FileHandler) ListByWorkspace(c *gin.Context) {
 }
 
 func (h *FileHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("userID")
 	idParam := c.Param("id")
 	fileID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
@@ -226,7 +344,15 @@ func (h *FileHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.FileService.DeleteFile(uint(fileID)); err != nil {
+	err = h.FileService.DeleteFile(userID, uint(fileID))
+	switch {
+	case errors.Is(err, utils.ErrFileNotFound):
+		utils.RespondJSON(c, http.StatusNotFound, nil, err.Error())
+		return
+	case errors.Is(err, utils.ErrFileForbidden):
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+		return
+	case err != nil:
 		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
 		return
 	}
@@ -340,3 +466,58 @@ func (h *FileHandler) Rename(c *gin.Context) {
 
 	utils.RespondJSON(c, http.StatusOK, resp, "file renamed successfully")
 }
+
+// BulkAction - POST /files/bulk
+func (h *FileHandler) BulkAction(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req dto.BulkFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	response, err := h.FileService.BulkAction(userID, req)
+	if errors.Is(err, utils.ErrBulkAborted) {
+		utils.RespondJSON(c, http.StatusConflict, response, err.Error())
+		return
+	}
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, response, "bulk action processed")
+}
+
+// Move - POST /files/:id/move
+func (h *FileHandler) Move(c *gin.Context) {
+	h.transfer(c, h.FileService.MoveFile, "file moved successfully")
+}
+
+// Copy - POST /files/:id/copy
+func (h *FileHandler) Copy(c *gin.Context) {
+	h.transfer(c, h.FileService.CopyFile, "file copied successfully")
+}
+
+func (h *FileHandler) transfer(c *gin.Context, action func(userID, fileID uint, req dto.FileTargetRequest) (*dto.FileResponse, error), message string) {
+	userID := c.GetUint("userID")
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid file id")
+		return
+	}
+
+	var req dto.FileTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	response, err := action(userID, uint(fileID), req)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, response, message)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vasvault/internal/dto"
+	"vasvault/pkg/jobqueue"
+	"vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxJobPageSize = 200
+
+type JobHandler struct {
+	jobs *jobqueue.Queue
+}
+
+func NewJobHandler(jobs *jobqueue.Queue) *JobHandler {
+	return &JobHandler{jobs: jobs}
+}
+
+// List - GET /admin/jobs?queue=&type=&status=&limit=&offset=
+func (h *JobHandler) List(c *gin.Context) {
+	opts := jobqueue.ListOptions{
+		Queue:  c.Query("queue"),
+		Type:   c.Query("type"),
+		Status: c.Query("status"),
+		Limit:  50,
+	}
+	switch opts.Status {
+	case "", jobqueue.StatusQueued, jobqueue.StatusRunning, jobqueue.StatusSucceeded, jobqueue.StatusDead:
+	default:
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid status")
+		return
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxJobPageSize {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "limit must be between 1 and 200")
+			return
+		}
+		opts.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid offset")
+			return
+		}
+		opts.Offset = offset
+	}
+
+	jobs, total, err := h.jobs.List(opts)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, dto.JobListResponse{
+		Jobs:   jobs,
+		Total:  total,
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	}, "Jobs retrieved")
+}
+
+// Stats - GET /admin/jobs/stats
+func (h *JobHandler) Stats(c *gin.Context) {
+	stats, err := h.jobs.Stats()
+	if err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+	if stats == nil {
+		stats = []jobqueue.QueueStats{}
+	}
+	utils.RespondJSON(c, http.StatusOK, stats, "Job stats retrieved")
+}
+
+// Detail - GET /admin/jobs/:id
+func (h *JobHandler) Detail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid job id")
+		return
+	}
+
+	job, err := h.jobs.Find(uint(id))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, job, "Job retrieved")
+}
+
+// Retry - POST /admin/jobs/:id/retry
+func (h *JobHandler) Retry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid job id")
+		return
+	}
+
+	job, err := h.jobs.Retry(uint(id))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, job, "Job queued for retry")
+}
+
+func (h *JobHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jobqueue.ErrNotFound):
+		utils.RespondJSON(c, http.StatusNotFound, nil, "job not found")
+	case errors.Is(err, jobqueue.ErrNotRetryable):
+		utils.RespondJSON(c, http.StatusConflict, nil, err.Error())
+	default:
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+	"vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS - GET /.well-known/jwks.json
+// Publishes the public keys used to sign vasvault tokens.
+func JWKS(c *gin.Context) {
+	set, err := utils.PublicJWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load keys"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
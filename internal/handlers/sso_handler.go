@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"vasvault/internal/dto"
+	"vasvault/internal/services"
+	"vasvault/pkg/utils"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// cookie yang mengikat state login ke browser yang memulainya, supaya
+	// callback dengan state milik orang lain (login CSRF) ditolak
+	oidcStateCookie = "vasvault_oidc_state"
+	oidcCookiePath  = "/api/v1/auth/oidc"
+	oidcCookieAge   = 10 * 60
+)
+
+type SSOHandler struct {
+	ssoService services.SSOServiceInterface
+}
+
+func NewSSOHandler(ssoService services.SSOServiceInterface) *SSOHandler {
+	return &SSOHandler{ssoService: ssoService}
+}
+
+// Login - GET /auth/oidc/login
+// Redirects the browser to the identity provider.
+func (h *SSOHandler) Login(c *gin.Context) {
+	authURL, state, err := h.ssoService.BeginLogin(c.Request.Context())
+	if err != nil {
+		log.Printf("oidc login failed: %v", err)
+		utils.RespondJSON(c, http.StatusBadGateway, nil, "identity provider unavailable")
+		return
+	}
+
+	// Lax: cookie tetap terkirim saat provider me-redirect kembali ke callback
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, oidcCookieAge, oidcCookiePath, "", isSecureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback - GET /auth/oidc/callback
+func (h *SSOHandler) Callback(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		utils.RespondJSON(c, http.StatusUnauthorized, nil, "login rejected by identity provider: "+errParam)
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "code and state are required")
+		return
+	}
+
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", isSecureRequest(c), true)
+	if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid or expired login state")
+		return
+	}
+
+	userResp, err := h.ssoService.CompleteLogin(c.Request.Context(), code, state)
+	if err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidToken):
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid or expired login state")
+		case errors.Is(err, apperrors.ErrSSOEmailUnverified):
+			utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+		case errors.Is(err, apperrors.ErrSSONotProvisioned):
+			utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+		default:
+			log.Printf("oidc callback failed: %v", err)
+			utils.RespondJSON(c, http.StatusUnauthorized, nil, "SSO login failed")
+		}
+		return
+	}
+
+	token, err := utils.GenerateTokenPair(userResp.Username, userResp.ID)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, "Failed to generate tokens")
+		return
+	}
+
+	response := dto.AuthResponse{
+		User: *userResp,
+		Token: dto.TokenResponse{
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+		},
+	}
+
+	utils.RespondJSON(c, http.StatusOK, response, "Login successful")
+}
+
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vasvault/internal/dto"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeSSOService struct {
+	state     string
+	completed bool
+}
+
+func (f *fakeSSOService) BeginLogin(ctx context.Context) (string, string, error) {
+	return "https://idp.example/authorize?state=" + f.state, f.state, nil
+}
+
+func (f *fakeSSOService) CompleteLogin(ctx context.Context, code string, state string) (*dto.UserResponse, error) {
+	f.completed = true
+	return nil, apperrors.ErrInvalidToken
+}
+
+func newSSORouter(svc *fakeSSOService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := NewSSOHandler(svc)
+	r.GET("/api/v1/auth/oidc/login", h.Login)
+	r.GET("/api/v1/auth/oidc/callback", h.Callback)
+	return r
+}
+
+func TestSSOLoginSetsStateCookie(t *testing.T) {
+	r := newSSORouter(&fakeSSOService{state: "abc"})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login", nil))
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	ck := cookies[0]
+	if ck.Name != oidcStateCookie || ck.Value != "abc" || !ck.HttpOnly || ck.Path != oidcCookiePath || ck.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("unexpected cookie: %+v", ck)
+	}
+}
+
+func TestSSOCallbackChecksStateCookie(t *testing.T) {
+	tests := []struct {
+		name          string
+		cookie        string
+		wantCompleted bool
+	}{
+		{name: "missing cookie"},
+		{name: "other browser's state", cookie: "xyz"},
+		{name: "matching cookie", cookie: "abc", wantCompleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeSSOService{state: "abc"}
+			r := newSSORouter(svc)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?code=c&state=abc", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			if svc.completed != tt.wantCompleted {
+				t.Fatalf("CompleteLogin called = %v, want %v", svc.completed, tt.wantCompleted)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"vasvault/internal/dto"
 	"vasvault/internal/services"
+	"vasvault/pkg/ratelimit"
 	"vasvault/pkg/utils"
 	apperrors "vasvault/pkg/utils"
 
@@ -13,11 +14,13 @@ import (
 
 type UserHandler struct {
 	userService services.UserServiceInterface
+	lockout     *ratelimit.Lockout
 }
 
-func NewUserHandler(userService services.UserServiceInterface) *UserHandler {
+func NewUserHandler(userService services.UserServiceInterface, lockout *ratelimit.Lockout) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		lockout:     lockout,
 	}
 }
 
@@ -45,9 +48,10 @@ func (h *UserHandler) Me(c *gin.Context) {
 	}
 
 	response := dto.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.VerifiedAt != nil,
 	}
 	utils.RespondJSON(c, http.StatusOK, response, "ok")
 }
@@ -100,11 +104,23 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if locked, err := h.lockout.LockedFor(loginRequest.Email); err == nil && locked > 0 {
+		c.Header("Retry-After", ratelimit.HeaderSeconds(locked))
+		utils.RespondJSON(c, http.StatusTooManyRequests, nil, "Too many failed login attempts, please try again later")
+		return
+	}
+
 	userResp, err := h.userService.Login(loginRequest)
 	if err != nil {
+		if locked, err := h.lockout.Fail(loginRequest.Email); err == nil && locked > 0 {
+			c.Header("Retry-After", ratelimit.HeaderSeconds(locked))
+			utils.RespondJSON(c, http.StatusTooManyRequests, nil, "Too many failed login attempts, please try again later")
+			return
+		}
 		utils.RespondJSON(c, http.StatusUnauthorized, nil, "Invalid email or password")
 		return
 	}
+	_ = h.lockout.Succeed(loginRequest.Email)
 	token, err := utils.GenerateTokenPair(userResp.Username, userResp.ID)
 	if err != nil {
 		utils.RespondJSON(c, http.StatusInternalServerError, nil, "Failed to generate tokens")
@@ -112,11 +128,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 	}
 
 	response := dto.AuthResponse{
-		User: dto.UserResponse{
-			ID:       userResp.ID,
-			Email:    userResp.Email,
-			Username: userResp.Username,
-		},
+		User: *userResp,
 		Token: dto.TokenResponse{
 			AccessToken:  token.AccessToken,
 			RefreshToken: token.RefreshToken,
@@ -151,10 +163,18 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	resp, err := h.userService.UpdateUser(id, updateRequest)
 	if err != nil {
+		if errors.Is(err, apperrors.ErrEmailExists) {
+			utils.RespondJSON(c, http.StatusConflict, nil, "email already exists")
+			return
+		}
 		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
 		return
 	}
 
+	if resp.PendingEmail != "" {
+		utils.RespondJSON(c, http.StatusOK, resp, "Profile updated, please verify your new email address")
+		return
+	}
 	utils.RespondJSON(c, http.StatusOK, resp, "Profile updated successfully")
 }
 
@@ -187,9 +207,10 @@ func (h *UserHandler) Refresh(c *gin.Context) {
 	}
 	response := dto.AuthResponse{
 		User: dto.UserResponse{
-			ID:       createdUser.ID,
-			Email:    createdUser.Email,
-			Username: createdUser.Username,
+			ID:            createdUser.ID,
+			Email:         createdUser.Email,
+			Username:      createdUser.Username,
+			EmailVerified: createdUser.VerifiedAt != nil,
 		},
 		Token: dto.TokenResponse{
 			AccessToken:  token.AccessToken,
@@ -199,3 +220,75 @@ func (h *UserHandler) Refresh(c *gin.Context) {
 
 	utils.RespondJSON(c, http.StatusOK, response, "Token refreshed successfully")
 }
+
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var request dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "Validation error")
+		return
+	}
+
+	if err := h.userService.ForgotPassword(request); err != nil {
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, "Failed to send reset email")
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, nil, "If the email is registered, a reset link has been sent")
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var request dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "Validation error")
+		return
+	}
+
+	if err := h.userService.ResetPassword(request); err != nil {
+		if errors.Is(err, apperrors.ErrInvalidToken) {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid or expired token")
+			return
+		}
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, nil, "Password reset successfully")
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var request dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "Validation error")
+		return
+	}
+
+	resp, err := h.userService.VerifyEmail(request)
+	if err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrInvalidToken):
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid or expired token")
+		case errors.Is(err, apperrors.ErrEmailExists):
+			utils.RespondJSON(c, http.StatusConflict, nil, "email already exists")
+		default:
+			utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		}
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, resp, "Email verified successfully")
+}
+
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	id := c.GetUint("userID")
+
+	if err := h.userService.ResendVerification(id); err != nil {
+		if errors.Is(err, apperrors.ErrAlreadyVerified) {
+			utils.RespondJSON(c, http.StatusConflict, nil, "email already verified")
+			return
+		}
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	utils.RespondJSON(c, http.StatusOK, nil, "Verification email sent")
+}
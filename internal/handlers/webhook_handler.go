@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"vasvault/internal/dto"
+	"vasvault/internal/services"
+	"vasvault/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 200
+)
+
+type WebhookHandler struct {
+	service services.WebhookServiceInterface
+}
+
+func NewWebhookHandler(service services.WebhookServiceInterface) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// Create - POST /webhooks
+func (h *WebhookHandler) Create(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req dto.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	resp, err := h.service.CreateWebhook(userID, req)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusCreated, resp, "webhook created")
+}
+
+// List - GET /webhooks?workspace_id=
+func (h *WebhookHandler) List(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	// tanpa workspace_id: webhook personal
+	var workspaceID *uint
+	if raw := c.Query("workspace_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid workspace id")
+			return
+		}
+		wsID := uint(id)
+		workspaceID = &wsID
+	}
+
+	resp, err := h.service.ListWebhooks(userID, workspaceID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, resp, "ok")
+}
+
+// Detail - GET /webhooks/:id
+func (h *WebhookHandler) Detail(c *gin.Context) {
+	userID := c.GetUint("userID")
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.GetWebhook(userID, webhookID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, resp, "ok")
+}
+
+// Update - PUT /webhooks/:id
+func (h *WebhookHandler) Update(c *gin.Context) {
+	userID := c.GetUint("userID")
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	resp, err := h.service.UpdateWebhook(userID, webhookID, req)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, resp, "webhook updated")
+}
+
+// Delete - DELETE /webhooks/:id
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	userID := c.GetUint("userID")
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(userID, webhookID); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, nil, "webhook deleted")
+}
+
+// Ping - POST /webhooks/:id/ping
+func (h *WebhookHandler) Ping(c *gin.Context) {
+	userID := c.GetUint("userID")
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service.PingWebhook(userID, webhookID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, resp, "ping sent")
+}
+
+// Deliveries - GET /webhooks/:id/deliveries?limit=
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	userID := c.GetUint("userID")
+	webhookID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryPageSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxDeliveryPageSize {
+			utils.RespondJSON(c, http.StatusBadRequest, nil, "limit must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+
+	resp, err := h.service.ListDeliveries(userID, webhookID, limit)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	utils.RespondJSON(c, http.StatusOK, resp, "ok")
+}
+
+func webhookIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.RespondJSON(c, http.StatusBadRequest, nil, "invalid webhook id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrWebhookNotFound):
+		utils.RespondJSON(c, http.StatusNotFound, nil, err.Error())
+	case errors.Is(err, utils.ErrWebhookForbidden):
+		utils.RespondJSON(c, http.StatusForbidden, nil, err.Error())
+	case errors.Is(err, utils.ErrInvalidWebhook):
+		utils.RespondJSON(c, http.StatusBadRequest, nil, err.Error())
+	default:
+		utils.RespondJSON(c, http.StatusInternalServerError, nil, err.Error())
+	}
+}
@@ -107,13 +107,14 @@ func (h *WorkspaceHandler) Delete(c *gin.Context) {
     idParam := c.Param("id")
     workspaceID, _ := strconv.Atoi(idParam)
 
-    err := h.service.DeleteWorkspace(userID, uint(workspaceID))
+    // file_policy: move (default) ke personal space owner, atau trash
+    deleted, err := h.service.DeleteWorkspace(userID, uint(workspaceID), c.Query("file_policy"))
     if err != nil {
         c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Workspace deleted successfully"})
+    c.JSON(http.StatusOK, gin.H{"message": "Workspace deleted successfully", "data": deleted})
 }
 
 func (h *WorkspaceHandler) AddMember(c *gin.Context) {
@@ -164,4 +165,193 @@ func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
     }
 
     c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
-}
\ No newline at end of file
+}
+func (h *WorkspaceHandler) Invite(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.service.InviteMember(userIDCtx.(uint), uint(workspaceID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Invitation sent", "data": invitation})
+}
+
+func (h *WorkspaceHandler) ListInvitations(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	invitations, err := h.service.ListWorkspaceInvitations(userIDCtx.(uint), uint(workspaceID))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+	invitationID, _ := strconv.Atoi(c.Param("invitationId"))
+
+	if err := h.service.RevokeInvitation(userIDCtx.(uint), uint(workspaceID), uint(invitationID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+func (h *WorkspaceHandler) MyInvitations(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+
+	invitations, err := h.service.ListMyInvitations(userIDCtx.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	invitationID, _ := strconv.Atoi(c.Param("id"))
+
+	// body opsional, berisi token dari email
+	var req dto.RespondInvitationRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := h.service.AcceptInvitation(userIDCtx.(uint), uint(invitationID), req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted"})
+}
+
+func (h *WorkspaceHandler) DeclineInvitation(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	invitationID, _ := strconv.Atoi(c.Param("id"))
+
+	if err := h.service.DeclineInvitation(userIDCtx.(uint), uint(invitationID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+}
+
+func (h *WorkspaceHandler) Leave(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	if err := h.service.LeaveWorkspace(userIDCtx.(uint), uint(workspaceID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You left the workspace"})
+}
+
+func (h *WorkspaceHandler) TransferOwnership(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	var req dto.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := h.service.RequestOwnershipTransfer(userIDCtx.(uint), uint(workspaceID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Ownership transfer requested, waiting for confirmation", "data": transfer})
+}
+
+func (h *WorkspaceHandler) PendingTransfer(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	transfer, err := h.service.GetPendingTransfer(userIDCtx.(uint), uint(workspaceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfer})
+}
+
+func (h *WorkspaceHandler) AcceptTransfer(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	if err := h.service.RespondOwnershipTransfer(userIDCtx.(uint), uint(workspaceID), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You are now the owner of this workspace"})
+}
+
+func (h *WorkspaceHandler) DeclineTransfer(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	if err := h.service.RespondOwnershipTransfer(userIDCtx.(uint), uint(workspaceID), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer declined"})
+}
+
+func (h *WorkspaceHandler) CancelTransfer(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	if err := h.service.CancelOwnershipTransfer(userIDCtx.(uint), uint(workspaceID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer cancelled"})
+}
+
+func (h *WorkspaceHandler) ListDeleted(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+
+	workspaces, err := h.service.ListDeletedWorkspaces(userIDCtx.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": workspaces})
+}
+
+func (h *WorkspaceHandler) Restore(c *gin.Context) {
+	userIDCtx, _ := c.Get("userID")
+	workspaceID, _ := strconv.Atoi(c.Param("id"))
+
+	workspace, err := h.service.RestoreWorkspace(userIDCtx.(uint), uint(workspaceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace restored successfully", "data": workspace})
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"os"
@@ -70,10 +71,16 @@ func GinBearerAuth() gin.HandlerFunc {
 	}
 }
 
+// APIClientCtxKey holds the name of the client whose key from API_KEYS
+// authenticated the request
+const APIClientCtxKey = "apiClient"
+
+// GinAPIKeyAuth checks x-api-key against API_KEY (one key shared by every
+// client) and API_KEYS (one key per client, "web:<key>,mobile:<key>").
+// Without either, the check is skipped.
 func GinAPIKeyAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		expected := os.Getenv("API_KEY")
-		if expected == "" {
+		if os.Getenv("API_KEY") == "" && os.Getenv("API_KEYS") == "" {
 			c.Next()
 			return
 		}
@@ -84,11 +91,61 @@ func GinAPIKeyAuth() gin.HandlerFunc {
 			return
 		}
 
-		if key != expected {
+		client, ok := LookupAPIClient(key)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
 			return
 		}
 
+		if client != "" {
+			c.Set(APIClientCtxKey, client)
+		}
+		c.Next()
+	}
+}
+
+// LookupAPIClient reports whether key is a configured API key and, for keys
+// from API_KEYS, the client it belongs to. The shared API_KEY has no client.
+func LookupAPIClient(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	for _, entry := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		name, clientKey, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || name == "" || clientKey == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(clientKey)) == 1 {
+			return name, true
+		}
+	}
+	if expected := os.Getenv("API_KEY"); expected != "" && subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1 {
+		return "", true
+	}
+	return "", false
+}
+
+// GinAdminAuth melindungi endpoint admin dengan header x-admin-key. Tanpa
+// ADMIN_API_KEY endpoint admin tidak bisa diakses sama sekali.
+func GinAdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := os.Getenv("ADMIN_API_KEY")
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin api is disabled"})
+			return
+		}
+
+		key := c.GetHeader("x-admin-key")
+		if key == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing x-admin-key header"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(key), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin key"})
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"vasvault/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc returns the identity a request is limited by, or "" when it does
+// not apply (e.g. no user on a public route).
+type KeyFunc func(c *gin.Context) string
+
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+func KeyByUser(c *gin.Context) string {
+	userID := c.GetUint(UserCtxKey)
+	if userID == 0 {
+		return ""
+	}
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// KeyByAPIClient keys on the client whose API key (from API_KEYS) was
+// verified. Public routes skip GinAPIKeyAuth, so the header is looked up
+// here; an unknown key or the shared API_KEY gets no client bucket.
+func KeyByAPIClient(c *gin.Context) string {
+	client := c.GetString(APIClientCtxKey)
+	if client == "" {
+		client, _ = LookupAPIClient(c.GetHeader("x-api-key"))
+	}
+	if client == "" {
+		return ""
+	}
+	return "client:" + client
+}
+
+// GinRateLimit applies a token bucket per key returned by keys. The request
+// is rejected if any of its buckets is empty. Store errors fail open.
+func GinRateLimit(store ratelimit.Store, name string, limit ratelimit.Limit, keys ...KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tightest *ratelimit.Result
+
+		for _, keyFn := range keys {
+			key := keyFn(c)
+			if key == "" {
+				continue
+			}
+
+			res, err := store.Allow(name+":"+key, limit)
+			if err != nil {
+				log.Printf("rate limit store error: %v", err)
+				continue
+			}
+
+			if tightest == nil || !res.Allowed || (tightest.Allowed && res.Remaining < tightest.Remaining) {
+				r := res
+				tightest = &r
+			}
+			if !res.Allowed {
+				break
+			}
+		}
+
+		if tightest == nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("X-RateLimit-Reset", ratelimit.HeaderSeconds(tightest.ResetAfter))
+
+		if !tightest.Allowed {
+			c.Header("Retry-After", ratelimit.HeaderSeconds(tightest.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -2,11 +2,17 @@ package models
 
 import "gorm.io/gorm"
 
+// Category bisa milik user (WorkspaceID null) atau milik workspace. Nama unik
+// per user untuk kategori personal dan per workspace untuk kategori workspace.
 type Category struct {
 	gorm.Model
-	Name   string `gorm:"not null;uniqueIndex:idx_user_category" json:"name"`
-	Color  string `gorm:"default:'#3B82F6'" json:"color"` // hex color
-	UserID uint   `gorm:"not null;uniqueIndex:idx_user_category" json:"user_id"`
-	User   User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Files  []File `gorm:"many2many:file_categories;" json:"files,omitempty"`
+	Name        string     `gorm:"not null;uniqueIndex:idx_personal_category,where:workspace_id IS NULL;uniqueIndex:idx_workspace_category,where:workspace_id IS NOT NULL" json:"name"`
+	Color       string     `gorm:"default:'#3B82F6'" json:"color"`                                                       // hex color
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_personal_category,where:workspace_id IS NULL" json:"user_id"` // pembuat kategori
+	User        User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	WorkspaceID *uint      `gorm:"uniqueIndex:idx_workspace_category,where:workspace_id IS NOT NULL" json:"workspace_id,omitempty"` // null = personal category
+	Workspace   *Workspace `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
+	Files       []File     `gorm:"many2many:file_categories;" json:"files,omitempty"`
+	ParentID    *uint      `gorm:"index" json:"parent_id,omitempty"` // null = root category
+	Children    []Category `gorm:"foreignKey:ParentID" json:"children,omitempty"`
 }
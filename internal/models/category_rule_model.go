@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// CategoryRule memasang kategori secara otomatis ke file yang cocok. Semua
+// kondisi yang diisi harus cocok; sebuah kategori cocok jika salah satu
+// rule-nya cocok. Rule hanya berlaku di scope kategorinya (file personal
+// pemilik kategori, atau file di workspace kategori tersebut).
+type CategoryRule struct {
+	gorm.Model
+	CategoryID  uint     `gorm:"not null;index" json:"category_id"`
+	Category    Category `gorm:"foreignKey:CategoryID" json:"-"`
+	MimeType    string   `json:"mime_type,omitempty"` // "application/pdf" atau "image/*"
+	NamePattern string   `json:"name_pattern,omitempty"`
+	PatternType string   `gorm:"not null;default:'glob'" json:"pattern_type"`
+	MinSize     *int64   `json:"min_size,omitempty"`
+	MaxSize     *int64   `json:"max_size,omitempty"`
+	UploaderID  *uint    `json:"uploader_id,omitempty"`
+	CreatedBy   uint     `gorm:"not null" json:"created_by"`
+}
+
+const (
+	RulePatternGlob  = "glob"
+	RulePatternRegex = "regex"
+)
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DataExport is a ZIP archive of everything stored about a user, built in the
+// background after the user requests it.
+type DataExport struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"not null;default:'pending'" json:"status"`
+	Filepath    string     `json:"-"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+}
+
+const (
+	ExportStatusPending    = "pending"
+	ExportStatusProcessing = "processing"
+	ExportStatusReady      = "ready"
+	ExportStatusFailed     = "failed"
+)
+
+// Policies for workspaces owned by a user who deletes their account.
+const (
+	OwnedWorkspaceTransfer = "transfer"
+	OwnedWorkspaceDelete   = "delete"
+)
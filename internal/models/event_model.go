@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Event is a file or membership change kept for the event stream, so that
+// clients can resume after a reconnect with Last-Event-ID. A row without a
+// WorkspaceID belongs to the personal files of OwnerID.
+type Event struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	EventID     string    `gorm:"not null" json:"event_id"` // evt_..., sama dengan id di payload
+	Type        string    `gorm:"not null" json:"type"`
+	OwnerID     uint      `gorm:"index" json:"owner_id,omitempty"`
+	WorkspaceID *uint     `gorm:"index" json:"workspace_id,omitempty"`
+	Payload     string    `gorm:"type:jsonb;not null" json:"-"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FileArchive is a ZIP of several files that is built in the background when a
+// client asks for the async mode of an archive download. Status uses the
+// ExportStatus* values.
+type FileArchive struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	WorkspaceID *uint      `json:"workspace_id,omitempty"`
+	FileCount   int        `json:"file_count"`
+	Status      string     `gorm:"not null;default:'pending'" json:"status"`
+	Filepath    string     `json:"-"`
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FileMetadata hasil ekstraksi EXIF/IPTC saat upload, disimpan sebagai jsonb.
+// Width/Height adalah ukuran tampilan (sudah memperhitungkan Orientation).
+type FileMetadata struct {
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	Orientation  int        `json:"orientation,omitempty"` // nilai EXIF 1-8
+	CameraMake   string     `json:"camera_make,omitempty"`
+	CameraModel  string     `json:"camera_model,omitempty"`
+	LensModel    string     `json:"lens_model,omitempty"`
+	FocalLength  string     `json:"focal_length,omitempty"`
+	FNumber      string     `json:"f_number,omitempty"`
+	ExposureTime string     `json:"exposure_time,omitempty"`
+	ISO          int        `json:"iso,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	GPS          *GPSInfo   `json:"gps,omitempty"`
+
+	Title     string   `json:"title,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+	Creator   string   `json:"creator,omitempty"`
+	Copyright string   `json:"copyright,omitempty"`
+	City      string   `json:"city,omitempty"`
+	Country   string   `json:"country,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+
+	// true jika GPS dan data personal dihapus dari file yang disimpan
+	Stripped bool `json:"stripped,omitempty"`
+}
+
+type GPSInfo struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+func (m FileMetadata) Value() (driver.Value, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (m *FileMetadata) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into FileMetadata", value)
+}
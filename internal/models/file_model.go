@@ -8,15 +8,47 @@ import (
 
 type File struct {
 	gorm.Model
-	Filename    string      `gorm:"not null" json:"filename"`
-	Filepath    string      `gorm:"not null" json:"filepath"`
-	Mimetype    string      `gorm:"not null" json:"mimetype"`
-	Size        int64       `gorm:"not null" json:"size"`
-	UploadedAt  time.Time   `gorm:"autoCreateTime" json:"uploaded_at"`
-	UserID      uint        `gorm:"not null" json:"user_id"`
-	User        User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	WorkspaceID *uint       `gorm:"index" json:"workspace_id,omitempty"` // null = personal file
-	Workspace   *Workspace  `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
-	Categories  []Category  `gorm:"many2many:file_categories;" json:"categories,omitempty"`
-	Shares      []FileShare `gorm:"foreignKey:FileID" json:"shares,omitempty"`
+	Filename string `gorm:"not null" json:"filename"`
+	// nama file dari client; Filename berisi nama uuid di disk
+	OriginalName string      `json:"original_name"`
+	Filepath     string      `gorm:"not null" json:"filepath"`
+	Mimetype     string      `gorm:"not null" json:"mimetype"`
+	Size         int64       `gorm:"not null" json:"size"`
+	UploadedAt   time.Time   `gorm:"autoCreateTime" json:"uploaded_at"`
+	UserID       uint        `gorm:"not null" json:"user_id"`
+	User         User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	WorkspaceID  *uint       `gorm:"index" json:"workspace_id,omitempty"` // null = personal file
+	Workspace    *Workspace  `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
+	Categories   []Category  `gorm:"many2many:file_categories;" json:"categories,omitempty"`
+	Shares       []FileShare `gorm:"foreignKey:FileID" json:"shares,omitempty"`
+
+	// workspace asal file yang dipindah ke personal space saat workspace dihapus
+	RestoreWorkspaceID *uint `gorm:"index" json:"-"`
+	// uploader asli file tersebut, dikembalikan saat workspace di-restore
+	RestoreUserID *uint `json:"-"`
+
+	// EXIF/IPTC gambar, null untuk file lain
+	Metadata *FileMetadata `gorm:"type:jsonb" json:"metadata,omitempty"`
+
+	// hasil scan malware; file infected dipindah ke folder karantina
+	ScanStatus    string     `gorm:"not null;default:'pending';index" json:"scan_status"`
+	ScanSignature string     `json:"scan_signature,omitempty"`
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
 }
+
+// DisplayName adalah nama file yang dikenal user. File lama yang belum
+// punya OriginalName memakai Filename.
+func (f *File) DisplayName() string {
+	if f.OriginalName != "" {
+		return f.OriginalName
+	}
+	return f.Filename
+}
+
+// Scan statuses
+const (
+	ScanPending  = "pending"  // belum discan (atau scanner tidak aktif)
+	ScanClean    = "clean"    // tidak ditemukan malware
+	ScanInfected = "infected" // malware ditemukan, blob dikarantina
+	ScanError    = "error"    // scan gagal, dicoba ulang secara berkala
+)
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index" json:"user_id"`
+	User     User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Provider string `gorm:"not null;uniqueIndex:idx_provider_subject" json:"provider"` // issuer URL
+	Subject  string `gorm:"not null;uniqueIndex:idx_provider_subject" json:"subject"`
+	Email    string `json:"email"`
+}
+
+// OIDCState holds the per-login values (state, nonce, PKCE verifier) between
+// the redirect to the identity provider and the callback.
+type OIDCState struct {
+	gorm.Model
+	State        string    `gorm:"not null;uniqueIndex" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null" json:"expires_at"`
+}
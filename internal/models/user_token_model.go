@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserToken is a single-use token sent to the user by email. Only the sha256
+// hash of the token is stored.
+type UserToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	Purpose   string     `gorm:"not null;index" json:"purpose"`
+	Email     string     `json:"email"` // target address for email verification
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
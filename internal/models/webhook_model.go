@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookSubscription sends events to a URL. A subscription without a
+// WorkspaceID receives the events of its user's personal files; one with a
+// WorkspaceID receives the events of that workspace.
+type WebhookSubscription struct {
+	gorm.Model
+	UserID      uint   `gorm:"not null;index" json:"user_id"`
+	WorkspaceID *uint  `gorm:"index" json:"workspace_id,omitempty"`
+	URL         string `gorm:"not null" json:"url"`
+	Secret      string `gorm:"not null" json:"-"`
+	Events      string `gorm:"not null" json:"-"` // dipisah koma
+	Active      bool   `gorm:"not null;default:true" json:"active"`
+}
+
+// EventList returns the subscribed event types.
+func (s *WebhookSubscription) EventList() []string {
+	if s.Events == "" {
+		return nil
+	}
+	return strings.Split(s.Events, ",")
+}
+
+// Handles reports whether the subscription wants events of this type.
+func (s *WebhookSubscription) Handles(event string) bool {
+	for _, e := range s.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the log entry of one event sent to one subscription.
+// Attempts, response and error describe the latest attempt.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        string     `gorm:"not null;index" json:"event_id"`
+	Event          string     `gorm:"not null" json:"event"`
+	Payload        string     `gorm:"type:jsonb;not null" json:"-"`
+	Status         string     `gorm:"not null;default:'pending'" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `json:"response_body,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	DurationMs     int64      `json:"duration_ms"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Event types
+const (
+	EventFileUploaded  = "file.uploaded"
+	EventFileDeleted   = "file.deleted"
+	EventFileRenamed   = "file.renamed"
+	EventFileMoved     = "file.moved"
+	EventMemberAdded   = "workspace.member_added"
+	EventMemberRemoved = "workspace.member_removed"
+
+	// dikirim oleh endpoint test, tidak bisa di-subscribe
+	EventPing = "ping"
+
+	// hanya di event stream: event yang terlewat tidak bisa dikirim ulang
+	EventStreamReset = "stream.reset"
+)
+
+// WebhookEvents are the event types a subscription can choose from.
+var WebhookEvents = []string{
+	EventFileUploaded,
+	EventFileDeleted,
+	EventFileRenamed,
+	EventFileMoved,
+	EventMemberAdded,
+	EventMemberRemoved,
+}
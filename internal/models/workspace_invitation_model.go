@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkspaceInvitation invites an email address (registered or not) to join a
+// workspace with the given role.
+type WorkspaceInvitation struct {
+	gorm.Model
+	WorkspaceID uint       `gorm:"not null;index" json:"workspace_id"`
+	Workspace   Workspace  `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
+	Email       string     `gorm:"not null;index" json:"email"`
+	InviteeID   *uint      `gorm:"index" json:"invitee_id,omitempty"` // set once the email belongs to an account
+	Role        string     `gorm:"not null;default:'viewer'" json:"role"`
+	InvitedByID uint       `gorm:"not null" json:"invited_by_id"`
+	InvitedBy   User       `gorm:"foreignKey:InvitedByID" json:"invited_by,omitempty"`
+	TokenHash   string     `gorm:"not null;uniqueIndex" json:"-"`
+	Status      string     `gorm:"not null;default:'pending';index" json:"status"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+)
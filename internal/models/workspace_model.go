@@ -15,8 +15,24 @@ type Workspace struct {
 	Members     []User            `gorm:"many2many:workspace_members;" json:"members,omitempty"`
 	Files       []File            `gorm:"foreignKey:WorkspaceID" json:"files,omitempty"`
 	Memberships []WorkspaceMember `gorm:"foreignKey:WorkspaceID" json:"memberships,omitempty"`
+
+	// diisi saat workspace dihapus; setelah PurgeAt workspace dihapus permanen
+	FilePolicy string     `json:"file_policy,omitempty"`
+	PurgeAt    *time.Time `gorm:"index" json:"purge_at,omitempty"`
+
+	// hapus GPS dan EXIF personal dari gambar yang disimpan di workspace ini
+	StripImageMetadata bool `gorm:"not null;default:false" json:"strip_image_metadata"`
+
+	// download diblokir sampai file dinyatakan bersih oleh scanner malware
+	RequireCleanScan bool `gorm:"not null;default:false" json:"require_clean_scan"`
 }
 
+// File policies when deleting a workspace
+const (
+	WorkspaceFilesMove  = "move"  // files go to the owner's personal space
+	WorkspaceFilesTrash = "trash" // files are trashed together with the workspace
+)
+
 type WorkspaceMember struct {
 	gorm.Model
 	WorkspaceID uint      `gorm:"not null;uniqueIndex:idx_workspace_user" json:"workspace_id"`
@@ -28,9 +44,42 @@ type WorkspaceMember struct {
 	JoinedAt time.Time `gorm:"autoCreateTime" json:"joined_at"`
 }
 
+// OwnershipTransfer is a pending hand-over of a workspace from its owner to
+// another member. It only takes effect once the receiving member accepts.
+type OwnershipTransfer struct {
+	gorm.Model
+	WorkspaceID uint       `gorm:"not null;index" json:"workspace_id"`
+	FromUserID  uint       `gorm:"not null" json:"from_user_id"`
+	FromUser    User       `gorm:"foreignKey:FromUserID" json:"from_user,omitempty"`
+	ToUserID    uint       `gorm:"not null;index" json:"to_user_id"`
+	ToUser      User       `gorm:"foreignKey:ToUserID" json:"to_user,omitempty"`
+	Status      string     `gorm:"not null;default:'pending'" json:"status"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+)
+
 const (
 	RoleOwner  = "owner"
 	RoleAdmin  = "admin"
 	RoleEditor = "editor"
 	RoleViewer = "viewer"
 )
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// RoleAtLeast reports whether role grants at least the permissions of min.
+func RoleAtLeast(role string, min string) bool {
+	return roleRank[role] >= roleRank[min]
+}
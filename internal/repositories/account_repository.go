@@ -0,0 +1,328 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+	"vasvault/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AccountExportData is everything stored about a single user.
+type AccountExportData struct {
+	User        models.User
+	Files       []models.File
+	Categories  []models.Category
+	Memberships []models.WorkspaceMember
+	Shares      []models.FileShare
+	PublicLinks []models.PublicLink
+}
+
+type AccountRepositoryInterface interface {
+	CreateExport(export *models.DataExport) error
+	UpdateExport(export *models.DataExport) error
+	FindExport(userID uint, exportID uint) (*models.DataExport, error)
+	FindUnfinishedExport(userID uint) (*models.DataExport, error)
+	DeleteExpiredExports(userID uint) ([]string, error)
+	LoadExportData(userID uint) (*AccountExportData, error)
+	DeleteAccount(userID uint, workspacePolicy string) ([]string, error)
+}
+
+type AccountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+func (r *AccountRepository) CreateExport(export *models.DataExport) error {
+	return r.db.Create(export).Error
+}
+
+func (r *AccountRepository) UpdateExport(export *models.DataExport) error {
+	return r.db.Save(export).Error
+}
+
+func (r *AccountRepository) FindExport(userID uint, exportID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	if err := r.db.Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *AccountRepository) FindUnfinishedExport(userID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.Where("user_id = ? AND status IN ?", userID, []string{models.ExportStatusPending, models.ExportStatusProcessing}).
+		Order("created_at desc").
+		First(&export).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// DeleteExpiredExports menghapus export yang sudah kadaluarsa dan mengembalikan path zip-nya
+func (r *AccountRepository) DeleteExpiredExports(userID uint) ([]string, error) {
+	var exports []models.DataExport
+	if err := r.db.Where("user_id = ? AND expires_at < ?", userID, time.Now()).Find(&exports).Error; err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range exports {
+		if e.Filepath != "" {
+			paths = append(paths, e.Filepath)
+		}
+		if err := r.db.Unscoped().Delete(&e).Error; err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
+
+func (r *AccountRepository) LoadExportData(userID uint) (*AccountExportData, error) {
+	data := &AccountExportData{}
+
+	if err := r.db.First(&data.User, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Preload("Categories").Where("user_id = ?", userID).Find(&data.Files).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Find(&data.Categories).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Preload("Workspace").Where("user_id = ?", userID).Find(&data.Memberships).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("shared_by_user_id = ? OR shared_with_user_id = ?", userID, userID).Find(&data.Shares).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("created_by = ?", userID).Find(&data.PublicLinks).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DeleteAccount menghapus semua data user dalam satu transaksi dan mengembalikan
+// path blob yang harus dihapus dari disk setelah transaksi berhasil.
+//
+// Workspace milik user diserahkan ke member lain (policy transfer) atau dihapus
+// beserta file-nya (policy delete, atau transfer tanpa member lain). File yang
+// user upload ke workspace lain tetap ada dan dipindahkan ke owner workspace.
+// Baris user sendiri dianonimkan lalu di-soft-delete supaya foreign key tetap valid.
+func (r *AccountRepository) DeleteAccount(userID uint, workspacePolicy string) ([]string, error) {
+	var blobs []string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// termasuk workspace yang sedang dalam grace period penghapusan
+		var owned []models.Workspace
+		if err := tx.Unscoped().Where("owner_id = ?", userID).Find(&owned).Error; err != nil {
+			return err
+		}
+
+		for _, ws := range owned {
+			if workspacePolicy == models.OwnedWorkspaceTransfer && !ws.DeletedAt.Valid {
+				transferred, err := transferWorkspace(tx, ws, userID)
+				if err != nil {
+					return err
+				}
+				if transferred {
+					continue
+				}
+			}
+
+			var files []models.File
+			if err := tx.Unscoped().Where("workspace_id = ?", ws.ID).Find(&files).Error; err != nil {
+				return err
+			}
+			paths, err := purgeFiles(tx, files)
+			if err != nil {
+				return err
+			}
+			blobs = append(blobs, paths...)
+
+			if err := tx.Exec("DELETE FROM file_categories WHERE category_id IN (SELECT id FROM categories WHERE workspace_id = ?)", ws.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM category_rules WHERE category_id IN (SELECT id FROM categories WHERE workspace_id = ?)", ws.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("workspace_id = ?", ws.ID).Delete(&models.Category{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("workspace_id = ?", ws.ID).Delete(&models.WorkspaceMember{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("workspace_id = ?", ws.ID).Delete(&models.WorkspaceInvitation{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("workspace_id = ?", ws.ID).Delete(&models.OwnershipTransfer{}).Error; err != nil {
+				return err
+			}
+			if err := purgeWebhooks(tx, "workspace_id = ?", ws.ID); err != nil {
+				return err
+			}
+			if err := tx.Where("workspace_id = ?", ws.ID).Delete(&models.Event{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&models.Workspace{}, ws.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		// file di workspace yang masih ada dipindah ke owner workspace tersebut
+		if err := tx.Exec(`UPDATE files SET user_id = workspaces.owner_id
+			FROM workspaces
+			WHERE files.workspace_id = workspaces.id AND files.user_id = ?`, userID).Error; err != nil {
+			return err
+		}
+
+		var personal []models.File
+		if err := tx.Unscoped().Where("user_id = ?", userID).Find(&personal).Error; err != nil {
+			return err
+		}
+		paths, err := purgeFiles(tx, personal)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, paths...)
+
+		// kategori workspace yang dibuat user tetap milik workspace
+		if err := tx.Exec("DELETE FROM file_categories WHERE category_id IN (SELECT id FROM categories WHERE user_id = ? AND workspace_id IS NULL)", userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM category_rules WHERE category_id IN (SELECT id FROM categories WHERE user_id = ? AND workspace_id IS NULL)", userID).Error; err != nil {
+			return err
+		}
+		// rule workspace yang memakai user ini sebagai kondisi uploader tidak berlaku lagi
+		if err := tx.Unscoped().Where("uploader_id = ?", userID).Delete(&models.CategoryRule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ? AND workspace_id IS NULL", userID).Delete(&models.Category{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("shared_by_user_id = ? OR shared_with_user_id = ?", userID, userID).Delete(&models.FileShare{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("created_by = ?", userID).Delete(&models.PublicLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("invited_by_id = ? OR invitee_id = ?", userID, userID).Delete(&models.WorkspaceInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("from_user_id = ? OR to_user_id = ?", userID, userID).Delete(&models.OwnershipTransfer{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := purgeWebhooks(tx, "user_id = ? AND workspace_id IS NULL", userID); err != nil {
+			return err
+		}
+		if err := tx.Where("owner_id = ? AND workspace_id IS NULL", userID).Delete(&models.Event{}).Error; err != nil {
+			return err
+		}
+
+		var exports []models.DataExport
+		if err := tx.Unscoped().Where("user_id = ?", userID).Find(&exports).Error; err != nil {
+			return err
+		}
+		for _, e := range exports {
+			if e.Filepath != "" {
+				blobs = append(blobs, e.Filepath)
+			}
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.DataExport{}).Error; err != nil {
+			return err
+		}
+
+		var archives []models.FileArchive
+		if err := tx.Unscoped().Where("user_id = ?", userID).Find(&archives).Error; err != nil {
+			return err
+		}
+		for _, a := range archives {
+			if a.Filepath != "" {
+				blobs = append(blobs, a.Filepath)
+			}
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.FileArchive{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":    fmt.Sprintf("deleted-user-%d", userID),
+			"email":       fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+			"password":    "",
+			"verified_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// transferWorkspace menyerahkan workspace ke member dengan role tertinggi
+// (admin, editor, viewer), yang paling lama bergabung lebih dulu
+func transferWorkspace(tx *gorm.DB, ws models.Workspace, userID uint) (bool, error) {
+	var successor models.WorkspaceMember
+	err := tx.Where("workspace_id = ? AND user_id <> ?", ws.ID, userID).
+		Order(gorm.Expr("CASE role WHEN ? THEN 0 WHEN ? THEN 1 WHEN ? THEN 2 ELSE 3 END", models.RoleAdmin, models.RoleEditor, models.RoleViewer)).
+		Order("joined_at asc").
+		First(&successor).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Model(&successor).Update("role", models.RoleOwner).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&models.Workspace{}).Where("id = ?", ws.ID).Update("owner_id", successor.UserID).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// purgeFiles menghapus permanen file beserta relasinya, mengembalikan path blob
+func purgeFiles(tx *gorm.DB, files []models.File) ([]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(files))
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, f.ID)
+		paths = append(paths, f.Filepath)
+	}
+
+	if err := tx.Exec("DELETE FROM file_categories WHERE file_id IN ?", ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("file_id IN ?", ids).Delete(&models.FileShare{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("file_id IN ?", ids).Delete(&models.PublicLink{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Delete(&models.File{}, ids).Error; err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
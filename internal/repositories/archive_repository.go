@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"time"
+	"vasvault/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type ArchiveRepositoryInterface interface {
+	FindFiles(ids []uint) ([]models.File, error)
+	ListWorkspaceFiles(workspaceID uint) ([]models.File, error)
+	SharedFileIDs(userID uint, fileIDs []uint) (map[uint]bool, error)
+	CreateArchive(archive *models.FileArchive) error
+	UpdateArchive(archive *models.FileArchive) error
+	FindArchive(userID uint, archiveID uint) (*models.FileArchive, error)
+	DeleteExpiredArchives(userID uint) ([]string, error)
+}
+
+type ArchiveRepository struct {
+	db *gorm.DB
+}
+
+func NewArchiveRepository(db *gorm.DB) *ArchiveRepository {
+	return &ArchiveRepository{db: db}
+}
+
+func (r *ArchiveRepository) FindFiles(ids []uint) ([]models.File, error) {
+	var files []models.File
+	if err := r.db.Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *ArchiveRepository) ListWorkspaceFiles(workspaceID uint) ([]models.File, error) {
+	var files []models.File
+	if err := r.db.Where("workspace_id = ?", workspaceID).Order("id").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// SharedFileIDs mengembalikan file yang dibagikan ke user dengan izin download atau edit
+func (r *ArchiveRepository) SharedFileIDs(userID uint, fileIDs []uint) (map[uint]bool, error) {
+	var ids []uint
+	err := r.db.Model(&models.FileShare{}).
+		Where("shared_with_user_id = ? AND file_id IN ?", userID, fileIDs).
+		Where("permission IN ?", []string{models.PermissionDownload, models.PermissionEdit}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Pluck("file_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shared := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		shared[id] = true
+	}
+	return shared, nil
+}
+
+func (r *ArchiveRepository) CreateArchive(archive *models.FileArchive) error {
+	return r.db.Create(archive).Error
+}
+
+func (r *ArchiveRepository) UpdateArchive(archive *models.FileArchive) error {
+	return r.db.Save(archive).Error
+}
+
+func (r *ArchiveRepository) FindArchive(userID uint, archiveID uint) (*models.FileArchive, error) {
+	var archive models.FileArchive
+	if err := r.db.Where("id = ? AND user_id = ?", archiveID, userID).First(&archive).Error; err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// DeleteExpiredArchives menghapus arsip yang sudah kadaluarsa dan mengembalikan path zip-nya
+func (r *ArchiveRepository) DeleteExpiredArchives(userID uint) ([]string, error) {
+	var archives []models.FileArchive
+	if err := r.db.Where("user_id = ? AND expires_at < ?", userID, time.Now()).Find(&archives).Error; err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, a := range archives {
+		if a.Filepath != "" {
+			paths = append(paths, a.Filepath)
+		}
+		if err := r.db.Unscoped().Delete(&a).Error; err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
@@ -1,9 +1,12 @@
 package repositories
 
 import (
+	"vasvault/internal/dto"
 	"vasvault/internal/models"
+	apperrors "vasvault/pkg/utils"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CategoryRepository struct {
@@ -20,10 +23,23 @@ func (r *CategoryRepository) Create(category *models.Category) error {
 
 func (r *CategoryRepository) GetByName(userID uint, name string) (*models.Category, error) {
 	var category models.Category
-	err := r.db.Where("user_id = ? AND name = ?", userID, name).First(&category).Error
+	err := r.db.Where("user_id = ? AND workspace_id IS NULL AND name = ?", userID, name).First(&category).Error
 	return &category, err
 }
 
+func (r *CategoryRepository) GetByNameInWorkspace(workspaceID uint, name string) (*models.Category, error) {
+	var category models.Category
+	err := r.db.Where("workspace_id = ? AND name = ?", workspaceID, name).First(&category).Error
+	return &category, err
+}
+
+// GetByIDs mengambil beberapa kategori sekaligus (untuk validasi assignment)
+func (r *CategoryRepository) GetByIDs(ids []uint) ([]models.Category, error) {
+	var categories []models.Category
+	err := r.db.Where("id IN ?", ids).Find(&categories).Error
+	return categories, err
+}
+
 func (r *CategoryRepository) GetByID(id uint) (*models.Category, error) {
 	var category models.Category
 	err := r.db.First(&category, id).Error
@@ -43,7 +59,7 @@ func (r *CategoryRepository) GetByIDAndUser(userID uint, id uint) (*models.Categ
 func (r *CategoryRepository) List(userID uint, search string) ([]models.Category, error) {
 	var categories []models.Category
 
-	query := r.db.Where("user_id = ?", userID)
+	query := r.db.Where("user_id = ? AND workspace_id IS NULL", userID)
 
 	// Optional search
 	if search != "" {
@@ -52,13 +68,37 @@ func (r *CategoryRepository) List(userID uint, search string) ([]models.Category
 
 	// Default sort: latest
 	err := query.Order("created_at DESC").
-		Select("id, name, color, created_at, updated_at").
+		Select("id, name, color, parent_id, created_at, updated_at").
+		Find(&categories).Error
+
+	return categories, err
+}
+
+// ListByWorkspace mengembalikan kategori milik workspace
+func (r *CategoryRepository) ListByWorkspace(workspaceID uint, search string) ([]models.Category, error) {
+	var categories []models.Category
+
+	query := r.db.Where("workspace_id = ?", workspaceID)
+
+	if search != "" {
+		query = query.Where("name LIKE ?", "%"+search+"%")
+	}
+
+	err := query.Order("created_at DESC").
+		Select("id, name, color, user_id, workspace_id, parent_id, created_at, updated_at").
 		Find(&categories).Error
 
 	return categories, err
 }
 
 
+// FindByIDWithFiles tanpa filter user; akses dicek di service sesuai scope
+func (r *CategoryRepository) FindByIDWithFiles(categoryID uint) (*models.Category, error) {
+	var category models.Category
+	err := r.db.Preload("Files").First(&category, categoryID).Error
+	return &category, err
+}
+
 //func findbyid
 func (r *CategoryRepository) FindByID(userID, categoryID uint) (*models.Category, error) {
 	var category models.Category
@@ -84,7 +124,15 @@ func (r *CategoryRepository) ExistsByName(
 ) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.Category{}).
-		Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID).
+		Where("user_id = ? AND workspace_id IS NULL AND name = ? AND id <> ?", userID, name, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *CategoryRepository) ExistsByNameInWorkspace(workspaceID uint, name string, excludeID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Category{}).
+		Where("workspace_id = ? AND name = ? AND id <> ?", workspaceID, name, excludeID).
 		Count(&count).Error
 	return count > 0, err
 }
@@ -96,6 +144,240 @@ func (r *CategoryRepository) Update(category *models.Category) error {
 
 //delete category
 func (r *CategoryRepository) Delete(category *models.Category) error {
-	return r.db.Delete(category).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("category_id = ?", category.ID).Delete(&models.CategoryRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
+
+// DescendantIDs mengembalikan id semua sub-kategori (rekursif), tanpa kategori itu sendiri
+func (r *CategoryRepository) DescendantIDs(categoryID uint) ([]uint, error) {
+	return descendantIDs(r.db, categoryID)
+}
+
+func descendantIDs(db *gorm.DB, categoryID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE parent_id = ? AND deleted_at IS NULL
+			UNION
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id WHERE c.deleted_at IS NULL
+		)
+		SELECT id FROM tree`, categoryID).Scan(&ids).Error
+	return ids, err
+}
+
+func (r *CategoryRepository) CountChildren(categoryID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Category{}).Where("parent_id = ?", categoryID).Count(&count).Error
+	return count, err
+}
+
+// Namespace advisory lock untuk Move, satu lock per scope kategori
+const (
+	categoryMoveUserLock      = 3501
+	categoryMoveWorkspaceLock = 3502
+)
+
+// Move memindahkan kategori ke parent baru (nil = root). Perpindahan dalam
+// satu scope (personal user atau workspace) diserialkan dengan advisory lock,
+// karena mengunci baris kategori dan parent saja tidak cukup: dua perpindahan
+// bersamaan di cabang berbeda tetap bisa membentuk siklus.
+func (r *CategoryRepository) Move(categoryID uint, parentID *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.First(&category, categoryID).Error; err != nil {
+			return err
+		}
+		namespace, scope := categoryMoveUserLock, category.UserID
+		if category.WorkspaceID != nil {
+			namespace, scope = categoryMoveWorkspaceLock, *category.WorkspaceID
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", namespace, int32(scope)).Error; err != nil {
+			return err
+		}
+
+		ids := []uint{categoryID}
+		if parentID != nil {
+			ids = append(ids, *parentID)
+		}
+		var locked []models.Category
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			if *parentID == categoryID {
+				return apperrors.ErrCategoryCycle
+			}
+			descendants, err := descendantIDs(tx, categoryID)
+			if err != nil {
+				return err
+			}
+			for _, id := range descendants {
+				if id == *parentID {
+					return apperrors.ErrCategoryCycle
+				}
+			}
+		}
+
+		return tx.Model(&models.Category{}).Where("id = ?", categoryID).Update("parent_id", parentID).Error
+	})
+}
+
+func (r *CategoryRepository) CreateRule(rule *models.CategoryRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *CategoryRepository) ListRules(categoryID uint) ([]models.CategoryRule, error) {
+	var rules []models.CategoryRule
+	err := r.db.Where("category_id = ?", categoryID).Order("created_at").Find(&rules).Error
+	return rules, err
+}
+
+func (r *CategoryRepository) GetRule(categoryID uint, ruleID uint) (*models.CategoryRule, error) {
+	var rule models.CategoryRule
+	err := r.db.Where("id = ? AND category_id = ?", ruleID, categoryID).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
 }
 
+func (r *CategoryRepository) DeleteRule(rule *models.CategoryRule) error {
+	return r.db.Unscoped().Delete(rule).Error
+}
+
+// RulesForScope mengembalikan semua rule dari kategori yang berlaku untuk file
+// personal milik userID (workspaceID nil) atau file di workspace tersebut
+func (r *CategoryRepository) RulesForScope(userID uint, workspaceID *uint) ([]models.CategoryRule, error) {
+	var rules []models.CategoryRule
+
+	query := r.db.Joins("JOIN categories ON categories.id = category_rules.category_id AND categories.deleted_at IS NULL")
+	if workspaceID == nil {
+		query = query.Where("categories.user_id = ? AND categories.workspace_id IS NULL", userID)
+	} else {
+		query = query.Where("categories.workspace_id = ?", *workspaceID)
+	}
+
+	err := query.Find(&rules).Error
+	return rules, err
+}
+
+// EachFileInScope memanggil fn per batch untuk semua file di scope kategori
+func (r *CategoryRepository) EachFileInScope(category *models.Category, batchSize int, fn func(files []models.File) error) error {
+	var files []models.File
+
+	query := r.db.Model(&models.File{})
+	if category.WorkspaceID == nil {
+		query = query.Where("user_id = ? AND workspace_id IS NULL", category.UserID)
+	} else {
+		query = query.Where("workspace_id = ?", *category.WorkspaceID)
+	}
+
+	return query.FindInBatches(&files, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(files)
+	}).Error
+}
+
+// AssignedFileIDs mengembalikan file (dari fileIDs) yang sudah punya kategori ini
+func (r *CategoryRepository) AssignedFileIDs(categoryID uint, fileIDs []uint) (map[uint]bool, error) {
+	var ids []uint
+	err := r.db.Table("file_categories").
+		Where("category_id = ? AND file_id IN ?", categoryID, fileIDs).
+		Pluck("file_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		assigned[id] = true
+	}
+	return assigned, nil
+}
+
+// AssignToFiles memasang kategori ke banyak file sekaligus, file yang sudah punya dilewati
+func (r *CategoryRepository) AssignToFiles(categoryID uint, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return r.db.Exec(`INSERT INTO file_categories (file_id, category_id)
+		SELECT id, ? FROM files WHERE id IN ?
+		ON CONFLICT DO NOTHING`, categoryID, fileIDs).Error
+}
+
+// Stats menghitung jumlah file dan total ukuran per kategori di satu scope
+func (r *CategoryRepository) Stats(userID uint, workspaceID *uint) ([]dto.CategoryStatResponse, error) {
+	var stats []dto.CategoryStatResponse
+
+	query := r.db.Table("categories").
+		Select(`categories.id AS category_id, categories.name, categories.color, categories.parent_id,
+			COUNT(files.id) AS file_count, COALESCE(SUM(files.size), 0) AS total_bytes`).
+		Joins("LEFT JOIN file_categories ON file_categories.category_id = categories.id").
+		Joins("LEFT JOIN files ON files.id = file_categories.file_id AND files.deleted_at IS NULL").
+		Where("categories.deleted_at IS NULL")
+
+	if workspaceID == nil {
+		query = query.Where("categories.user_id = ? AND categories.workspace_id IS NULL", userID)
+	} else {
+		query = query.Where("categories.workspace_id = ?", *workspaceID)
+	}
+
+	err := query.Group("categories.id").
+		Order("total_bytes DESC, categories.name").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// Merge memindahkan semua file, rule, dan sub-kategori dari source ke target
+// lalu menghapus source, dalam satu transaksi
+func (r *CategoryRepository) Merge(sourceID uint, targetID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var locked []models.Category
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", []uint{sourceID, targetID}).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+		if len(locked) != 2 {
+			return gorm.ErrRecordNotFound
+		}
+		var source models.Category
+		for _, c := range locked {
+			if c.ID == sourceID {
+				source = c
+			}
+		}
+
+		if err := tx.Exec(`INSERT INTO file_categories (file_id, category_id)
+			SELECT file_id, ? FROM file_categories WHERE category_id = ?
+			ON CONFLICT DO NOTHING`, targetID, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM file_categories WHERE category_id = ?", sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CategoryRule{}).Where("category_id = ?", sourceID).Update("category_id", targetID).Error; err != nil {
+			return err
+		}
+
+		// kalau target ada di bawah source, naikkan dulu supaya tidak terbentuk siklus
+		descendants, err := descendantIDs(tx, sourceID)
+		if err != nil {
+			return err
+		}
+		for _, id := range descendants {
+			if id == targetID {
+				if err := tx.Model(&models.Category{}).Where("id = ?", targetID).Update("parent_id", source.ParentID).Error; err != nil {
+					return err
+				}
+				break
+			}
+		}
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", sourceID).Update("parent_id", targetID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.Category{}, sourceID).Error
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"vasvault/internal/models"
+	"vasvault/pkg/jobqueue"
 
 	"os"
 
@@ -25,7 +26,13 @@ func Connect() (*DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("gagal terhubung ke database: %v", err)
 	}
-	if err := db.AutoMigrate(&models.User{}, &models.File{}, &models.FileShare{}, &models.Category{}, &models.PublicLink{}, &models.Workspace{}, &models.WorkspaceMember{}); err != nil {
+	// idx_user_category diganti index parsial per scope (personal / workspace)
+	if db.Migrator().HasIndex(&models.Category{}, "idx_user_category") {
+		if err := db.Migrator().DropIndex(&models.Category{}, "idx_user_category"); err != nil {
+			log.Printf("Gagal menghapus index lama: %v", err)
+		}
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.File{}, &models.FileShare{}, &models.Category{}, &models.PublicLink{}, &models.Workspace{}, &models.WorkspaceMember{}, &models.UserToken{}, &models.UserIdentity{}, &models.OIDCState{}, &models.DataExport{}, &models.WorkspaceInvitation{}, &models.OwnershipTransfer{}, &models.CategoryRule{}, &models.FileArchive{}, &models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.Event{}, &jobqueue.Job{}); err != nil {
 		log.Printf("Gagal melakukan migrasi: %v", err)
 		return &DB{db}, err
 	}
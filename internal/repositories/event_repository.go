@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+	"vasvault/internal/models"
+	"vasvault/pkg/pgnotify"
+
+	"gorm.io/gorm"
+)
+
+// EventChannel adalah channel NOTIFY untuk event baru; payload-nya id event
+const EventChannel = "vasvault_events"
+
+type EventRepositoryInterface interface {
+	Create(event *models.Event) error
+	FindByID(id uint) (*models.Event, error)
+	Exists(id uint) (bool, error)
+	MaxID() (uint, error)
+	ListAfter(afterID uint, overlap time.Duration, limit int) ([]models.Event, error)
+	ListVisibleAfter(afterID uint, overlap time.Duration, userID uint, workspaceIDs []uint, limit int) ([]models.Event, error)
+	DeleteBefore(t time.Time) (int64, error)
+	Listen(ctx context.Context, connected func(), notify func(id uint))
+}
+
+type EventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// Create menyimpan event dan mengirim NOTIFY dalam satu transaksi, jadi
+// listener baru menerima id setelah row-nya bisa dibaca
+func (r *EventRepository) Create(event *models.Event) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		return tx.Exec("SELECT pg_notify(?, ?)", EventChannel, strconv.FormatUint(uint64(event.ID), 10)).Error
+	})
+}
+
+func (r *EventRepository) FindByID(id uint) (*models.Event, error) {
+	var event models.Event
+	if err := r.db.First(&event, id).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *EventRepository) Exists(id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Event{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *EventRepository) MaxID() (uint, error) {
+	var id uint
+	err := r.db.Model(&models.Event{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// ListAfter: semua event setelah afterID, untuk listener yang tertinggal.
+// Lihat afterScope untuk overlap.
+func (r *EventRepository) ListAfter(afterID uint, overlap time.Duration, limit int) ([]models.Event, error) {
+	var events []models.Event
+	err := r.db.Where(r.afterScope(afterID, overlap)).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// ListVisibleAfter: event setelah afterID untuk file personal userID dan
+// workspace yang diikutinya
+func (r *EventRepository) ListVisibleAfter(afterID uint, overlap time.Duration, userID uint, workspaceIDs []uint, limit int) ([]models.Event, error) {
+	var events []models.Event
+	scope := r.db.Where("workspace_id IS NULL AND owner_id = ?", userID)
+	if len(workspaceIDs) > 0 {
+		scope = scope.Or("workspace_id IN ?", workspaceIDs)
+	}
+	err := r.db.Where(r.afterScope(afterID, overlap)).Where(scope).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// afterScope: event dengan id > afterID, ditambah event dengan id lebih kecil
+// yang dibuat paling lama overlap sebelum event afterID. Id dibagikan saat
+// insert, bukan saat commit, jadi event dengan id lebih kecil bisa baru
+// terlihat setelah event afterID. Event di jendela overlap bisa terkirim dua
+// kali; pemanggil membuang duplikat berdasarkan id.
+func (r *EventRepository) afterScope(afterID uint, overlap time.Duration) *gorm.DB {
+	return r.db.Where("id > ?", afterID).
+		Or("id < ? AND created_at >= (SELECT created_at FROM events WHERE id = ?) - make_interval(secs => ?)",
+			afterID, afterID, overlap.Seconds())
+}
+
+func (r *EventRepository) DeleteBefore(t time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", t).Delete(&models.Event{})
+	return result.RowsAffected, result.Error
+}
+
+// Listen menunggu NOTIFY di EventChannel sampai ctx selesai, dengan koneksi
+// sendiri dari pool dan reconnect otomatis
+func (r *EventRepository) Listen(ctx context.Context, connected func(), notify func(id uint)) {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		log.Printf("events: cannot listen for notifications: %v", err)
+		return
+	}
+	pgnotify.Listen(ctx, sqlDB, EventChannel, pgnotify.Handler{
+		Connected: connected,
+		Notify: func(payload string) {
+			if id, err := strconv.ParseUint(payload, 10, 64); err == nil {
+				notify(uint(id))
+			}
+		},
+	})
+}
@@ -1,20 +1,26 @@
 package repositories
 
 import (
+	"time"
 	"vasvault/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type FileRepositoryInterface interface {
 	Create(file *models.File) error
+	CreateWithCategories(file *models.File, categoryIDs []uint) error
 	FindByID(id uint) (*models.File, error)
 	FindByIDWithCategories(id uint) (*models.File, error)
 	Update(file *models.File) error
+	UpdateMetadata(fileID uint, metadata *models.FileMetadata, size int64) error
+	UpdateScanResult(fileID uint, status, signature, path string) error
+	ListUnscanned(limit int) ([]models.File, error)
 	ListUserFiles(userID uint) ([]models.File, error)
 	ListUserFilesWithCategories(userID uint) ([]models.File, error)
 	ListFilesByWorkspaceWithCategories(workspaceID uint) ([]models.File, error)
-	ListUserFilesWithOptionalCategory(userID uint, categoryID *uint) ([]models.File, error)
+	ListUserFilesWithOptionalCategory(userID uint, categoryIDs []uint) ([]models.File, error)
 	Delete(fileID uint) error
 	AssignCategories(fileID uint, categoryIDs []uint) error
 	RemoveCategories(fileID uint, categoryIDs []uint) error
@@ -22,6 +28,17 @@ type FileRepositoryInterface interface {
 	TotalUserStorage(userID uint) (int64, error)
 	GetLatestFileForUser(userID uint) (*models.File, error)
 	GetLatestFilesForUser(userID uint, limit int) ([]models.File, error)
+	FindByIDs(ids []uint) ([]models.File, error)
+	BulkDelete(fileIDs []uint) error
+	BulkMove(fileIDs []uint, workspaceID *uint, userID uint) error
+	BulkMoveFolder(fileIDs []uint, fromCategoryID *uint, toCategoryID uint) error
+	CopyFile(sourceID uint, file *models.File) error
+	IsSharedWith(fileID uint, userID uint) (bool, error)
+	BulkAssignCategories(fileIDs []uint, categoryIDs []uint) error
+	BulkRemoveCategories(fileIDs []uint, categoryIDs []uint) error
+	ListBlobPaths() ([]string, error)
+	ListFilesAfter(afterID uint, limit int) ([]models.File, error)
+	PurgeFiles(fileIDs []uint) error
 }
 
 type FileRepository struct {
@@ -32,6 +49,33 @@ func (r *FileRepository) Create(file *models.File) error {
 	return r.db.Create(file).Error
 }
 
+// CreateWithCategories menyimpan file dan kategorinya dalam satu transaksi.
+// Kategori yang sudah dihapus sejak divalidasi membatalkan seluruh insert.
+func (r *FileRepository) CreateWithCategories(file *models.File, categoryIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		if len(categoryIDs) == 0 {
+			return nil
+		}
+
+		var categories []models.Category
+		if err := tx.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+			return err
+		}
+		if len(categories) != len(categoryIDs) {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Exec(`INSERT INTO file_categories (file_id, category_id)
+			SELECT ?, id FROM categories WHERE id IN ?`, file.ID, categoryIDs).Error; err != nil {
+			return err
+		}
+		file.Categories = categories
+		return nil
+	})
+}
+
 func NewFileRepository(db *gorm.DB) *FileRepository {
 	return &FileRepository{db: db}
 }
@@ -60,6 +104,32 @@ func (r *FileRepository) Update(file *models.File) error {
 	return r.db.Save(file).Error
 }
 
+func (r *FileRepository) UpdateMetadata(fileID uint, metadata *models.FileMetadata, size int64) error {
+	return r.db.Model(&models.File{}).Where("id = ?", fileID).
+		Updates(map[string]interface{}{"metadata": metadata, "size": size}).Error
+}
+
+// UpdateScanResult menyimpan hasil scan; path berubah jika blob dikarantina
+func (r *FileRepository) UpdateScanResult(fileID uint, status, signature, path string) error {
+	return r.db.Model(&models.File{}).Where("id = ?", fileID).
+		Updates(map[string]interface{}{
+			"scan_status":    status,
+			"scan_signature": signature,
+			"scanned_at":     time.Now(),
+			"filepath":       path,
+		}).Error
+}
+
+// ListUnscanned: file yang masih menunggu scan malware atau scan-nya gagal.
+// File pending didahulukan.
+func (r *FileRepository) ListUnscanned(limit int) ([]models.File, error) {
+	var files []models.File
+	err := r.db.Where("scan_status IN ?", []string{models.ScanPending, models.ScanError}).
+		Order(clause.Expr{SQL: "scan_status = ?, id", Vars: []interface{}{models.ScanError}}).
+		Limit(limit).Find(&files).Error
+	return files, err
+}
+
 func (r *FileRepository) ListUserFiles(userID uint) ([]models.File, error) {
 	var files []models.File
 	if err := r.db.Where("user_id = ?", userID).Find(&files).Error; err != nil {
@@ -120,12 +190,13 @@ func (r *FileRepository) ClearAllCategories(fileID uint) error {
 	return r.db.Model(&file).Association("Categories").Clear()
 }
 
-func (r *FileRepository) ListUserFilesWithOptionalCategory(userID uint, categoryID *uint) ([]models.File, error) {
+// ListUserFilesWithOptionalCategory memfilter file yang punya salah satu dari categoryIDs (nil = tanpa filter)
+func (r *FileRepository) ListUserFilesWithOptionalCategory(userID uint, categoryIDs []uint) ([]models.File, error) {
 	var files []models.File
 	query := r.db.Preload("Categories").Where("user_id = ?", userID)
 
-	if categoryID != nil {
-		query = query.Joins("JOIN file_categories ON file_categories.file_id = files.id").Where("file_categories.category_id = ?", *categoryID)
+	if categoryIDs != nil {
+		query = query.Where("files.id IN (SELECT file_id FROM file_categories WHERE category_id IN ?)", categoryIDs)
 	}
 	if err := query.Find(&files).Error; err != nil {
 		return nil, err
@@ -164,3 +235,135 @@ func (r *FileRepository) GetLatestFilesForUser(userID uint, limit int) ([]models
 	}
 	return files, nil
 }
+
+func (r *FileRepository) FindByIDs(ids []uint) ([]models.File, error) {
+	var files []models.File
+	if err := r.db.Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// BulkDelete meng-soft-delete banyak file sekaligus
+func (r *FileRepository) BulkDelete(fileIDs []uint) error {
+	return r.db.Delete(&models.File{}, fileIDs).Error
+}
+
+// BulkMove memindahkan file ke workspace lain (nil = personal space milik userID).
+// Kategori dibawa ke kategori bernama sama di scope tujuan, sisanya dilepas.
+// Uploader (user_id) tidak berubah.
+func (r *FileRepository) BulkMove(fileIDs []uint, workspaceID *uint, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		scope, args := targetCategoryScope(workspaceID, userID)
+
+		carry := append([]interface{}{}, args...)
+		carry = append(carry, fileIDs)
+		if err := tx.Exec(`INSERT INTO file_categories (file_id, category_id)
+			SELECT fc.file_id, target.id FROM file_categories fc
+			JOIN categories source ON source.id = fc.category_id
+			JOIN categories target ON LOWER(target.name) = LOWER(source.name) AND target.deleted_at IS NULL AND `+scope+`
+			WHERE fc.file_id IN ?
+			ON CONFLICT DO NOTHING`, carry...).Error; err != nil {
+			return err
+		}
+
+		keep := append([]interface{}{fileIDs}, args...)
+		if err := tx.Exec(`DELETE FROM file_categories WHERE file_id IN ?
+			AND category_id NOT IN (SELECT target.id FROM categories target WHERE `+scope+`)`, keep...).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.File{}).Where("id IN ?", fileIDs).Update("workspace_id", workspaceID).Error
+	})
+}
+
+// BulkMoveFolder memindahkan file dari satu kategori ke kategori lain
+// (fromCategoryID nil = hanya menambahkan ke kategori tujuan)
+func (r *FileRepository) BulkMoveFolder(fileIDs []uint, fromCategoryID *uint, toCategoryID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if fromCategoryID != nil && *fromCategoryID != toCategoryID {
+			if err := tx.Exec("DELETE FROM file_categories WHERE file_id IN ? AND category_id = ?", fileIDs, *fromCategoryID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec(`INSERT INTO file_categories (file_id, category_id)
+			SELECT files.id, ? FROM files WHERE files.id IN ?
+			ON CONFLICT DO NOTHING`, toCategoryID, fileIDs).Error
+	})
+}
+
+// CopyFile menyimpan salinan file beserta kategori yang bernama sama di scope tujuan
+func (r *FileRepository) CopyFile(sourceID uint, file *models.File) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+
+		scope, args := targetCategoryScope(file.WorkspaceID, file.UserID)
+		carry := append([]interface{}{file.ID}, args...)
+		carry = append(carry, sourceID)
+		return tx.Exec(`INSERT INTO file_categories (file_id, category_id)
+			SELECT ?, target.id FROM file_categories fc
+			JOIN categories source ON source.id = fc.category_id
+			JOIN categories target ON LOWER(target.name) = LOWER(source.name) AND target.deleted_at IS NULL AND `+scope+`
+			WHERE fc.file_id = ?
+			ON CONFLICT DO NOTHING`, carry...).Error
+	})
+}
+
+// targetCategoryScope: kondisi SQL untuk kategori yang boleh dipakai di scope tujuan
+func targetCategoryScope(workspaceID *uint, userID uint) (string, []interface{}) {
+	if workspaceID == nil {
+		return "target.workspace_id IS NULL AND target.user_id = ?", []interface{}{userID}
+	}
+	return "target.workspace_id = ?", []interface{}{*workspaceID}
+}
+
+// IsSharedWith: file dibagikan ke user dengan izin download atau edit yang masih berlaku
+func (r *FileRepository) IsSharedWith(fileID uint, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.FileShare{}).
+		Where("file_id = ? AND shared_with_user_id = ?", fileID, userID).
+		Where("permission IN ?", []string{models.PermissionDownload, models.PermissionEdit}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *FileRepository) BulkAssignCategories(fileIDs []uint, categoryIDs []uint) error {
+	return r.db.Exec(`INSERT INTO file_categories (file_id, category_id)
+		SELECT files.id, categories.id FROM files CROSS JOIN categories
+		WHERE files.id IN ? AND categories.id IN ?
+		ON CONFLICT DO NOTHING`, fileIDs, categoryIDs).Error
+}
+
+func (r *FileRepository) BulkRemoveCategories(fileIDs []uint, categoryIDs []uint) error {
+	return r.db.Exec("DELETE FROM file_categories WHERE file_id IN ? AND category_id IN ?", fileIDs, categoryIDs).Error
+}
+
+// ListBlobPaths: path blob semua file, termasuk yang sudah di-soft-delete
+// (blob workspace yang dihapus disimpan sampai purge)
+func (r *FileRepository) ListBlobPaths() ([]string, error) {
+	var paths []string
+	err := r.db.Unscoped().Model(&models.File{}).Pluck("filepath", &paths).Error
+	return paths, err
+}
+
+// ListFilesAfter: halaman file aktif berdasarkan id, untuk iterasi seluruh tabel
+func (r *FileRepository) ListFilesAfter(afterID uint, limit int) ([]models.File, error) {
+	var files []models.File
+	err := r.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// PurgeFiles menghapus permanen file beserta kategori, share dan public link-nya
+func (r *FileRepository) PurgeFiles(fileIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var files []models.File
+		if err := tx.Unscoped().Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
+			return err
+		}
+		_, err := purgeFiles(tx, files)
+		return err
+	})
+}
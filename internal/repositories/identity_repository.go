@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"time"
+	"vasvault/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type IdentityRepositoryInterface interface {
+	FindByProviderSubject(provider string, subject string) (*models.UserIdentity, error)
+	Create(identity *models.UserIdentity) error
+	CreateState(state *models.OIDCState) error
+	ConsumeState(state string) (*models.OIDCState, error)
+}
+
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) FindByProviderSubject(provider string, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) Create(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *IdentityRepository) CreateState(state *models.OIDCState) error {
+	// sekalian bersihkan state lama yang tidak pernah dipakai
+	r.db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OIDCState{})
+	return r.db.Create(state).Error
+}
+
+// ConsumeState mengambil dan menghapus state, jadi state hanya bisa dipakai sekali
+func (r *IdentityRepository) ConsumeState(state string) (*models.OIDCState, error) {
+	var found models.OIDCState
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ? AND expires_at > ?", state, time.Now()).First(&found).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id = ?", found.ID).Delete(&models.OIDCState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
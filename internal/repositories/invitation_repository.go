@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"time"
+	"vasvault/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type InvitationRepository interface {
+	Create(invitation *models.WorkspaceInvitation) error
+	Update(invitation *models.WorkspaceInvitation) error
+	FindByID(id uint) (*models.WorkspaceInvitation, error)
+	FindPending(workspaceID uint, email string) (*models.WorkspaceInvitation, error)
+	ListPendingByWorkspace(workspaceID uint) ([]models.WorkspaceInvitation, error)
+	ListPendingForUser(userID uint, email string) ([]models.WorkspaceInvitation, error)
+	ClaimForUser(userID uint, email string) error
+	Accept(invitation *models.WorkspaceInvitation, member *models.WorkspaceMember) error
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(invitation *models.WorkspaceInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *invitationRepository) Update(invitation *models.WorkspaceInvitation) error {
+	return r.db.Save(invitation).Error
+}
+
+func (r *invitationRepository) FindByID(id uint) (*models.WorkspaceInvitation, error) {
+	var invitation models.WorkspaceInvitation
+	err := r.db.Preload("Workspace").Preload("InvitedBy").First(&invitation, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) FindPending(workspaceID uint, email string) (*models.WorkspaceInvitation, error) {
+	var invitation models.WorkspaceInvitation
+	err := r.db.Where("workspace_id = ? AND LOWER(email) = LOWER(?) AND status = ?", workspaceID, email, models.InvitationPending).
+		First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) ListPendingByWorkspace(workspaceID uint) ([]models.WorkspaceInvitation, error) {
+	var invitations []models.WorkspaceInvitation
+	err := r.db.Preload("InvitedBy").
+		Where("workspace_id = ? AND status = ? AND expires_at > ?", workspaceID, models.InvitationPending, time.Now()).
+		Order("created_at desc").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *invitationRepository) ListPendingForUser(userID uint, email string) ([]models.WorkspaceInvitation, error) {
+	var invitations []models.WorkspaceInvitation
+	err := r.db.Preload("Workspace").Preload("InvitedBy").
+		Where("(invitee_id = ? OR LOWER(email) = LOWER(?)) AND status = ? AND expires_at > ?", userID, email, models.InvitationPending, time.Now()).
+		Order("created_at desc").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// ClaimForUser menghubungkan undangan untuk email yang belum terdaftar ke akun baru
+func (r *invitationRepository) ClaimForUser(userID uint, email string) error {
+	return r.db.Model(&models.WorkspaceInvitation{}).
+		Where("LOWER(email) = LOWER(?) AND status = ? AND invitee_id IS NULL", email, models.InvitationPending).
+		Update("invitee_id", userID).Error
+}
+
+func (r *invitationRepository) Accept(invitation *models.WorkspaceInvitation, member *models.WorkspaceMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return tx.Save(invitation).Error
+	})
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"time"
+	"vasvault/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenRepositoryInterface interface {
+	Create(token *models.UserToken) error
+	FindActiveByHash(hash string, purpose string) (*models.UserToken, error)
+	MarkUsed(token *models.UserToken) error
+	InvalidateForUser(userID uint, purpose string) error
+}
+
+type UserTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+func (r *UserTokenRepository) Create(token *models.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindActiveByHash hanya mengembalikan token yang belum dipakai dan belum expired
+func (r *UserTokenRepository) FindActiveByHash(hash string, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.Preload("User").
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hash, purpose, time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed menandai token sudah dipakai. Gagal jika token sudah dipakai oleh request lain
+func (r *UserTokenRepository) MarkUsed(token *models.UserToken) error {
+	now := time.Now()
+	result := r.db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	token.UsedAt = &now
+	return nil
+}
+
+func (r *UserTokenRepository) InvalidateForUser(userID uint, purpose string) error {
+	return r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
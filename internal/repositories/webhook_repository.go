@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"time"
+	"vasvault/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepositoryInterface interface {
+	Create(sub *models.WebhookSubscription) error
+	Update(sub *models.WebhookSubscription) error
+	Delete(id uint) error
+	FindByID(id uint) (*models.WebhookSubscription, error)
+	ListPersonal(userID uint) ([]models.WebhookSubscription, error)
+	ListWorkspace(workspaceID uint) ([]models.WebhookSubscription, error)
+	FindSubscribers(ownerID uint, workspaceID *uint) ([]models.WebhookSubscription, error)
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+	FindDelivery(id uint) (*models.WebhookDelivery, error)
+	ListDeliveries(subscriptionID uint, limit int) ([]models.WebhookDelivery, error)
+	DeleteDeliveriesBefore(t time.Time) (int64, error)
+}
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(sub *models.WebhookSubscription) error {
+	return r.db.Create(sub).Error
+}
+
+// Update menyimpan url, event dan status aktif; Select dipakai supaya
+// Active=false tidak dilewati sebagai zero value
+func (r *WebhookRepository) Update(sub *models.WebhookSubscription) error {
+	return r.db.Model(sub).Select("UserID", "URL", "Events", "Active").Updates(sub).Error
+}
+
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.db.Delete(&models.WebhookSubscription{}, id).Error
+}
+
+func (r *WebhookRepository) FindByID(id uint) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	if err := r.db.First(&sub, id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *WebhookRepository) ListPersonal(userID uint) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	err := r.db.Where("user_id = ? AND workspace_id IS NULL", userID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (r *WebhookRepository) ListWorkspace(workspaceID uint) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	err := r.db.Where("workspace_id = ?", workspaceID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// FindSubscribers: subscription aktif untuk event di workspace, atau untuk
+// file personal ownerID jika workspaceID nil
+func (r *WebhookRepository) FindSubscribers(ownerID uint, workspaceID *uint) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	db := r.db.Where("active = ?", true)
+	if workspaceID != nil {
+		db = db.Where("workspace_id = ?", *workspaceID)
+	} else {
+		db = db.Where("user_id = ? AND workspace_id IS NULL", ownerID)
+	}
+	err := db.Find(&subs).Error
+	return subs, err
+}
+
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *WebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+func (r *WebhookRepository) FindDelivery(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *WebhookRepository) ListDeliveries(subscriptionID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Where("subscription_id = ?", subscriptionID).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *WebhookRepository) DeleteDeliveriesBefore(t time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", t).Delete(&models.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+// purgeWebhooks menghapus permanen subscription yang cocok beserta log pengirimannya
+func purgeWebhooks(tx *gorm.DB, query string, args ...interface{}) error {
+	var ids []uint
+	if err := tx.Unscoped().Model(&models.WebhookSubscription{}).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("subscription_id IN ?", ids).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.WebhookSubscription{}, ids).Error
+}
@@ -1,9 +1,12 @@
 package repositories
 
 import (
+	"errors"
+	"time"
 	"vasvault/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WorkspaceRepository interface {
@@ -12,10 +15,20 @@ type WorkspaceRepository interface {
 	FindByID(workspaceID uint) (*models.Workspace, error)
 	Update(workspace *models.Workspace) error
 	Delete(id uint) error
+	DeleteWithPolicy(workspaceID uint, filePolicy string, purgeAt time.Time) error
+	FindDeletedByOwner(ownerID uint) ([]models.Workspace, error)
+	Restore(workspaceID uint, ownerID uint) (*models.Workspace, error)
+	FindPurgeable(now time.Time) ([]models.Workspace, error)
+	Purge(workspaceID uint) ([]string, error)
 	AddMember(member *models.WorkspaceMember) error
 	UpdateMember(member *models.WorkspaceMember) error
 	RemoveMember(workspaceID uint, userID uint) error
 	FindMember(workspaceID uint, userID uint) (*models.WorkspaceMember, error)
+	MemberWorkspaceIDs(userID uint) ([]uint, error)
+	CreateTransfer(transfer *models.OwnershipTransfer) error
+	UpdateTransfer(transfer *models.OwnershipTransfer) error
+	FindPendingTransfer(workspaceID uint) (*models.OwnershipTransfer, error)
+	CompleteTransfer(transfer *models.OwnershipTransfer) error
 }
 
 type workspaceRepository struct {
@@ -81,13 +94,189 @@ func (r *workspaceRepository) FindByID(id uint) (*models.Workspace, error) {
 }
 
 func (r *workspaceRepository) Update(workspace *models.Workspace) error {
-	return r.db.Model(workspace).Select("Name", "Description").Updates(workspace).Error
+	return r.db.Model(workspace).Select("Name", "Description", "StripImageMetadata", "RequireCleanScan").Updates(workspace).Error
 }
 
 func (r *workspaceRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Workspace{}, id).Error
 }
 
+// DeleteWithPolicy menghapus workspace (soft delete) dalam satu transaksi.
+// Semua baris yang ikut dihapus memakai timestamp yang sama dengan workspace
+// supaya Restore bisa mengembalikan persis baris-baris tersebut.
+func (r *workspaceRepository) DeleteWithPolicy(workspaceID uint, filePolicy string, purgeAt time.Time) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var workspace models.Workspace
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&workspace, workspaceID).Error; err != nil {
+			return err
+		}
+
+		switch filePolicy {
+		case models.WorkspaceFilesMove:
+			if err := tx.Model(&models.File{}).Where("workspace_id = ?", workspaceID).Updates(map[string]interface{}{
+				"workspace_id":         nil,
+				"user_id":              workspace.OwnerID,
+				"restore_workspace_id": workspaceID,
+				"restore_user_id":      gorm.Expr("user_id"),
+			}).Error; err != nil {
+				return err
+			}
+		case models.WorkspaceFilesTrash:
+			if err := tx.Model(&models.File{}).Where("workspace_id = ?", workspaceID).Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+		default:
+			return errors.New("invalid file policy")
+		}
+
+		if err := tx.Model(&models.WorkspaceMember{}).Where("workspace_id = ?", workspaceID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.WorkspaceInvitation{}).
+			Where("workspace_id = ? AND status = ?", workspaceID, models.InvitationPending).
+			Update("status", models.InvitationRevoked).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.OwnershipTransfer{}).
+			Where("workspace_id = ? AND status = ?", workspaceID, models.TransferPending).
+			Updates(map[string]interface{}{"status": models.TransferCancelled, "responded_at": now}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&workspace).Updates(map[string]interface{}{
+			"file_policy": filePolicy,
+			"purge_at":    purgeAt,
+			"deleted_at":  now,
+		}).Error
+	})
+}
+
+func (r *workspaceRepository) FindDeletedByOwner(ownerID uint) ([]models.Workspace, error) {
+	var workspaces []models.Workspace
+	err := r.db.Unscoped().
+		Where("owner_id = ? AND deleted_at IS NOT NULL AND purge_at > ?", ownerID, time.Now()).
+		Order("deleted_at desc").
+		Find(&workspaces).Error
+	return workspaces, err
+}
+
+// Restore mengembalikan workspace yang masih dalam grace period beserta
+// member dan file yang ikut terhapus bersamanya
+func (r *workspaceRepository) Restore(workspaceID uint, ownerID uint) (*models.Workspace, error) {
+	var workspace models.Workspace
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND owner_id = ? AND deleted_at IS NOT NULL AND purge_at > ?", workspaceID, ownerID, time.Now()).
+			First(&workspace).Error; err != nil {
+			return err
+		}
+		deletedAt := workspace.DeletedAt.Time
+
+		if err := tx.Unscoped().Model(&models.WorkspaceMember{}).
+			Where("workspace_id = ? AND deleted_at = ?", workspaceID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if workspace.FilePolicy == models.WorkspaceFilesTrash {
+			if err := tx.Unscoped().Model(&models.File{}).
+				Where("workspace_id = ? AND deleted_at = ?", workspaceID, deletedAt).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		} else {
+			// file yang sudah dihapus owner dari personal space tidak ikut kembali
+			if err := tx.Model(&models.File{}).
+				Where("restore_workspace_id = ? AND workspace_id IS NULL", workspaceID).
+				Updates(map[string]interface{}{
+					"workspace_id":         workspaceID,
+					"user_id":              gorm.Expr("COALESCE(restore_user_id, user_id)"),
+					"restore_workspace_id": nil,
+					"restore_user_id":      nil,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Model(&workspace).Updates(map[string]interface{}{
+			"file_policy": "",
+			"purge_at":    nil,
+			"deleted_at":  nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("Owner").First(&workspace, workspaceID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+func (r *workspaceRepository) FindPurgeable(now time.Time) ([]models.Workspace, error) {
+	var workspaces []models.Workspace
+	err := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND purge_at <= ?", now).
+		Find(&workspaces).Error
+	return workspaces, err
+}
+
+// Purge menghapus permanen workspace yang grace period-nya sudah habis dan
+// mengembalikan path blob yang harus dihapus dari disk
+func (r *workspaceRepository) Purge(workspaceID uint) ([]string, error) {
+	var blobs []string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var files []models.File
+		if err := tx.Unscoped().Where("workspace_id = ?", workspaceID).Find(&files).Error; err != nil {
+			return err
+		}
+		paths, err := purgeFiles(tx, files)
+		if err != nil {
+			return err
+		}
+		blobs = paths
+
+		if err := tx.Model(&models.File{}).Where("restore_workspace_id = ?", workspaceID).
+			Updates(map[string]interface{}{"restore_workspace_id": nil, "restore_user_id": nil}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM file_categories WHERE category_id IN (SELECT id FROM categories WHERE workspace_id = ?)", workspaceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM category_rules WHERE category_id IN (SELECT id FROM categories WHERE workspace_id = ?)", workspaceID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workspace_id = ?", workspaceID).Delete(&models.Category{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workspace_id = ?", workspaceID).Delete(&models.WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workspace_id = ?", workspaceID).Delete(&models.WorkspaceInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workspace_id = ?", workspaceID).Delete(&models.OwnershipTransfer{}).Error; err != nil {
+			return err
+		}
+		if err := purgeWebhooks(tx, "workspace_id = ?", workspaceID); err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ?", workspaceID).Delete(&models.Event{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Workspace{}, workspaceID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
 func (r *workspaceRepository) AddMember(member *models.WorkspaceMember) error {
 	return r.db.Create(member).Error
 }
@@ -105,3 +294,68 @@ func (r *workspaceRepository) FindMember(workspaceID uint, userID uint) (*models
 	err := r.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error
 	return &member, err
 }
+
+// MemberWorkspaceIDs: id workspace aktif (tidak di trash) yang diikuti user
+func (r *workspaceRepository) MemberWorkspaceIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.WorkspaceMember{}).
+		Joins("JOIN workspaces ON workspaces.id = workspace_members.workspace_id AND workspaces.deleted_at IS NULL").
+		Where("workspace_members.user_id = ?", userID).
+		Pluck("workspace_members.workspace_id", &ids).Error
+	return ids, err
+}
+
+func (r *workspaceRepository) CreateTransfer(transfer *models.OwnershipTransfer) error {
+	return r.db.Create(transfer).Error
+}
+
+func (r *workspaceRepository) UpdateTransfer(transfer *models.OwnershipTransfer) error {
+	return r.db.Save(transfer).Error
+}
+
+func (r *workspaceRepository) FindPendingTransfer(workspaceID uint) (*models.OwnershipTransfer, error) {
+	var transfer models.OwnershipTransfer
+	err := r.db.Preload("FromUser").Preload("ToUser").
+		Where("workspace_id = ? AND status = ? AND expires_at > ?", workspaceID, models.TransferPending, time.Now()).
+		Order("created_at desc").
+		First(&transfer).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// CompleteTransfer menukar role owner dan admin lalu mengganti OwnerID dalam satu transaksi
+func (r *workspaceRepository) CompleteTransfer(transfer *models.OwnershipTransfer) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var workspace models.Workspace
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&workspace, transfer.WorkspaceID).Error; err != nil {
+			return err
+		}
+		if workspace.OwnerID != transfer.FromUserID {
+			return errors.New("workspace owner has changed")
+		}
+
+		result := tx.Model(&models.WorkspaceMember{}).
+			Where("workspace_id = ? AND user_id = ?", transfer.WorkspaceID, transfer.ToUserID).
+			Update("role", models.RoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("receiving user is no longer a member")
+		}
+
+		if err := tx.Model(&models.WorkspaceMember{}).
+			Where("workspace_id = ? AND user_id = ?", transfer.WorkspaceID, transfer.FromUserID).
+			Update("role", models.RoleAdmin).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&workspace).Update("owner_id", transfer.ToUserID).Error; err != nil {
+			return err
+		}
+
+		return tx.Save(transfer).Error
+	})
+}
@@ -1,40 +1,143 @@
 package routes
 
 import (
+	"log"
+	"os"
+	"time"
 	"vasvault/internal/handlers"
 	"vasvault/internal/middleware"
 	"vasvault/internal/repositories"
 	"vasvault/internal/services"
+	"vasvault/pkg/jobqueue"
+	"vasvault/pkg/mailer"
+	"vasvault/pkg/oidc"
+	"vasvault/pkg/ratelimit"
+	"vasvault/pkg/scanner"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 func InitRoutes(r *gin.Engine, db *gorm.DB) {
+	mail, err := mailer.NewFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure mailer: %v", err)
+	}
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:8080"
+	}
+	limitStore, err := ratelimit.NewStoreFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure rate limit store: %v", err)
+	}
+
+	// Background jobs, override workers per queue with e.g. JOB_CONCURRENCY=thumbnails=4,scan=2
+	jobQueue := jobqueue.New(db)
+	jobQueue.SetConcurrency(jobqueue.DefaultQueue, 2)
+	jobQueue.SetConcurrency("thumbnails", 2)
+	jobQueue.SetConcurrency("scan", 2)
+	jobQueue.SetConcurrency("exports", 1)
+	jobQueue.SetConcurrency("webhooks", 2)
+	jobQueue.ConcurrencyFromEnv("JOB_CONCURRENCY")
+
 	userRepo := repositories.NewUserRepository(db)
-	userService := services.NewUserService(userRepo)
-	userHandler := handlers.NewUserHandler(userService)
+	userTokenRepo := repositories.NewUserTokenRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	userService := services.NewUserService(userRepo, userTokenRepo, invitationRepo, mail, appURL)
+	userHandler := handlers.NewUserHandler(userService, ratelimit.NewLockout(limitStore))
 
 	fileRepo := repositories.NewFileRepository(db)
 	workspaceRepo := repositories.NewWorkspaceRepository(db)
-	fileService := services.NewFileService(fileRepo, workspaceRepo, "./uploads")
+	categoryRepo := repositories.NewCategoryRepository(db)
+	thumbnailService := services.NewThumbnailService(fileRepo, jobQueue)
+	imageService := services.NewImageService("./uploads")
+	malwareScanner, err := scanner.NewFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure malware scanner: %v", err)
+	}
+	scanService, err := services.NewScanService(malwareScanner, fileRepo, jobQueue, "./quarantine")
+	if err != nil {
+		log.Fatalf("failed to configure malware scanning: %v", err)
+	}
+	if _, err := services.NewReconcileService(fileRepo, jobQueue, "./uploads", "./quarantine"); err != nil {
+		log.Fatalf("failed to configure storage reconciliation: %v", err)
+	}
+	webhookRepo := repositories.NewWebhookRepository(db)
+	webhookService, err := services.NewWebhookService(webhookRepo, workspaceRepo, jobQueue)
+	if err != nil {
+		log.Fatalf("failed to configure webhooks: %v", err)
+	}
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+
+	// event bus: setiap event disimpan untuk GET /events lalu diteruskan ke webhook
+	eventRepo := repositories.NewEventRepository(db)
+	eventService, err := services.NewEventService(eventRepo, workspaceRepo, jobQueue, webhookService)
+	if err != nil {
+		log.Fatalf("failed to configure event stream: %v", err)
+	}
+	eventHandler := handlers.NewEventHandler(eventService)
+
+	fileService := services.NewFileService(fileRepo, workspaceRepo, categoryRepo, thumbnailService, imageService, scanService, eventService, "./uploads")
 	fileHandler := handlers.NewFileHandler(fileService)
 
+	archiveRepo := repositories.NewArchiveRepository(db)
+	archiveService := services.NewArchiveService(archiveRepo, workspaceRepo, "./exports/archives", jobQueue)
+	archiveHandler := handlers.NewArchiveHandler(archiveService)
+
 	// Category module
-	categoryRepo := repositories.NewCategoryRepository(db)
-	categoryService := services.NewCategoryService(categoryRepo)
+	categoryService := services.NewCategoryService(categoryRepo, workspaceRepo)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 
-	workspaceService := services.NewWorkspaceService(workspaceRepo, userRepo)
+	accountRepo := repositories.NewAccountRepository(db)
+	accountService := services.NewAccountService(accountRepo, userRepo, "./exports", jobQueue)
+	accountHandler := handlers.NewAccountHandler(accountService)
+
+	// SSO is only enabled when OIDC_ISSUER is set
+	var ssoHandler *handlers.SSOHandler
+	if oidcConfig, ok := oidc.ConfigFromEnv(); ok {
+		identityRepo := repositories.NewIdentityRepository(db)
+		ssoService := services.NewSSOService(oidc.NewProvider(oidcConfig), userRepo, identityRepo, os.Getenv("OIDC_AUTO_PROVISION") != "false")
+		ssoHandler = handlers.NewSSOHandler(ssoService)
+	}
+
+	workspaceService, err := services.NewWorkspaceService(workspaceRepo, userRepo, invitationRepo, mail, appURL, jobQueue, eventService)
+	if err != nil {
+		log.Fatalf("failed to configure workspace purge: %v", err)
+	}
 	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
 
+	// all job types are registered by now
+	jobQueue.Start()
+	eventService.Start()
+	jobHandler := handlers.NewJobHandler(jobQueue)
+
+	// Rate limits, override with e.g. RATE_LIMIT_LOGIN=20/m
+	loginLimit := middleware.GinRateLimit(limitStore, "login", ratelimit.LimitFromEnv("RATE_LIMIT_LOGIN", ratelimit.Per(10, time.Minute)), middleware.KeyByIP)
+	registerLimit := middleware.GinRateLimit(limitStore, "register", ratelimit.LimitFromEnv("RATE_LIMIT_REGISTER", ratelimit.Per(5, time.Minute)), middleware.KeyByIP)
+	refreshLimit := middleware.GinRateLimit(limitStore, "refresh", ratelimit.LimitFromEnv("RATE_LIMIT_REFRESH", ratelimit.Per(30, time.Minute)), middleware.KeyByIP)
+	accountLimit := middleware.GinRateLimit(limitStore, "account", ratelimit.LimitFromEnv("RATE_LIMIT_ACCOUNT", ratelimit.Per(5, time.Minute)), middleware.KeyByIP)
+	uploadLimit := middleware.GinRateLimit(limitStore, "upload", ratelimit.LimitFromEnv("RATE_LIMIT_UPLOAD", ratelimit.Per(60, time.Minute)), middleware.KeyByUser)
+	// one bucket per API client across all of the routes above; it runs
+	// first so the route's own (tighter) headers are the ones returned
+	clientLimit := middleware.GinRateLimit(limitStore, "client", ratelimit.LimitFromEnv("RATE_LIMIT_CLIENT", ratelimit.Per(600, time.Minute)), middleware.KeyByAPIClient)
+
+	r.GET("/.well-known/jwks.json", handlers.JWKS)
+
 	// API v1 routes
 	apiV1 := r.Group("/api/v1")
 	{
 		// Public routes
-		apiV1.POST("/login", userHandler.Login)
-		apiV1.POST("/register", userHandler.Register)
-		apiV1.POST("/refresh", userHandler.Refresh)
+		apiV1.POST("/login", clientLimit, loginLimit, userHandler.Login)
+		apiV1.POST("/register", clientLimit, registerLimit, userHandler.Register)
+		apiV1.POST("/refresh", clientLimit, refreshLimit, userHandler.Refresh)
+		apiV1.POST("/password/forgot", clientLimit, accountLimit, userHandler.ForgotPassword)
+		apiV1.POST("/password/reset", clientLimit, accountLimit, userHandler.ResetPassword)
+		apiV1.POST("/email/verify", clientLimit, accountLimit, userHandler.VerifyEmail)
+		if ssoHandler != nil {
+			apiV1.GET("/auth/oidc/login", loginLimit, ssoHandler.Login)
+			apiV1.GET("/auth/oidc/callback", loginLimit, ssoHandler.Callback)
+		}
 
 		// Protected routes (require API key + Bearer token)
 		protected := apiV1.Group("")
@@ -42,21 +145,40 @@ func InitRoutes(r *gin.Engine, db *gorm.DB) {
 		{
 			protected.GET("/me", userHandler.Me)
 			protected.PUT("/profile", userHandler.UpdateProfile)
+			protected.DELETE("/me", accountHandler.DeleteAccount)
+			protected.POST("/me/export", accountHandler.RequestExport)
+			protected.GET("/me/exports/:id", accountHandler.GetExport)
+			protected.GET("/me/exports/:id/download", accountHandler.DownloadExport)
+			protected.POST("/email/verify/resend", userHandler.ResendVerification)
 
 			// Category endpoints
 			protected.POST("/categories", categoryHandler.Create)
 			protected.GET("/categories", categoryHandler.List)
+			protected.GET("/categories/stats", categoryHandler.Stats)
 			protected.GET("/categories/:id", categoryHandler.Detail)
 			protected.PUT("/categories/:id", categoryHandler.Update)
 			protected.DELETE("/categories/:id", categoryHandler.Delete)
+			protected.POST("/categories/:id/move", categoryHandler.Move)
+			protected.POST("/categories/:id/merge", categoryHandler.Merge)
+			protected.GET("/categories/:id/rules", categoryHandler.ListRules)
+			protected.POST("/categories/:id/rules", categoryHandler.CreateRule)
+			protected.DELETE("/categories/:id/rules/:ruleId", categoryHandler.DeleteRule)
+			protected.POST("/categories/:id/rules/apply", categoryHandler.ApplyRules)
 
-			protected.POST("/files", fileHandler.Upload)
+			protected.POST("/files", clientLimit, uploadLimit, fileHandler.Upload)
 			protected.GET("/files", fileHandler.ListMyFiles)
+			protected.POST("/files/bulk", fileHandler.BulkAction)
+			protected.POST("/files/archive", archiveHandler.ArchiveFiles)
+			protected.GET("/files/archives/:id", archiveHandler.GetArchive)
+			protected.GET("/files/archives/:id/download", archiveHandler.DownloadArchive)
 			protected.GET("/files/:id", fileHandler.GetByID)
 			protected.DELETE("/files/:id", fileHandler.Delete)
 			protected.PUT("/files/:id", fileHandler.Rename)
+			protected.POST("/files/:id/move", fileHandler.Move)
+			protected.POST("/files/:id/copy", fileHandler.Copy)
 			protected.GET("/files/:id/download", fileHandler.Download)
 			protected.GET("/files/:id/thumbnail", fileHandler.Thumbnail)
+			protected.GET("/files/:id/image", fileHandler.Image)
 			protected.GET("/storage/summary", fileHandler.StorageSummary)
 
 			// File-Category Management
@@ -67,14 +189,53 @@ func InitRoutes(r *gin.Engine, db *gorm.DB) {
 			// Workspace
 			protected.POST("/workspaces", workspaceHandler.Create)
 			protected.GET("/workspaces", workspaceHandler.List)
+			protected.GET("/workspaces/deleted", workspaceHandler.ListDeleted)
 			protected.GET("/workspaces/:id", workspaceHandler.Detail)
 			protected.GET("/workspaces/:id/files", fileHandler.ListByWorkspace)
+			protected.GET("/workspaces/:id/archive", archiveHandler.ArchiveWorkspace)
 			protected.PUT("/workspaces/:id", workspaceHandler.Update)
 			protected.DELETE("/workspaces/:id", workspaceHandler.Delete)
+			protected.POST("/workspaces/:id/restore", workspaceHandler.Restore)
 			protected.POST("/workspaces/:id/members", workspaceHandler.AddMember)
 			protected.PUT("/workspaces/:id/members/:userId", workspaceHandler.UpdateMemberRole)
 			protected.DELETE("/workspaces/:id/members/:userId", workspaceHandler.RemoveMember)
+			protected.POST("/workspaces/:id/leave", workspaceHandler.Leave)
+			protected.POST("/workspaces/:id/transfer", workspaceHandler.TransferOwnership)
+			protected.GET("/workspaces/:id/transfer", workspaceHandler.PendingTransfer)
+			protected.DELETE("/workspaces/:id/transfer", workspaceHandler.CancelTransfer)
+			protected.POST("/workspaces/:id/transfer/accept", workspaceHandler.AcceptTransfer)
+			protected.POST("/workspaces/:id/transfer/decline", workspaceHandler.DeclineTransfer)
+			protected.POST("/workspaces/:id/invitations", workspaceHandler.Invite)
+			protected.GET("/workspaces/:id/invitations", workspaceHandler.ListInvitations)
+			protected.DELETE("/workspaces/:id/invitations/:invitationId", workspaceHandler.RevokeInvitation)
+
+			// Invitations for the current user
+			protected.GET("/invitations", workspaceHandler.MyInvitations)
+			protected.POST("/invitations/:id/accept", workspaceHandler.AcceptInvitation)
+			protected.POST("/invitations/:id/decline", workspaceHandler.DeclineInvitation)
+
+			// Real-time events (Server-Sent Events)
+			protected.GET("/events", eventHandler.Stream)
+
+			// Webhooks
+			protected.POST("/webhooks", webhookHandler.Create)
+			protected.GET("/webhooks", webhookHandler.List)
+			protected.GET("/webhooks/:id", webhookHandler.Detail)
+			protected.PUT("/webhooks/:id", webhookHandler.Update)
+			protected.DELETE("/webhooks/:id", webhookHandler.Delete)
+			protected.POST("/webhooks/:id/ping", webhookHandler.Ping)
+			protected.GET("/webhooks/:id/deliveries", webhookHandler.Deliveries)
 
 		}
+
+		// Admin routes (require x-admin-key matching ADMIN_API_KEY)
+		admin := apiV1.Group("/admin")
+		admin.Use(middleware.GinAdminAuth())
+		{
+			admin.GET("/jobs", jobHandler.List)
+			admin.GET("/jobs/stats", jobHandler.Stats)
+			admin.GET("/jobs/:id", jobHandler.Detail)
+			admin.POST("/jobs/:id/retry", jobHandler.Retry)
+		}
 	}
 }
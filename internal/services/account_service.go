@@ -0,0 +1,347 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const exportTTL = 7 * 24 * time.Hour
+
+type AccountServiceInterface interface {
+	RequestExport(userID uint) (*dto.DataExportResponse, error)
+	GetExport(userID uint, exportID uint) (*dto.DataExportResponse, error)
+	ExportFilePath(userID uint, exportID uint) (string, error)
+	DeleteAccount(userID uint, request dto.DeleteAccountRequest) error
+}
+
+type AccountService struct {
+	repository repositories.AccountRepositoryInterface
+	userRepo   repositories.UserRepositoryInterface
+	exportDir  string
+	exportJob  jobqueue.Type[exportJob]
+}
+
+type exportJob struct {
+	ExportID uint `json:"export_id"`
+	UserID   uint `json:"user_id"`
+}
+
+func NewAccountService(repo repositories.AccountRepositoryInterface, userRepo repositories.UserRepositoryInterface, exportDir string, jobs *jobqueue.Queue) AccountServiceInterface {
+	service := &AccountService{
+		repository: repo,
+		userRepo:   userRepo,
+		exportDir:  exportDir,
+	}
+	service.exportJob = jobqueue.Register(jobs, "build_export", jobqueue.HandlerOptions{
+		Queue:       "exports",
+		MaxAttempts: 3,
+		Timeout:     30 * time.Minute,
+	}, service.buildExport)
+	return service
+}
+
+// RequestExport membuat export baru dan memprosesnya di background.
+// Jika masih ada export yang sedang diproses, export tersebut yang dikembalikan.
+func (s *AccountService) RequestExport(userID uint) (*dto.DataExportResponse, error) {
+	if existing, err := s.repository.FindUnfinishedExport(userID); err == nil {
+		return toExportResponse(existing), nil
+	}
+
+	if paths, err := s.repository.DeleteExpiredExports(userID); err == nil {
+		removeBlobs(paths)
+	}
+
+	export := &models.DataExport{
+		UserID:    userID,
+		Status:    models.ExportStatusPending,
+		ExpiresAt: time.Now().Add(exportTTL),
+	}
+	if err := s.repository.CreateExport(export); err != nil {
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+
+	if _, err := s.exportJob.Enqueue(exportJob{ExportID: export.ID, UserID: userID}); err != nil {
+		export.Status = models.ExportStatusFailed
+		export.Error = "failed to schedule export"
+		if err := s.repository.UpdateExport(export); err != nil {
+			log.Printf("export %d: failed to update status: %v", export.ID, err)
+		}
+		return nil, fmt.Errorf("failed to schedule export: %w", err)
+	}
+
+	return toExportResponse(export), nil
+}
+
+func (s *AccountService) GetExport(userID uint, exportID uint) (*dto.DataExportResponse, error) {
+	export, err := s.repository.FindExport(userID, exportID)
+	if err != nil {
+		return nil, fmt.Errorf("export not found: %w", err)
+	}
+	return toExportResponse(export), nil
+}
+
+func (s *AccountService) ExportFilePath(userID uint, exportID uint) (string, error) {
+	export, err := s.repository.FindExport(userID, exportID)
+	if err != nil {
+		return "", fmt.Errorf("export not found: %w", err)
+	}
+	if export.Status != models.ExportStatusReady || time.Now().After(export.ExpiresAt) {
+		return "", apperrors.ErrExportNotReady
+	}
+	return export.Filepath, nil
+}
+
+func (s *AccountService) DeleteAccount(userID uint, request dto.DeleteAccountRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return apperrors.ErrUserNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
+		return apperrors.ErrInvalidCredentials
+	}
+
+	blobs, err := s.repository.DeleteAccount(userID, request.WorkspacePolicy)
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	// blob dihapus setelah commit; kalau gagal cukup di-log, datanya sudah tidak terhubung ke user
+	removeBlobs(blobs)
+	return nil
+}
+
+// buildExport dijalankan oleh job queue. Error dikembalikan agar job dicoba
+// ulang; status failed baru disimpan pada percobaan terakhir.
+func (s *AccountService) buildExport(ctx context.Context, job exportJob) error {
+	export, err := s.repository.FindExport(job.UserID, job.ExportID)
+	if err != nil || export.Status == models.ExportStatusReady || export.Status == models.ExportStatusFailed {
+		return nil // user sudah dihapus atau export sudah selesai
+	}
+
+	export.Status = models.ExportStatusProcessing
+	if err := s.repository.UpdateExport(export); err != nil {
+		log.Printf("export %d: failed to update status: %v", export.ID, err)
+	}
+
+	path, size, err := s.writeExportArchive(export.UserID)
+	if err != nil {
+		log.Printf("export %d failed: %v", export.ID, err)
+		if !jobqueue.LastAttempt(ctx) {
+			return err
+		}
+		export.Status = models.ExportStatusFailed
+		export.Error = "failed to build export"
+	} else {
+		now := time.Now()
+		export.Status = models.ExportStatusReady
+		export.Filepath = path
+		export.Size = size
+		export.CompletedAt = &now
+	}
+
+	if err := s.repository.UpdateExport(export); err != nil {
+		log.Printf("export %d: failed to update status: %v", export.ID, err)
+	}
+	return err
+}
+
+func (s *AccountService) writeExportArchive(userID uint) (string, int64, error) {
+	data, err := s.repository.LoadExportData(userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(s.exportDir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	finalPath := filepath.Join(s.exportDir, uuid.New().String()+".zip")
+	tmpPath := finalPath + ".part"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	zw := zip.NewWriter(out)
+	if err := writeExportEntries(zw, data); err != nil {
+		zw.Close()
+		out.Close()
+		return "", 0, err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return "", 0, err
+	}
+	if err := out.Close(); err != nil {
+		return "", 0, err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return "", 0, err
+	}
+	return finalPath, info.Size(), nil
+}
+
+func writeExportEntries(zw *zip.Writer, data *repositories.AccountExportData) error {
+	profile := dto.ExportProfile{
+		ID:         data.User.ID,
+		Username:   data.User.Username,
+		Email:      data.User.Email,
+		VerifiedAt: data.User.VerifiedAt,
+		CreatedAt:  data.User.CreatedAt,
+	}
+	if err := writeZipJSON(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	var files []dto.ExportFile
+	for _, f := range data.Files {
+		entry := dto.ExportFile{
+			ID:          f.ID,
+			FileName:    f.Filename,
+			MimeType:    f.Mimetype,
+			Size:        f.Size,
+			WorkspaceId: f.WorkspaceID,
+			UploadedAt:  f.UploadedAt,
+		}
+		for _, cat := range f.Categories {
+			entry.CategoryIDs = append(entry.CategoryIDs, cat.ID)
+		}
+
+		// file yang dikarantina hanya dicatat di files.json, tanpa isinya
+		if f.ScanStatus == models.ScanInfected {
+			files = append(files, entry)
+			continue
+		}
+		archivePath := fmt.Sprintf("files/%d-%s", f.ID, filepath.Base(f.Filename))
+		if err := copyIntoZip(zw, archivePath, f.Filepath); err == nil {
+			entry.ArchivePath = archivePath
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		files = append(files, entry)
+	}
+	if err := writeZipJSON(zw, "files.json", files); err != nil {
+		return err
+	}
+
+	var categories []dto.CategorySimple
+	for _, cat := range data.Categories {
+		categories = append(categories, dto.CategorySimple{ID: cat.ID, Name: cat.Name, Color: cat.Color})
+	}
+	if err := writeZipJSON(zw, "categories.json", categories); err != nil {
+		return err
+	}
+
+	var memberships []dto.ExportMembership
+	for _, m := range data.Memberships {
+		memberships = append(memberships, dto.ExportMembership{
+			WorkspaceID:   m.WorkspaceID,
+			WorkspaceName: m.Workspace.Name,
+			Role:          m.Role,
+			JoinedAt:      m.JoinedAt,
+		})
+	}
+	if err := writeZipJSON(zw, "workspaces.json", memberships); err != nil {
+		return err
+	}
+
+	var shares []dto.ExportShare
+	for _, sh := range data.Shares {
+		shares = append(shares, dto.ExportShare{
+			FileID:           sh.FileID,
+			SharedByUserID:   sh.SharedByUserID,
+			SharedWithUserID: sh.SharedWithUserID,
+			Permission:       sh.Permission,
+			SharedAt:         sh.SharedAt,
+			ExpiresAt:        sh.ExpiresAt,
+		})
+	}
+	var links []dto.ExportPublicLink
+	for _, l := range data.PublicLinks {
+		links = append(links, dto.ExportPublicLink{
+			FileID:      l.FileID,
+			Permission:  l.Permission,
+			AccessCount: l.AccessCount,
+			IsActive:    l.IsActive,
+			CreatedAt:   l.CreatedAt,
+			ExpiresAt:   l.ExpiresAt,
+		})
+	}
+	return writeZipJSON(zw, "shares.json", map[string]interface{}{
+		"shares":       shares,
+		"public_links": links,
+	})
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func copyIntoZip(zw *zip.Writer, name string, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+func toExportResponse(export *models.DataExport) *dto.DataExportResponse {
+	resp := &dto.DataExportResponse{
+		ID:          export.ID,
+		Status:      export.Status,
+		Size:        export.Size,
+		Error:       export.Error,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+	}
+	if export.Status == models.ExportStatusReady {
+		resp.DownloadURL = fmt.Sprintf("/api/v1/me/exports/%d/download", export.ID)
+	}
+	return resp
+}
+
+// removeBlobs menghapus file di disk beserta thumbnail-nya, error hanya di-log
+func removeBlobs(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove %s: %v", path, err)
+		}
+		removeThumbnails(path)
+	}
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+const archiveTTL = 24 * time.Hour
+
+type ArchiveServiceInterface interface {
+	ResolveFiles(userID uint, fileIDs []uint) ([]models.File, error)
+	ResolveWorkspaceFiles(userID uint, workspaceID uint) ([]models.File, error)
+	WriteArchive(w io.Writer, files []models.File) error
+	RequestArchive(userID uint, workspaceID *uint, files []models.File) (*dto.ArchiveResponse, error)
+	GetArchive(userID uint, archiveID uint) (*dto.ArchiveResponse, error)
+	ArchiveFilePath(userID uint, archiveID uint) (string, error)
+}
+
+type ArchiveService struct {
+	repository    repositories.ArchiveRepositoryInterface
+	workspaceRepo repositories.WorkspaceRepository
+	archiveDir    string
+	archiveJob    jobqueue.Type[archiveJob]
+}
+
+type archiveJob struct {
+	ArchiveID uint   `json:"archive_id"`
+	UserID    uint   `json:"user_id"`
+	FileIDs   []uint `json:"file_ids"`
+}
+
+func NewArchiveService(repo repositories.ArchiveRepositoryInterface, workspaceRepo repositories.WorkspaceRepository, archiveDir string, jobs *jobqueue.Queue) ArchiveServiceInterface {
+	service := &ArchiveService{
+		repository:    repo,
+		workspaceRepo: workspaceRepo,
+		archiveDir:    archiveDir,
+	}
+	service.archiveJob = jobqueue.Register(jobs, "build_archive", jobqueue.HandlerOptions{
+		Queue:       "exports",
+		MaxAttempts: 3,
+		Timeout:     30 * time.Minute,
+	}, service.buildArchive)
+	return service
+}
+
+// ResolveFiles mengambil file yang diminta dan memastikan user boleh mengunduh
+// semuanya: pemilik file personal, member workspace, atau penerima share dengan
+// izin download/edit. Dicek sebelum streaming dimulai supaya error bisa dikirim.
+func (s *ArchiveService) ResolveFiles(userID uint, fileIDs []uint) ([]models.File, error) {
+	files, err := s.repository.FindFiles(fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+
+	shared, err := s.repository.SharedFileIDs(userID, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[uint]bool)
+	seen := make(map[uint]bool, len(fileIDs))
+	var ordered []models.File
+	for _, id := range fileIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		f, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("file %d not found", id)
+		}
+
+		allowed := shared[id]
+		if f.WorkspaceID == nil {
+			allowed = allowed || f.UserID == userID
+		} else if !allowed {
+			isMember, checked := members[*f.WorkspaceID]
+			if !checked {
+				_, err := s.workspaceRepo.FindMember(*f.WorkspaceID, userID)
+				isMember = err == nil
+				members[*f.WorkspaceID] = isMember
+			}
+			allowed = isMember
+		}
+		if !allowed {
+			return nil, fmt.Errorf("unauthorized: no access to file %d", id)
+		}
+
+		ordered = append(ordered, f)
+	}
+	return ordered, nil
+}
+
+func (s *ArchiveService) ResolveWorkspaceFiles(userID uint, workspaceID uint) ([]models.File, error) {
+	if _, err := s.workspaceRepo.FindMember(workspaceID, userID); err != nil {
+		return nil, fmt.Errorf("unauthorized: you are not a member of this workspace")
+	}
+	return s.repository.ListWorkspaceFiles(workspaceID)
+}
+
+// WriteArchive menulis zip langsung ke w. Isi file di-copy per file sehingga
+// memori tetap konstan berapa pun ukuran arsipnya.
+func (s *ArchiveService) WriteArchive(w io.Writer, files []models.File) error {
+	zw := zip.NewWriter(w)
+	names := make(map[string]int, len(files))
+	requireClean := make(map[uint]bool)
+
+	for _, f := range files {
+		if err := checkScanPolicy(&f, s.requiresCleanScan(requireClean, f.WorkspaceID)); err != nil {
+			log.Printf("archive: skipping file %d: %v", f.ID, err)
+			continue
+		}
+
+		src, err := os.Open(f.Filepath)
+		if err != nil {
+			// blob hilang tidak menggagalkan seluruh arsip
+			log.Printf("archive: skipping file %d: %v", f.ID, err)
+			continue
+		}
+
+		header := &zip.FileHeader{
+			Name:     uniqueArchiveName(names, f.DisplayName()),
+			Method:   archiveMethod(f.Mimetype),
+			Modified: f.UploadedAt,
+		}
+		entry, err := zw.CreateHeader(header)
+		if err == nil {
+			_, err = io.Copy(entry, src)
+		}
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// requiresCleanScan membaca kebijakan workspace sekali per arsip
+func (s *ArchiveService) requiresCleanScan(cache map[uint]bool, workspaceID *uint) bool {
+	if workspaceID == nil {
+		return false
+	}
+	required, ok := cache[*workspaceID]
+	if !ok {
+		workspace, err := s.workspaceRepo.FindByID(*workspaceID)
+		required = err == nil && workspace.RequireCleanScan
+		cache[*workspaceID] = required
+	}
+	return required
+}
+
+// RequestArchive membuat arsip di background untuk diunduh nanti
+func (s *ArchiveService) RequestArchive(userID uint, workspaceID *uint, files []models.File) (*dto.ArchiveResponse, error) {
+	if paths, err := s.repository.DeleteExpiredArchives(userID); err == nil {
+		removeBlobs(paths)
+	}
+
+	archive := &models.FileArchive{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		FileCount:   len(files),
+		Status:      models.ExportStatusPending,
+		ExpiresAt:   time.Now().Add(archiveTTL),
+	}
+	if err := s.repository.CreateArchive(archive); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	fileIDs := make([]uint, len(files))
+	for i, f := range files {
+		fileIDs[i] = f.ID
+	}
+	if _, err := s.archiveJob.Enqueue(archiveJob{ArchiveID: archive.ID, UserID: userID, FileIDs: fileIDs}); err != nil {
+		archive.Status = models.ExportStatusFailed
+		archive.Error = "failed to schedule archive"
+		if err := s.repository.UpdateArchive(archive); err != nil {
+			log.Printf("archive %d: failed to update status: %v", archive.ID, err)
+		}
+		return nil, fmt.Errorf("failed to schedule archive: %w", err)
+	}
+
+	return toArchiveResponse(archive), nil
+}
+
+func (s *ArchiveService) GetArchive(userID uint, archiveID uint) (*dto.ArchiveResponse, error) {
+	archive, err := s.repository.FindArchive(userID, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("archive not found: %w", err)
+	}
+	return toArchiveResponse(archive), nil
+}
+
+func (s *ArchiveService) ArchiveFilePath(userID uint, archiveID uint) (string, error) {
+	archive, err := s.repository.FindArchive(userID, archiveID)
+	if err != nil {
+		return "", fmt.Errorf("archive not found: %w", err)
+	}
+	if archive.Status != models.ExportStatusReady || time.Now().After(archive.ExpiresAt) {
+		return "", apperrors.ErrExportNotReady
+	}
+	return archive.Filepath, nil
+}
+
+// buildArchive dijalankan oleh job queue. File dimuat ulang karena bisa saja
+// sudah dihapus sejak arsip diminta; urutannya mengikuti permintaan.
+func (s *ArchiveService) buildArchive(ctx context.Context, job archiveJob) error {
+	archive, err := s.repository.FindArchive(job.UserID, job.ArchiveID)
+	if err != nil || archive.Status == models.ExportStatusReady || archive.Status == models.ExportStatusFailed {
+		return nil
+	}
+
+	archive.Status = models.ExportStatusProcessing
+	if err := s.repository.UpdateArchive(archive); err != nil {
+		log.Printf("archive %d: failed to update status: %v", archive.ID, err)
+	}
+
+	found, err := s.repository.FindFiles(job.FileIDs)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]models.File, len(found))
+	for _, f := range found {
+		byID[f.ID] = f
+	}
+	files := make([]models.File, 0, len(job.FileIDs))
+	for _, id := range job.FileIDs {
+		if f, ok := byID[id]; ok {
+			files = append(files, f)
+		}
+	}
+
+	path, size, err := s.writeArchiveFile(files)
+	if err != nil {
+		log.Printf("archive %d failed: %v", archive.ID, err)
+		if !jobqueue.LastAttempt(ctx) {
+			return err
+		}
+		archive.Status = models.ExportStatusFailed
+		archive.Error = "failed to build archive"
+	} else {
+		now := time.Now()
+		archive.Status = models.ExportStatusReady
+		archive.Filepath = path
+		archive.Size = size
+		archive.FileCount = len(files)
+		archive.CompletedAt = &now
+	}
+
+	if err := s.repository.UpdateArchive(archive); err != nil {
+		log.Printf("archive %d: failed to update status: %v", archive.ID, err)
+	}
+	return err
+}
+
+func (s *ArchiveService) writeArchiveFile(files []models.File) (string, int64, error) {
+	if err := os.MkdirAll(s.archiveDir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	finalPath := filepath.Join(s.archiveDir, uuid.New().String()+".zip")
+	tmpPath := finalPath + ".part"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	if err := s.WriteArchive(out, files); err != nil {
+		out.Close()
+		return "", 0, err
+	}
+	if err := out.Close(); err != nil {
+		return "", 0, err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return "", 0, err
+	}
+	return finalPath, info.Size(), nil
+}
+
+// uniqueArchiveName memberi akhiran " (n)" jika nama sudah dipakai di arsip
+func uniqueArchiveName(used map[string]int, name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+
+	key := strings.ToLower(name)
+	count, exists := used[key]
+	used[key] = count + 1
+	if !exists {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := count; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, taken := used[strings.ToLower(candidate)]; !taken {
+			used[strings.ToLower(candidate)] = 1
+			return candidate
+		}
+	}
+}
+
+// archiveMethod: format yang sudah terkompresi disimpan apa adanya
+func archiveMethod(mimetype string) uint16 {
+	mime := strings.ToLower(mimetype)
+	switch {
+	case strings.HasPrefix(mime, "image/"), strings.HasPrefix(mime, "video/"), strings.HasPrefix(mime, "audio/"):
+		return zip.Store
+	case strings.Contains(mime, "zip"), strings.Contains(mime, "compressed"), strings.Contains(mime, "x-7z"), strings.Contains(mime, "rar"):
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+func toArchiveResponse(archive *models.FileArchive) *dto.ArchiveResponse {
+	resp := &dto.ArchiveResponse{
+		ID:          archive.ID,
+		Status:      archive.Status,
+		FileCount:   archive.FileCount,
+		Size:        archive.Size,
+		Error:       archive.Error,
+		CreatedAt:   archive.CreatedAt,
+		CompletedAt: archive.CompletedAt,
+		ExpiresAt:   archive.ExpiresAt,
+	}
+	if archive.Status == models.ExportStatusReady {
+		resp.DownloadURL = fmt.Sprintf("/api/v1/files/archives/%d/download", archive.ID)
+	}
+	return resp
+}
@@ -2,20 +2,108 @@ package services
 
 import (
 	"errors"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"vasvault/internal/dto"
 	"vasvault/internal/models"
 	"vasvault/internal/repositories"
+	apperrors "vasvault/pkg/utils"
 )
 
+const ruleApplyBatchSize = 500
+
 type CategoryService struct {
-	repo *repositories.CategoryRepository
+	repo          *repositories.CategoryRepository
+	workspaceRepo repositories.WorkspaceRepository
+}
+
+func NewCategoryService(repo *repositories.CategoryRepository, workspaceRepo repositories.WorkspaceRepository) *CategoryService {
+	return &CategoryService{repo: repo, workspaceRepo: workspaceRepo}
+}
+
+// authorize mengecek akses ke kategori: kategori personal hanya untuk pembuatnya,
+// kategori workspace bisa dilihat semua member dan diubah editor ke atas
+func (s *CategoryService) authorize(userID uint, category *models.Category, write bool) error {
+	if category.WorkspaceID == nil {
+		if category.UserID != userID {
+			return errors.New("category not found")
+		}
+		return nil
+	}
+
+	member, err := s.workspaceRepo.FindMember(*category.WorkspaceID, userID)
+	if err != nil {
+		return errors.New("category not found")
+	}
+	if write && !models.RoleAtLeast(member.Role, models.RoleEditor) {
+		return errors.New("unauthorized: editor role or higher required")
+	}
+	return nil
+}
+
+// checkParent memastikan parent ada dan berada di scope yang sama dengan kategori
+func (s *CategoryService) checkParent(userID uint, workspaceID *uint, parentID uint) error {
+	parent, err := s.repo.GetByID(parentID)
+	if err != nil {
+		return errors.New("parent category not found")
+	}
+	if workspaceID == nil {
+		if parent.WorkspaceID != nil || parent.UserID != userID {
+			return errors.New("parent category not found")
+		}
+		return nil
+	}
+	if parent.WorkspaceID == nil || *parent.WorkspaceID != *workspaceID {
+		return errors.New("parent category must belong to the same workspace")
+	}
+	return nil
 }
 
-func NewCategoryService(repo *repositories.CategoryRepository) *CategoryService {
-	return &CategoryService{repo: repo}
+// List mengembalikan kategori personal, atau kategori workspace jika workspaceID diisi
+func (s *CategoryService) List(userID uint, search string, workspaceID *uint) ([]models.Category, error) {
+	if workspaceID == nil {
+		return s.repo.List(userID, search)
+	}
+
+	if _, err := s.workspaceRepo.FindMember(*workspaceID, userID); err != nil {
+		return nil, errors.New("unauthorized: you are not a member of this workspace")
+	}
+	return s.repo.ListByWorkspace(*workspaceID, search)
 }
 
-func (s *CategoryService) List(userID uint, search string) ([]models.Category, error) {
-	return s.repo.List(userID, search)
+// Tree mengembalikan kategori dalam bentuk pohon. Kategori yang parent-nya
+// tidak ikut hasil (mis. karena search) ditampilkan sebagai root.
+func (s *CategoryService) Tree(userID uint, search string, workspaceID *uint) ([]models.Category, error) {
+	categories, err := s.List(userID, search, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[uint]bool, len(categories))
+	children := make(map[uint][]models.Category)
+	for _, cat := range categories {
+		present[cat.ID] = true
+	}
+
+	var roots []models.Category
+	for _, cat := range categories {
+		if cat.ParentID != nil && present[*cat.ParentID] {
+			children[*cat.ParentID] = append(children[*cat.ParentID], cat)
+		} else {
+			roots = append(roots, cat)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots), nil
 }
 
 func (s *CategoryService) GetByID(id uint) (*models.Category, error) {
@@ -27,36 +115,59 @@ func (s *CategoryService) GetByID(id uint) (*models.Category, error) {
 }
 
 func (s *CategoryService) Detail(userID uint, id uint) (*models.Category, error) {
-    category, err := s.repo.GetByIDAndUser(userID, id)
+    category, err := s.repo.GetByID(id)
     if err != nil {
         return nil, errors.New("category not found")
     }
+    if err := s.authorize(userID, category, false); err != nil {
+        return nil, err
+    }
     return category, nil
 }
 
 
 
 
-func (s *CategoryService) Create(name string, color string, userID uint) (*models.Category, error) {
+func (s *CategoryService) Create(name string, color string, userID uint, workspaceID *uint, parentID *uint) (*models.Category, error) {
 	if name == "" {
 		return nil, errors.New("category name is required")
 	}
 
-
-	existing, err := s.repo.GetByName(userID, name)
+	var existing *models.Category
+	var err error
+	if workspaceID != nil {
+		member, memberErr := s.workspaceRepo.FindMember(*workspaceID, userID)
+		if memberErr != nil {
+			return nil, errors.New("unauthorized: you are not a member of this workspace")
+		}
+		if !models.RoleAtLeast(member.Role, models.RoleEditor) {
+			return nil, errors.New("unauthorized: editor role or higher required")
+		}
+		existing, err = s.repo.GetByNameInWorkspace(*workspaceID, name)
+	} else {
+		existing, err = s.repo.GetByName(userID, name)
+	}
 	if err == nil && existing.ID != 0 {
 		return nil, errors.New("category already exists")
 	}
 
+	if parentID != nil {
+		if err := s.checkParent(userID, workspaceID, *parentID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Default color
 	if color == "" {
 		color = "#3B82F6"
 	}
 
 	category := &models.Category{
-		Name:   name,
-		Color:  color,
-		UserID: userID,
+		Name:        name,
+		Color:       color,
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		ParentID:    parentID,
 	}
 	
 	err = s.repo.Create(category)
@@ -76,12 +187,20 @@ func (s *CategoryService) Update(
 	color string,
 ) (*models.Category, error) {
 
-	category, err := s.repo.FindByID(userID, categoryID)
+	category, err := s.repo.FindByIDWithFiles(categoryID)
 	if err != nil {
 		return nil, errors.New("category not found")
 	}
+	if err := s.authorize(userID, category, true); err != nil {
+		return nil, err
+	}
 
-	exists, err := s.repo.ExistsByName(userID, name, categoryID)
+	var exists bool
+	if category.WorkspaceID != nil {
+		exists, err = s.repo.ExistsByNameInWorkspace(*category.WorkspaceID, name, categoryID)
+	} else {
+		exists, err = s.repo.ExistsByName(userID, name, categoryID)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -103,17 +222,358 @@ func (s *CategoryService) Update(
 
 // delete
 func (s *CategoryService) Delete(userID, categoryID uint) error {
-	// 1. pastikan category milik user
-	category, err := s.repo.FindByID(userID, categoryID)
+	// 1. pastikan user punya akses ke category
+	category, err := s.repo.FindByIDWithFiles(categoryID)
 	if err != nil {
 		return errors.New("category not found")
 	}
+	if err := s.authorize(userID, category, true); err != nil {
+		return err
+	}
 
-	// 2. cek apakah category masih dipakai file
+	// 2. cek apakah category masih dipakai file atau punya sub-kategori
 	if len(category.Files) > 0 {
 		return errors.New("category is still used by files")
 	}
+	children, err := s.repo.CountChildren(categoryID)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("category still has subcategories")
+	}
 
 	// 3. delete
 	return s.repo.Delete(category)
 }
+
+// Move memindahkan kategori ke bawah parent lain, atau ke root jika parentID nil
+func (s *CategoryService) Move(userID uint, categoryID uint, parentID *uint) (*models.Category, error) {
+	category, err := s.repo.GetByID(categoryID)
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	if err := s.authorize(userID, category, true); err != nil {
+		return nil, err
+	}
+
+	if parentID != nil {
+		if err := s.checkParent(category.UserID, category.WorkspaceID, *parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Move(categoryID, parentID); err != nil {
+		if errors.Is(err, apperrors.ErrCategoryCycle) {
+			return nil, err
+		}
+		return nil, errors.New("failed to move category")
+	}
+
+	category.ParentID = parentID
+	return category, nil
+}
+
+func (s *CategoryService) ListRules(userID uint, categoryID uint) ([]models.CategoryRule, error) {
+	category, err := s.repo.GetByID(categoryID)
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	if err := s.authorize(userID, category, false); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRules(categoryID)
+}
+
+func (s *CategoryService) CreateRule(userID uint, categoryID uint, req dto.CreateCategoryRuleRequest) (*models.CategoryRule, error) {
+	category, err := s.repo.GetByID(categoryID)
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	if err := s.authorize(userID, category, true); err != nil {
+		return nil, err
+	}
+
+	rule := &models.CategoryRule{
+		CategoryID:  categoryID,
+		MimeType:    strings.ToLower(strings.TrimSpace(req.MimeType)),
+		NamePattern: req.NamePattern,
+		PatternType: req.PatternType,
+		MinSize:     req.MinSize,
+		MaxSize:     req.MaxSize,
+		UploaderID:  req.UploaderID,
+		CreatedBy:   userID,
+	}
+	if rule.PatternType == "" {
+		rule.PatternType = models.RulePatternGlob
+	}
+
+	if rule.MimeType == "" && rule.NamePattern == "" && rule.MinSize == nil && rule.MaxSize == nil && rule.UploaderID == nil {
+		return nil, errors.New("rule needs at least one condition")
+	}
+	if rule.MinSize != nil && rule.MaxSize != nil && *rule.MinSize > *rule.MaxSize {
+		return nil, errors.New("min_size cannot be greater than max_size")
+	}
+	if rule.UploaderID != nil {
+		if category.WorkspaceID == nil {
+			return nil, errors.New("uploader condition is only available for workspace categories")
+		}
+		if _, err := s.workspaceRepo.FindMember(*category.WorkspaceID, *rule.UploaderID); err != nil {
+			return nil, errors.New("uploader is not a member of this workspace")
+		}
+	}
+	if _, err := compileRule(*rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *CategoryService) DeleteRule(userID uint, categoryID uint, ruleID uint) error {
+	category, err := s.repo.GetByID(categoryID)
+	if err != nil {
+		return errors.New("category not found")
+	}
+	if err := s.authorize(userID, category, true); err != nil {
+		return err
+	}
+
+	rule, err := s.repo.GetRule(categoryID, ruleID)
+	if err != nil {
+		return errors.New("rule not found")
+	}
+	return s.repo.DeleteRule(rule)
+}
+
+// ApplyRules menjalankan ulang rule kategori ke semua file yang sudah ada di
+// scope-nya. Dengan dryRun tidak ada yang diubah, hanya daftar file yang cocok.
+func (s *CategoryService) ApplyRules(userID uint, categoryID uint, dryRun bool) (*dto.ApplyCategoryRulesResponse, error) {
+	category, err := s.repo.GetByID(categoryID)
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	if err := s.authorize(userID, category, true); err != nil {
+		return nil, err
+	}
+
+	rules, err := s.repo.ListRules(categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("category has no rules")
+	}
+
+	var compiled []compiledRule
+	for _, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			log.Printf("category rule %d is invalid: %v", rule.ID, err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	result := &dto.ApplyCategoryRulesResponse{DryRun: dryRun}
+	err = s.repo.EachFileInScope(category, ruleApplyBatchSize, func(files []models.File) error {
+		var matched []models.File
+		var matchedIDs []uint
+		for _, f := range files {
+			candidate := ruleCandidate{Name: f.DisplayName(), MimeType: f.Mimetype, Size: f.Size, UploaderID: f.UserID}
+			if matchesAny(compiled, candidate) {
+				matched = append(matched, f)
+				matchedIDs = append(matchedIDs, f.ID)
+			}
+		}
+		if len(matched) == 0 {
+			return nil
+		}
+
+		assigned, err := s.repo.AssignedFileIDs(categoryID, matchedIDs)
+		if err != nil {
+			return err
+		}
+
+		var toAssign []uint
+		for _, f := range matched {
+			result.Matched++
+			if dryRun {
+				result.Files = append(result.Files, dto.RuleMatchFile{ID: f.ID, FileName: f.DisplayName(), AlreadyAssigned: assigned[f.ID]})
+			}
+			if !assigned[f.ID] {
+				toAssign = append(toAssign, f.ID)
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+		if err := s.repo.AssignToFiles(categoryID, toAssign); err != nil {
+			return err
+		}
+		result.Assigned += len(toAssign)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ruleCandidate adalah atribut file yang dicocokkan dengan rule
+type ruleCandidate struct {
+	Name       string
+	MimeType   string
+	Size       int64
+	UploaderID uint
+}
+
+type compiledRule struct {
+	rule models.CategoryRule
+	re   *regexp.Regexp
+}
+
+func compileRule(rule models.CategoryRule) (compiledRule, error) {
+	c := compiledRule{rule: rule}
+	if rule.NamePattern == "" {
+		return c, nil
+	}
+
+	switch rule.PatternType {
+	case models.RulePatternGlob:
+		if _, err := path.Match(strings.ToLower(rule.NamePattern), ""); err != nil {
+			return c, errors.New("invalid glob pattern")
+		}
+	case models.RulePatternRegex:
+		re, err := regexp.Compile(rule.NamePattern)
+		if err != nil {
+			return c, errors.New("invalid regex pattern")
+		}
+		c.re = re
+	default:
+		return c, errors.New("pattern_type must be glob or regex")
+	}
+	return c, nil
+}
+
+func (c compiledRule) matches(f ruleCandidate) bool {
+	r := c.rule
+
+	if r.MimeType != "" {
+		mime := strings.ToLower(strings.TrimSpace(strings.Split(f.MimeType, ";")[0]))
+		if strings.HasSuffix(r.MimeType, "/*") {
+			if !strings.HasPrefix(mime, strings.TrimSuffix(r.MimeType, "*")) {
+				return false
+			}
+		} else if mime != r.MimeType {
+			return false
+		}
+	}
+
+	if r.NamePattern != "" {
+		if c.re != nil {
+			if !c.re.MatchString(f.Name) {
+				return false
+			}
+		} else if ok, _ := path.Match(strings.ToLower(r.NamePattern), strings.ToLower(f.Name)); !ok {
+			return false
+		}
+	}
+
+	if r.MinSize != nil && f.Size < *r.MinSize {
+		return false
+	}
+	if r.MaxSize != nil && f.Size > *r.MaxSize {
+		return false
+	}
+	if r.UploaderID != nil && f.UploaderID != *r.UploaderID {
+		return false
+	}
+	return true
+}
+
+func matchesAny(rules []compiledRule, f ruleCandidate) bool {
+	for _, r := range rules {
+		if r.matches(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingCategoryIDs mengembalikan kategori di scope file yang rule-nya cocok
+func matchingCategoryIDs(rules []models.CategoryRule, f ruleCandidate) []uint {
+	var ids []uint
+	for _, rule := range rules {
+		if containsUint(ids, rule.CategoryID) {
+			continue
+		}
+		c, err := compileRule(rule)
+		if err != nil {
+			log.Printf("category rule %d is invalid: %v", rule.ID, err)
+			continue
+		}
+		if c.matches(f) {
+			ids = append(ids, rule.CategoryID)
+		}
+	}
+	return ids
+}
+
+func containsUint(list []uint, v uint) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats mengembalikan jumlah file dan total byte per kategori personal, atau per
+// kategori workspace jika workspaceID diisi
+func (s *CategoryService) Stats(userID uint, workspaceID *uint) ([]dto.CategoryStatResponse, error) {
+	if workspaceID != nil {
+		if _, err := s.workspaceRepo.FindMember(*workspaceID, userID); err != nil {
+			return nil, errors.New("unauthorized: you are not a member of this workspace")
+		}
+	}
+	return s.repo.Stats(userID, workspaceID)
+}
+
+// Merge memindahkan semua file dari kategori source ke target lalu menghapus source
+func (s *CategoryService) Merge(userID uint, sourceID uint, targetID uint) (*models.Category, error) {
+	if sourceID == targetID {
+		return nil, errors.New("cannot merge a category into itself")
+	}
+
+	source, err := s.repo.GetByID(sourceID)
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	if err := s.authorize(userID, source, true); err != nil {
+		return nil, err
+	}
+
+	target, err := s.repo.GetByID(targetID)
+	if err != nil {
+		return nil, errors.New("target category not found")
+	}
+	if err := s.authorize(userID, target, true); err != nil {
+		return nil, errors.New("target category not found")
+	}
+
+	sameScope := (source.WorkspaceID == nil && target.WorkspaceID == nil) ||
+		(source.WorkspaceID != nil && target.WorkspaceID != nil && *source.WorkspaceID == *target.WorkspaceID)
+	if !sameScope {
+		return nil, errors.New("categories must belong to the same scope")
+	}
+
+	if err := s.repo.Merge(sourceID, targetID); err != nil {
+		return nil, errors.New("failed to merge categories")
+	}
+
+	return s.repo.GetByID(targetID)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Event adalah perubahan pada file atau workspace yang diteruskan ke
+// subscriber (event stream dan webhook)
+type Event struct {
+	ID          string // diisi oleh EventService; kosong = dibuat saat payload dibangun
+	CreatedAt   time.Time
+	Type        string
+	ActorID     uint
+	OwnerID     uint  // pemilik file personal, dipakai jika WorkspaceID nil
+	WorkspaceID *uint // scope event
+	Data        interface{}
+}
+
+type EventPublisher interface {
+	Publish(event Event)
+}
+
+// newEventPayload membangun body event; id dan waktu dari event dipakai jika
+// sudah ada supaya stream dan webhook melihat event yang sama
+func newEventPayload(event Event) dto.EventPayload {
+	if event.ID == "" {
+		event.ID = "evt_" + uuid.New().String()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	return dto.EventPayload{
+		ID:          event.ID,
+		Type:        event.Type,
+		CreatedAt:   event.CreatedAt.UTC(),
+		WorkspaceID: event.WorkspaceID,
+		ActorID:     event.ActorID,
+		Data:        event.Data,
+	}
+}
+
+func fileEvent(eventType string, actorID uint, file *models.File) Event {
+	return Event{
+		Type:        eventType,
+		ActorID:     actorID,
+		OwnerID:     file.UserID,
+		WorkspaceID: file.WorkspaceID,
+		Data:        dto.FileEventData{File: eventFile(file)},
+	}
+}
+
+func eventFile(file *models.File) dto.EventFile {
+	return dto.EventFile{
+		ID:          file.ID,
+		FileName:    file.Filename,
+		MimeType:    file.Mimetype,
+		Size:        file.Size,
+		UserID:      file.UserID,
+		WorkspaceID: file.WorkspaceID,
+	}
+}
+
+// moveEvents: file.moved dikirim ke scope asal dan scope tujuan. Uploader
+// tidak berubah saat file dipindah, jadi pemiliknya tetap before.UserID.
+func moveEvents(actorID uint, before models.File, to *uint) []Event {
+	after := before
+	after.WorkspaceID = to
+	data := dto.FileEventData{File: eventFile(&after), FromWorkspaceID: before.WorkspaceID, ToWorkspaceID: to}
+	return []Event{
+		{Type: models.EventFileMoved, ActorID: actorID, OwnerID: before.UserID, WorkspaceID: before.WorkspaceID, Data: data},
+		{Type: models.EventFileMoved, ActorID: actorID, OwnerID: before.UserID, WorkspaceID: to, Data: data},
+	}
+}
+
+func memberEvent(eventType string, actorID uint, workspaceID uint, userID uint, role string) Event {
+	ws := workspaceID
+	return Event{
+		Type:        eventType,
+		ActorID:     actorID,
+		WorkspaceID: &ws,
+		Data:        dto.MemberEventData{WorkspaceID: workspaceID, UserID: userID, Role: role},
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/eventbus"
+	"vasvault/pkg/jobqueue"
+)
+
+const (
+	// event disimpan selama ini untuk resume dengan Last-Event-ID
+	eventRetention = 24 * time.Hour
+	// lebih dari ini client harus memuat ulang datanya (stream.reset)
+	maxEventReplay = 1000
+	// buffer per koneksi; client yang tertinggal sejauh ini diputus
+	streamBuffer = 256
+	// id event yang sudah diteruskan ke bus, untuk membuang duplikat
+	// dari catch-up setelah reconnect
+	recentEventIDs = 4096
+	// event yang commit tidak urut id-nya masih terambil saat resume jika
+	// dibuat paling lama selama ini sebelum event terakhir yang diterima
+	eventReplayOverlap = 10 * time.Second
+)
+
+type EventServiceInterface interface {
+	EventPublisher
+	Start()
+	Subscribe(userID uint, lastEventID uint) (*EventStream, error)
+}
+
+// EventService menyimpan setiap event, lalu meneruskannya ke subscriber lokal
+// (webhook). Penyimpanan mengirim NOTIFY; setiap instance yang LISTEN
+// memasukkan event ke bus lokalnya untuk koneksi GET /events.
+type EventService struct {
+	repository    repositories.EventRepositoryInterface
+	workspaceRepo repositories.WorkspaceRepository
+	subscribers   []EventPublisher
+	bus           *eventbus.Bus[models.Event]
+
+	mu       sync.Mutex
+	lastID   uint
+	seen     map[uint]struct{}
+	seenRing []uint
+}
+
+func NewEventService(repo repositories.EventRepositoryInterface, workspaceRepo repositories.WorkspaceRepository, jobs *jobqueue.Queue, subscribers ...EventPublisher) (EventServiceInterface, error) {
+	service := &EventService{
+		repository:    repo,
+		workspaceRepo: workspaceRepo,
+		subscribers:   subscribers,
+		bus:           eventbus.New[models.Event](),
+		seen:          make(map[uint]struct{}),
+	}
+
+	prune := jobqueue.Register(jobs, "prune_events", jobqueue.HandlerOptions{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		_, err := repo.DeleteBefore(time.Now().Add(-eventRetention))
+		return err
+	})
+	if err := prune.Schedule("prune-events", "@hourly", struct{}{}); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// Publish menyimpan event untuk stream dan meneruskannya ke subscriber.
+// Seperti webhook, error hanya di-log.
+func (s *EventService) Publish(event Event) {
+	payload := newEventPayload(event)
+	event.ID, event.CreatedAt = payload.ID, payload.CreatedAt
+
+	body, err := json.Marshal(payload)
+	if err == nil {
+		err = s.repository.Create(&models.Event{
+			EventID:     payload.ID,
+			Type:        event.Type,
+			OwnerID:     event.OwnerID,
+			WorkspaceID: event.WorkspaceID,
+			Payload:     string(body),
+			CreatedAt:   payload.CreatedAt,
+		})
+	}
+	if err != nil {
+		log.Printf("events: failed to store %s: %v", event.Type, err)
+	}
+
+	for _, sub := range s.subscribers {
+		sub.Publish(event)
+	}
+}
+
+// Start mulai menerima NOTIFY di background
+func (s *EventService) Start() {
+	if id, err := s.repository.MaxID(); err == nil {
+		s.lastID = id
+	}
+	go s.repository.Listen(context.Background(), s.catchUp, s.dispatchID)
+}
+
+// catchUp meneruskan event yang tersimpan selama listener terputus
+func (s *EventService) catchUp() {
+	for {
+		s.mu.Lock()
+		after := s.lastID
+		s.mu.Unlock()
+
+		events, err := s.repository.ListAfter(after, eventReplayOverlap, maxEventReplay)
+		if err != nil {
+			log.Printf("events: catch up failed: %v", err)
+			return
+		}
+		for _, event := range events {
+			s.dispatch(event)
+		}
+		// halaman yang hanya berisi event overlap tidak memajukan lastID
+		if len(events) < maxEventReplay || events[len(events)-1].ID <= after {
+			return
+		}
+	}
+}
+
+func (s *EventService) dispatchID(id uint) {
+	// tanpa koneksi GET /events di instance ini event tidak perlu dimuat
+	if s.bus.Len() == 0 {
+		s.mu.Lock()
+		if id > s.lastID {
+			s.lastID = id
+		}
+		s.mu.Unlock()
+		return
+	}
+	event, err := s.repository.FindByID(id)
+	if err != nil {
+		log.Printf("events: failed to load event %d: %v", id, err)
+		return
+	}
+	s.dispatch(*event)
+}
+
+func (s *EventService) dispatch(event models.Event) {
+	s.mu.Lock()
+	if _, dup := s.seen[event.ID]; dup {
+		s.mu.Unlock()
+		return
+	}
+	if len(s.seenRing) == recentEventIDs {
+		delete(s.seen, s.seenRing[0])
+		s.seenRing = s.seenRing[1:]
+	}
+	s.seen[event.ID] = struct{}{}
+	s.seenRing = append(s.seenRing, event.ID)
+	if event.ID > s.lastID {
+		s.lastID = event.ID
+	}
+	s.mu.Unlock()
+
+	s.bus.Publish(event)
+}
+
+// EventStream adalah satu koneksi GET /events. C ditutup jika client terlalu
+// lambat membaca atau Close dipanggil; client lalu reconnect dengan Last-Event-ID.
+type EventStream struct {
+	C <-chan dto.StreamEvent
+
+	sub  *eventbus.Subscription[models.Event]
+	done chan struct{}
+	once sync.Once
+}
+
+func (st *EventStream) Close() {
+	st.once.Do(func() {
+		close(st.done)
+		st.sub.Close()
+	})
+}
+
+// Subscribe membuka stream untuk file personal userID dan workspace yang
+// diikutinya. Dengan lastEventID, event setelahnya dikirim ulang dulu; jika
+// sudah terhapus atau terlalu banyak, stream diawali stream.reset.
+func (s *EventService) Subscribe(userID uint, lastEventID uint) (*EventStream, error) {
+	workspaceIDs, err := s.workspaceRepo.MemberWorkspaceIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// subscribe sebelum replay supaya tidak ada event yang lolos di antaranya
+	sub := s.bus.Subscribe(streamBuffer)
+	var replay []models.Event
+	reset := false
+	if lastEventID > 0 {
+		replay, reset, err = s.replay(userID, workspaceIDs, lastEventID)
+		if err != nil {
+			sub.Close()
+			return nil, err
+		}
+	}
+	var resetID uint
+	if reset {
+		if resetID, err = s.repository.MaxID(); err != nil {
+			sub.Close()
+			return nil, err
+		}
+	}
+
+	out := make(chan dto.StreamEvent, streamBuffer)
+	stream := &EventStream{C: out, sub: sub, done: make(chan struct{})}
+	filter := newStreamFilter(userID, workspaceIDs, s.workspaceRepo)
+	go func() {
+		defer close(out)
+		send := func(msg dto.StreamEvent) bool {
+			select {
+			case out <- msg:
+				return true
+			case <-stream.done:
+				return false
+			}
+		}
+
+		sent := make(map[uint]bool, len(replay))
+		if reset && !send(dto.StreamEvent{ID: resetID, Type: models.EventStreamReset, Data: json.RawMessage(`{}`)}) {
+			return
+		}
+		for _, event := range replay {
+			sent[event.ID] = true
+			filter.visible(event) // memperbarui daftar workspace
+			if !send(toStreamEvent(event)) {
+				return
+			}
+		}
+		for event := range sub.C {
+			if sent[event.ID] || (reset && event.ID <= resetID) || !filter.visible(event) {
+				continue
+			}
+			if !send(toStreamEvent(event)) {
+				return
+			}
+		}
+	}()
+	return stream, nil
+}
+
+func (s *EventService) replay(userID uint, workspaceIDs []uint, lastEventID uint) ([]models.Event, bool, error) {
+	exists, err := s.repository.Exists(lastEventID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !exists {
+		return nil, true, nil
+	}
+	events, err := s.repository.ListVisibleAfter(lastEventID, eventReplayOverlap, userID, workspaceIDs, maxEventReplay+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(events) > maxEventReplay {
+		return nil, true, nil
+	}
+	return events, false, nil
+}
+
+func toStreamEvent(event models.Event) dto.StreamEvent {
+	return dto.StreamEvent{ID: event.ID, Type: event.Type, Data: json.RawMessage(event.Payload)}
+}
+
+// streamFilter menentukan event mana yang boleh dilihat user. Daftar
+// workspace dimuat ulang saat user sendiri ditambahkan atau dikeluarkan.
+type streamFilter struct {
+	userID        uint
+	workspaces    map[uint]bool
+	workspaceRepo repositories.WorkspaceRepository
+}
+
+func newStreamFilter(userID uint, workspaceIDs []uint, workspaceRepo repositories.WorkspaceRepository) *streamFilter {
+	f := &streamFilter{userID: userID, workspaceRepo: workspaceRepo}
+	f.set(workspaceIDs)
+	return f
+}
+
+func (f *streamFilter) set(workspaceIDs []uint) {
+	f.workspaces = make(map[uint]bool, len(workspaceIDs))
+	for _, id := range workspaceIDs {
+		f.workspaces[id] = true
+	}
+}
+
+func (f *streamFilter) visible(event models.Event) bool {
+	if event.WorkspaceID == nil {
+		return event.OwnerID == f.userID
+	}
+	if event.Type == models.EventMemberAdded || event.Type == models.EventMemberRemoved {
+		var payload struct {
+			Data dto.MemberEventData `json:"data"`
+		}
+		if json.Unmarshal([]byte(event.Payload), &payload) == nil && payload.Data.UserID == f.userID {
+			if ids, err := f.workspaceRepo.MemberWorkspaceIDs(f.userID); err == nil {
+				f.set(ids)
+			}
+			// user yang dikeluarkan tetap diberi tahu
+			return true
+		}
+	}
+	return f.workspaces[*event.WorkspaceID]
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"os"
 	"path/filepath"
@@ -11,88 +12,150 @@ import (
 	"vasvault/internal/dto"
 	"vasvault/internal/models"
 	"vasvault/internal/repositories"
+	apperrors "vasvault/pkg/utils"
 
 	"github.com/google/uuid"
 )
 
+// Batas storage per user: 5 GiB
+const maxStorageBytes int64 = 5 * 1024 * 1024 * 1024
+
+// Bulk actions untuk POST /files/bulk
+const (
+	BulkActionDelete           = "delete"
+	BulkActionMove             = "move"
+	BulkActionAssignCategories = "assign_categories"
+	BulkActionRemoveCategories = "remove_categories"
+	BulkActionMoveFolder       = "move_folder"
+)
+
 type FileServiceInterface interface {
 	UploadFile(userID uint, file multipart.File, header *multipart.FileHeader, request dto.UploadFileRequest) (*dto.FileResponse, error)
 	GetFileByID(fileID uint) (*dto.FileResponse, error)
+	GetDownloadPath(userID, fileID uint) (string, error)
 	ListUserFiles(userID uint) ([]dto.FileResponse, error)
-	ListUserFilesWithOptionalCategory(userID uint, categoryID *uint) ([]dto.FileResponse, error)
+	ListUserFilesWithOptionalCategory(userID uint, categoryID *uint, includeDescendants bool) ([]dto.FileResponse, error)
 	ListFilesByWorkspace(userID uint, workspaceID uint) ([]dto.FileResponse, error)
-	DeleteFile(fileID uint) error
+	DeleteFile(userID, fileID uint) error
 	AssignCategories(userID, fileID uint, categoryIDs []uint) error
 	RemoveCategories(userID, fileID uint, categoryIDs []uint) error
 	UpdateCategories(userID, fileID uint, categoryIDs []uint) error
 	GetStorageSummary(userID uint) (*dto.StorageSummaryResponse, error)
 	RenameFile(userID, fileID uint, newName string) (*dto.FileResponse, error)
+	BulkAction(userID uint, req dto.BulkFileRequest) (*dto.BulkFileResponse, error)
+	MoveFile(userID, fileID uint, req dto.FileTargetRequest) (*dto.FileResponse, error)
+	CopyFile(userID, fileID uint, req dto.FileTargetRequest) (*dto.FileResponse, error)
+	GetThumbnail(userID, fileID uint, size, format string) (path string, status string, err error)
+	ThumbnailPlaceholder(size, format string) ([]byte, error)
+	TransformImage(userID, fileID uint, opts ImageTransformOptions) (string, error)
 }
 
 type FileService struct {
 	repository    repositories.FileRepositoryInterface
 	workspaceRepo repositories.WorkspaceRepository
+	categoryRepo  *repositories.CategoryRepository
+	thumbnails    ThumbnailServiceInterface
+	images        ImageServiceInterface
+	scans         ScanServiceInterface
+	events        EventPublisher
 	basePath      string
 }
 
-func NewFileService(repo repositories.FileRepositoryInterface, workspaceRepo repositories.WorkspaceRepository, basePath string) FileServiceInterface {
+func NewFileService(repo repositories.FileRepositoryInterface, workspaceRepo repositories.WorkspaceRepository, categoryRepo *repositories.CategoryRepository, thumbnails ThumbnailServiceInterface, images ImageServiceInterface, scans ScanServiceInterface, events EventPublisher, basePath string) FileServiceInterface {
 	return &FileService{
 		repository:    repo,
 		workspaceRepo: workspaceRepo,
+		categoryRepo:  categoryRepo,
+		thumbnails:    thumbnails,
+		images:        images,
+		scans:         scans,
+		events:        events,
 		basePath:      basePath,
 	}
 }
 
+// UploadFile menulis blob ke key sementara (<nama>.part), menyimpan file dan
+// kategorinya dalam satu transaksi, lalu me-rename blob ke nama finalnya.
+// Error di langkah mana pun menghapus semua yang sudah dibuat.
 func (s *FileService) UploadFile(userID uint, file multipart.File, header *multipart.FileHeader, request dto.UploadFileRequest) (*dto.FileResponse, error) {
-	if _, err := os.Stat(s.basePath); os.IsNotExist(err) {
-		if err := os.MkdirAll(s.basePath, os.ModePerm); err != nil {
-			return nil, fmt.Errorf("failed to create upload directory: %w", err)
-		}
+	// validasi sebelum blob ditulis ke disk
+	if err := s.checkFileWrite(userID, userID, request.WorkspaceId); err != nil {
+		return nil, fmt.Errorf("%w: %v", apperrors.ErrUploadRejected, err)
+	}
+	if err := s.validateCategoryScope(userID, request.WorkspaceId, request.CategoryIDs); err != nil {
+		return nil, fmt.Errorf("%w: %v", apperrors.ErrUploadRejected, err)
+	}
+
+	if err := os.MkdirAll(s.basePath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
 	}
 
-	ext := filepath.Ext(header.Filename)
-	newName := uuid.New().String() + ext
+	originalName := filepath.Base(header.Filename)
+	newName := uuid.New().String() + filepath.Ext(header.Filename)
 	fullPath := filepath.Join(s.basePath, newName)
+	tmpPath := fullPath + ".part"
+	// dihapus di setiap jalur error; setelah rename file ini sudah tidak ada
+	defer os.Remove(tmpPath)
 
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+	if err := writeBlob(tmpPath, file); err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
-		return nil, fmt.Errorf("failed to save file: %w", err)
+	mimetype := header.Header.Get("Content-Type")
+	size := header.Size
+	var metadata *models.FileMetadata
+	if isImageMime(mimetype) {
+		var err error
+		if metadata, size, err = s.prepareImage(tmpPath, request.WorkspaceId); err != nil {
+			return nil, err
+		}
 	}
 
-	model := &models.File{
-		Filename:    newName,
-		Filepath:    fullPath,
-		Mimetype:    header.Header.Get("Content-Type"),
-		Size:        header.Size,
-		UserID:      userID,
-		WorkspaceID: request.WorkspaceId,
-		UploadedAt:  time.Now(),
+	// kategori dari request ditambah kategori dari smart rule yang cocok
+	var categoryIDs []uint
+	for _, id := range request.CategoryIDs {
+		if !containsUint(categoryIDs, id) {
+			categoryIDs = append(categoryIDs, id)
+		}
+	}
+	if rules, err := s.categoryRepo.RulesForScope(userID, request.WorkspaceId); err == nil {
+		// nama asli dari client, bukan nama uuid yang disimpan
+		candidate := ruleCandidate{Name: originalName, MimeType: mimetype, Size: size, UploaderID: userID}
+		for _, id := range matchingCategoryIDs(rules, candidate) {
+			if !containsUint(categoryIDs, id) {
+				categoryIDs = append(categoryIDs, id)
+			}
+		}
 	}
 
-	if err := s.repository.Create(model); err != nil {
+	model := &models.File{
+		Filename:     newName,
+		OriginalName: originalName,
+		Filepath:     fullPath,
+		Mimetype:     mimetype,
+		Size:         size,
+		UserID:       userID,
+		WorkspaceID:  request.WorkspaceId,
+		UploadedAt:   time.Now(),
+		Metadata:     metadata,
+	}
+	if err := s.repository.CreateWithCategories(model, categoryIDs); err != nil {
 		return nil, fmt.Errorf("failed to store file metadata: %w", err)
 	}
 
-	// Assign categories jika ada
-	if len(request.CategoryIDs) > 0 {
-		if err := s.repository.AssignCategories(model.ID, request.CategoryIDs); err != nil {
-			return nil, fmt.Errorf("failed to assign categories: %w", err)
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		if perr := s.repository.PurgeFiles([]uint{model.ID}); perr != nil {
+			log.Printf("upload: failed to roll back file %d: %v", model.ID, perr)
 		}
+		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
 
-	// Reload file dengan categories untuk response
-	fileWithCategories, err := s.repository.FindByIDWithCategories(model.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load file with categories: %w", err)
-	}
+	s.scans.Enqueue(*model)
+	s.thumbnails.Enqueue(*model)
+	s.publish(fileEvent(models.EventFileUploaded, userID, model))
 
 	var categories []dto.CategorySimple
-	for _, cat := range fileWithCategories.Categories {
+	for _, cat := range model.Categories {
 		categories = append(categories, dto.CategorySimple{
 			ID:    cat.ID,
 			Name:  cat.Name,
@@ -101,20 +164,36 @@ func (s *FileService) UploadFile(userID uint, file multipart.File, header *multi
 	}
 
 	response := dto.FileResponse{
-		ID:          model.ID,
-		UserId:      model.UserID,
-		WorkspaceId: model.WorkspaceID,
-		FileName:    model.Filename,
-		FilePath:    model.Filepath,
-		MimeType:    model.Mimetype,
-		Size:        model.Size,
-		Categories:  categories,
-		CreatedAt:   model.UploadedAt,
+		ID:            model.ID,
+		UserId:        model.UserID,
+		WorkspaceId:   model.WorkspaceID,
+		FileName:      model.Filename,
+		FilePath:      model.Filepath,
+		MimeType:      model.Mimetype,
+		Size:          model.Size,
+		Categories:    categories,
+		CreatedAt:     model.UploadedAt,
+		Metadata:      model.Metadata,
+		ScanStatus:    model.ScanStatus,
+		ScanSignature: model.ScanSignature,
 	}
 
 	return &response, nil
 }
 
+// writeBlob menulis isi upload ke path baru; file yang sudah ada tidak ditimpa
+func writeBlob(path string, src io.Reader) error {
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
 func (s *FileService) GetFileByID(fileID uint) (*dto.FileResponse, error) {
 	file, err := s.repository.FindByIDWithCategories(fileID)
 	if err != nil {
@@ -131,15 +210,18 @@ func (s *FileService) GetFileByID(fileID uint) (*dto.FileResponse, error) {
 	}
 
 	response := dto.FileResponse{
-		ID:          file.ID,
-		UserId:      file.UserID,
-		WorkspaceId: file.WorkspaceID,
-		FileName:    file.Filename,
-		FilePath:    file.Filepath,
-		MimeType:    file.Mimetype,
-		Size:        file.Size,
-		Categories:  categories,
-		CreatedAt:   file.UploadedAt,
+		ID:            file.ID,
+		UserId:        file.UserID,
+		WorkspaceId:   file.WorkspaceID,
+		FileName:      file.Filename,
+		FilePath:      file.Filepath,
+		MimeType:      file.Mimetype,
+		Size:          file.Size,
+		Categories:    categories,
+		CreatedAt:     file.UploadedAt,
+		Metadata:      file.Metadata,
+		ScanStatus:    file.ScanStatus,
+		ScanSignature: file.ScanSignature,
 	}
 	return &response, nil
 }
@@ -161,31 +243,39 @@ func (s *FileService) ListUserFiles(userID uint) ([]dto.FileResponse, error) {
 		}
 
 		responses = append(responses, dto.FileResponse{
-			ID:          f.ID,
-			UserId:      f.UserID,
-			WorkspaceId: f.WorkspaceID,
-			FileName:    f.Filename,
-			FilePath:    f.Filepath,
-			MimeType:    f.Mimetype,
-			Size:        f.Size,
-			Categories:  categories,
-			CreatedAt:   f.UploadedAt,
+			ID:            f.ID,
+			UserId:        f.UserID,
+			WorkspaceId:   f.WorkspaceID,
+			FileName:      f.Filename,
+			FilePath:      f.Filepath,
+			MimeType:      f.Mimetype,
+			Size:          f.Size,
+			Categories:    categories,
+			CreatedAt:     f.UploadedAt,
+			Metadata:      f.Metadata,
+			ScanStatus:    f.ScanStatus,
+			ScanSignature: f.ScanSignature,
 		})
 	}
 	return responses, err
 }
 
-func (s *FileService) DeleteFile(fileID uint) error {
+func (s *FileService) DeleteFile(userID, fileID uint) error {
 	file, err := s.repository.FindByID(fileID)
 	if err != nil {
-		return fmt.Errorf("file not found: %w", err)
+		return apperrors.ErrFileNotFound
+	}
+	if err := s.checkFileWrite(userID, file.UserID, file.WorkspaceID); err != nil {
+		return fmt.Errorf("%w: %v", apperrors.ErrFileForbidden, err)
 	}
 	if err := os.Remove(file.Filepath); err != nil {
 		return fmt.Errorf("failed to delete file from fisk: %w", err)
 	}
+	removeThumbnails(file.Filepath)
 	if err := s.repository.Delete(fileID); err != nil {
 		return fmt.Errorf("failed to delete file metadata: %w", err)
 	}
+	s.publish(fileEvent(models.EventFileDeleted, userID, file))
 	return nil
 }
 
@@ -216,12 +306,17 @@ func (s *FileService) RenameFile(userID, fileID uint, newName string) (*dto.File
 		return nil, fmt.Errorf("failed to rename file on disk: %w", err)
 	}
 
+	oldName := file.Filename
 	file.Filename = newName
+	file.OriginalName = newName
 	file.Filepath = newPath
 
 	if err := s.repository.Update(file); err != nil {
 		return nil, fmt.Errorf("failed to update file metadata: %w", err)
 	}
+	renamed := fileEvent(models.EventFileRenamed, userID, file)
+	renamed.Data = dto.FileEventData{File: eventFile(file), OldName: oldName}
+	s.publish(renamed)
 
 	// build response
 	var categories []dto.CategorySimple
@@ -230,15 +325,18 @@ func (s *FileService) RenameFile(userID, fileID uint, newName string) (*dto.File
 	}
 
 	resp := &dto.FileResponse{
-		ID:          file.ID,
-		UserId:      file.UserID,
-		WorkspaceId: file.WorkspaceID,
-		FileName:    file.Filename,
-		FilePath:    file.Filepath,
-		MimeType:    file.Mimetype,
-		Size:        file.Size,
-		Categories:  categories,
-		CreatedAt:   file.UploadedAt,
+		ID:            file.ID,
+		UserId:        file.UserID,
+		WorkspaceId:   file.WorkspaceID,
+		FileName:      file.Filename,
+		FilePath:      file.Filepath,
+		MimeType:      file.Mimetype,
+		Size:          file.Size,
+		Categories:    categories,
+		CreatedAt:     file.UploadedAt,
+		Metadata:      file.Metadata,
+		ScanStatus:    file.ScanStatus,
+		ScanSignature: file.ScanSignature,
 	}
 
 	return resp, nil
@@ -246,13 +344,15 @@ func (s *FileService) RenameFile(userID, fileID uint, newName string) (*dto.File
 
 // AssignCategories menambahkan kategori ke file (tidak menghapus kategori yang sudah ada)
 func (s *FileService) AssignCategories(userID, fileID uint, categoryIDs []uint) error {
-	// Validasi file milik user
 	file, err := s.repository.FindByID(fileID)
 	if err != nil {
 		return fmt.Errorf("file not found")
 	}
-	if file.UserID != userID {
-		return fmt.Errorf("unauthorized: file does not belong to user")
+	if err := s.checkFileWrite(userID, file.UserID, file.WorkspaceID); err != nil {
+		return err
+	}
+	if err := s.validateCategoryScope(userID, file.WorkspaceID, categoryIDs); err != nil {
+		return err
 	}
 
 	if err := s.repository.AssignCategories(fileID, categoryIDs); err != nil {
@@ -263,13 +363,12 @@ func (s *FileService) AssignCategories(userID, fileID uint, categoryIDs []uint)
 
 // RemoveCategories menghapus kategori tertentu dari file
 func (s *FileService) RemoveCategories(userID, fileID uint, categoryIDs []uint) error {
-	// Validasi file milik user
 	file, err := s.repository.FindByID(fileID)
 	if err != nil {
 		return fmt.Errorf("file not found")
 	}
-	if file.UserID != userID {
-		return fmt.Errorf("unauthorized: file does not belong to user")
+	if err := s.checkFileWrite(userID, file.UserID, file.WorkspaceID); err != nil {
+		return err
 	}
 
 	if err := s.repository.RemoveCategories(fileID, categoryIDs); err != nil {
@@ -280,13 +379,15 @@ func (s *FileService) RemoveCategories(userID, fileID uint, categoryIDs []uint)
 
 // UpdateCategories mengganti semua kategori file dengan yang baru
 func (s *FileService) UpdateCategories(userID, fileID uint, categoryIDs []uint) error {
-	// Validasi file milik user
 	file, err := s.repository.FindByID(fileID)
 	if err != nil {
 		return fmt.Errorf("file not found")
 	}
-	if file.UserID != userID {
-		return fmt.Errorf("unauthorized: file does not belong to user")
+	if err := s.checkFileWrite(userID, file.UserID, file.WorkspaceID); err != nil {
+		return err
+	}
+	if err := s.validateCategoryScope(userID, file.WorkspaceID, categoryIDs); err != nil {
+		return err
 	}
 
 	// Clear semua kategori lama
@@ -304,8 +405,85 @@ func (s *FileService) UpdateCategories(userID, fileID uint, categoryIDs []uint)
 	return nil
 }
 
-func (s *FileService) ListUserFilesWithOptionalCategory(userID uint, categoryID *uint) ([]dto.FileResponse, error) {
-	files, err := s.repository.ListUserFilesWithOptionalCategory(userID, categoryID)
+// checkFileWrite: file personal hanya bisa diubah pemiliknya, file workspace
+// oleh member dengan role editor ke atas
+func (s *FileService) checkFileWrite(userID uint, ownerID uint, workspaceID *uint) error {
+	if workspaceID == nil {
+		if ownerID != userID {
+			return fmt.Errorf("unauthorized: file does not belong to user")
+		}
+		return nil
+	}
+
+	member, err := s.workspaceRepo.FindMember(*workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("unauthorized: you are not a member of this workspace")
+	}
+	if !models.RoleAtLeast(member.Role, models.RoleEditor) {
+		return fmt.Errorf("unauthorized: editor role or higher required")
+	}
+	return nil
+}
+
+// validateCategoryScope memastikan semua kategori ada dan satu scope dengan file:
+// kategori personal milik user untuk file personal, kategori workspace yang sama
+// untuk file workspace
+func (s *FileService) validateCategoryScope(userID uint, workspaceID *uint, categoryIDs []uint) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	categories, err := s.loadCategories(categoryIDs)
+	if err != nil {
+		return err
+	}
+	return checkCategoryScope(categories, userID, workspaceID, categoryIDs)
+}
+
+func (s *FileService) loadCategories(categoryIDs []uint) (map[uint]models.Category, error) {
+	categories, err := s.categoryRepo.GetByIDs(categoryIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+
+	found := make(map[uint]models.Category, len(categories))
+	for _, cat := range categories {
+		found[cat.ID] = cat
+	}
+	return found, nil
+}
+
+func checkCategoryScope(found map[uint]models.Category, userID uint, workspaceID *uint, categoryIDs []uint) error {
+	for _, id := range categoryIDs {
+		cat, ok := found[id]
+		if !ok {
+			return fmt.Errorf("category %d not found", id)
+		}
+		if workspaceID == nil {
+			if cat.WorkspaceID != nil || cat.UserID != userID {
+				return fmt.Errorf("category %d cannot be used for personal files", id)
+			}
+		} else if cat.WorkspaceID == nil || *cat.WorkspaceID != *workspaceID {
+			return fmt.Errorf("category %d does not belong to this workspace", id)
+		}
+	}
+	return nil
+}
+
+func (s *FileService) ListUserFilesWithOptionalCategory(userID uint, categoryID *uint, includeDescendants bool) ([]dto.FileResponse, error) {
+	var categoryIDs []uint
+	if categoryID != nil {
+		categoryIDs = []uint{*categoryID}
+		if includeDescendants {
+			descendants, err := s.categoryRepo.DescendantIDs(*categoryID)
+			if err != nil {
+				return nil, err
+			}
+			categoryIDs = append(categoryIDs, descendants...)
+		}
+	}
+
+	files, err := s.repository.ListUserFilesWithOptionalCategory(userID, categoryIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -322,15 +500,18 @@ func (s *FileService) ListUserFilesWithOptionalCategory(userID uint, categoryID
 		}
 
 		response = append(response, dto.FileResponse{
-			ID:          file.ID,
-			UserId:      file.UserID,
-			WorkspaceId: file.WorkspaceID,
-			FileName:    file.Filename,
-			FilePath:    file.Filepath,
-			MimeType:    file.Mimetype,
-			Size:        file.Size,
-			Categories:  categories,
-			CreatedAt:   file.UploadedAt,
+			ID:            file.ID,
+			UserId:        file.UserID,
+			WorkspaceId:   file.WorkspaceID,
+			FileName:      file.Filename,
+			FilePath:      file.Filepath,
+			MimeType:      file.Mimetype,
+			Size:          file.Size,
+			Categories:    categories,
+			CreatedAt:     file.UploadedAt,
+			Metadata:      file.Metadata,
+			ScanStatus:    file.ScanStatus,
+			ScanSignature: file.ScanSignature,
 		})
 	}
 
@@ -338,9 +519,6 @@ func (s *FileService) ListUserFilesWithOptionalCategory(userID uint, categoryID
 }
 
 func (s *FileService) GetStorageSummary(userID uint) (*dto.StorageSummaryResponse, error) {
-	// Max storage: 5 GiB
-	const maxBytes int64 = 5 * 1024 * 1024 * 1024
-
 	used, err := s.repository.TotalUserStorage(userID)
 	if err != nil {
 		return nil, err
@@ -357,26 +535,29 @@ func (s *FileService) GetStorageSummary(userID uint) (*dto.StorageSummaryRespons
 			categories = append(categories, dto.CategorySimple{ID: cat.ID, Name: cat.Name, Color: cat.Color})
 		}
 		f := dto.FileResponse{
-			ID:          latest.ID,
-			UserId:      latest.UserID,
-			WorkspaceId: latest.WorkspaceID,
-			FileName:    latest.Filename,
-			FilePath:    latest.Filepath,
-			MimeType:    latest.Mimetype,
-			Size:        latest.Size,
-			Categories:  categories,
-			CreatedAt:   latest.UploadedAt,
+			ID:            latest.ID,
+			UserId:        latest.UserID,
+			WorkspaceId:   latest.WorkspaceID,
+			FileName:      latest.Filename,
+			FilePath:      latest.Filepath,
+			MimeType:      latest.Mimetype,
+			Size:          latest.Size,
+			Categories:    categories,
+			CreatedAt:     latest.UploadedAt,
+			Metadata:      latest.Metadata,
+			ScanStatus:    latest.ScanStatus,
+			ScanSignature: latest.ScanSignature,
 		}
 		latestDtos = append(latestDtos, f)
 	}
 
-	remaining := maxBytes - used
+	remaining := maxStorageBytes - used
 	if remaining < 0 {
 		remaining = 0
 	}
 
 	return &dto.StorageSummaryResponse{
-		MaxBytes:       maxBytes,
+		MaxBytes:       maxStorageBytes,
 		UsedBytes:      used,
 		RemainingBytes: remaining,
 		LatestFiles:    latestDtos,
@@ -401,17 +582,456 @@ func (s *FileService) ListFilesByWorkspace(userID uint, workspaceID uint) ([]dto
 			categories = append(categories, dto.CategorySimple{ID: cat.ID, Name: cat.Name, Color: cat.Color})
 		}
 		responses = append(responses, dto.FileResponse{
-			ID:          f.ID,
-			UserId:      f.UserID,
-			WorkspaceId: f.WorkspaceID,
-			FileName:    f.Filename,
-			FilePath:    f.Filepath,
-			MimeType:    f.Mimetype,
-			Size:        f.Size,
-			Categories:  categories,
-			CreatedAt:   f.UploadedAt,
+			ID:            f.ID,
+			UserId:        f.UserID,
+			WorkspaceId:   f.WorkspaceID,
+			FileName:      f.Filename,
+			FilePath:      f.Filepath,
+			MimeType:      f.Mimetype,
+			Size:          f.Size,
+			Categories:    categories,
+			CreatedAt:     f.UploadedAt,
+			Metadata:      f.Metadata,
+			ScanStatus:    f.ScanStatus,
+			ScanSignature: f.ScanSignature,
 		})
 	}
 
 	return responses, nil
 }
+
+// BulkAction menjalankan satu aksi untuk banyak file. Izin dicek per file;
+// file yang lolos diproses dalam satu operasi database. Dengan AllOrNothing,
+// tidak ada perubahan sama sekali jika ada file yang gagal dicek.
+func (s *FileService) BulkAction(userID uint, req dto.BulkFileRequest) (*dto.BulkFileResponse, error) {
+	var categories map[uint]models.Category
+
+	switch req.Action {
+	case BulkActionDelete:
+	case BulkActionMove:
+		if req.WorkspaceID != nil {
+			if err := s.checkFileWrite(userID, userID, req.WorkspaceID); err != nil {
+				return nil, fmt.Errorf("target workspace: %w", err)
+			}
+		}
+	case BulkActionAssignCategories, BulkActionRemoveCategories:
+		if len(req.CategoryIDs) == 0 {
+			return nil, fmt.Errorf("category_ids is required for %s", req.Action)
+		}
+		loaded, err := s.loadCategories(req.CategoryIDs)
+		if err != nil {
+			return nil, err
+		}
+		categories = loaded
+	case BulkActionMoveFolder:
+		if req.FolderID == nil {
+			return nil, fmt.Errorf("folder_id is required for %s", req.Action)
+		}
+		loaded, err := s.loadCategories(folderIDs(req))
+		if err != nil {
+			return nil, err
+		}
+		categories = loaded
+	default:
+		return nil, fmt.Errorf("unsupported action %q: use delete, move, assign_categories, remove_categories or move_folder", req.Action)
+	}
+
+	files, err := s.repository.FindByIDs(req.FileIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+
+	response := &dto.BulkFileResponse{Action: req.Action}
+	seen := make(map[uint]bool, len(req.FileIDs))
+	var allowed []uint
+	var blobs []string
+
+	for _, id := range req.FileIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		file, ok := byID[id]
+		if !ok {
+			response.Results = append(response.Results, dto.BulkFileItemResult{FileID: id, Error: "file not found"})
+			continue
+		}
+		if err := s.checkBulkItem(userID, &file, req, categories); err != nil {
+			response.Results = append(response.Results, dto.BulkFileItemResult{FileID: id, Error: err.Error()})
+			continue
+		}
+
+		allowed = append(allowed, id)
+		blobs = append(blobs, file.Filepath)
+		response.Results = append(response.Results, dto.BulkFileItemResult{FileID: id, Success: true})
+	}
+
+	failed := len(response.Results) - len(allowed)
+	if req.AllOrNothing && failed > 0 {
+		for i := range response.Results {
+			if response.Results[i].Success {
+				response.Results[i].Success = false
+				response.Results[i].Error = "not applied"
+			}
+		}
+		response.Failed = len(response.Results)
+		return response, apperrors.ErrBulkAborted
+	}
+
+	if len(allowed) > 0 {
+		var err error
+		switch req.Action {
+		case BulkActionDelete:
+			err = s.repository.BulkDelete(allowed)
+		case BulkActionMove:
+			err = s.repository.BulkMove(allowed, req.WorkspaceID, userID)
+		case BulkActionAssignCategories:
+			err = s.repository.BulkAssignCategories(allowed, req.CategoryIDs)
+		case BulkActionRemoveCategories:
+			err = s.repository.BulkRemoveCategories(allowed, req.CategoryIDs)
+		case BulkActionMoveFolder:
+			err = s.repository.BulkMoveFolder(allowed, req.FromFolderID, *req.FolderID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply %s: %w", req.Action, err)
+		}
+
+		// blob dihapus setelah metadata berhasil dihapus
+		if req.Action == BulkActionDelete {
+			removeBlobs(blobs)
+		}
+		if req.Action == BulkActionMove {
+			s.applyStripPolicy(allowed, req.WorkspaceID)
+		}
+		for _, id := range allowed {
+			file := byID[id]
+			switch req.Action {
+			case BulkActionDelete:
+				s.publish(fileEvent(models.EventFileDeleted, userID, &file))
+			case BulkActionMove:
+				s.publish(moveEvents(userID, file, req.WorkspaceID)...)
+			}
+		}
+	}
+
+	response.Succeeded = len(allowed)
+	response.Failed = failed
+	return response, nil
+}
+
+// checkBulkItem mengecek izin dan aturan aksi untuk satu file
+func (s *FileService) checkBulkItem(userID uint, file *models.File, req dto.BulkFileRequest, categories map[uint]models.Category) error {
+	if err := s.checkFileWrite(userID, file.UserID, file.WorkspaceID); err != nil {
+		return err
+	}
+
+	switch req.Action {
+	case BulkActionMove:
+		sameScope := (file.WorkspaceID == nil && req.WorkspaceID == nil) ||
+			(file.WorkspaceID != nil && req.WorkspaceID != nil && *file.WorkspaceID == *req.WorkspaceID)
+		if sameScope {
+			return fmt.Errorf("file is already in the target space")
+		}
+		// uploader tidak berubah, jadi hanya file milik sendiri yang bisa masuk personal space
+		if req.WorkspaceID == nil && file.UserID != userID {
+			return fmt.Errorf("unauthorized: only the uploader can move a file to their personal space")
+		}
+	case BulkActionAssignCategories:
+		return checkCategoryScope(categories, userID, file.WorkspaceID, req.CategoryIDs)
+	case BulkActionMoveFolder:
+		return checkCategoryScope(categories, userID, file.WorkspaceID, folderIDs(req))
+	}
+	return nil
+}
+
+// folderIDs: folder tujuan dan (jika ada) folder asal untuk action move_folder
+func folderIDs(req dto.BulkFileRequest) []uint {
+	ids := []uint{*req.FolderID}
+	if req.FromFolderID != nil {
+		ids = append(ids, *req.FromFolderID)
+	}
+	return ids
+}
+
+// checkQuota memastikan tambahan bytes masih muat di storage user
+func (s *FileService) checkQuota(userID uint, extra int64) error {
+	if extra <= 0 {
+		return nil
+	}
+	used, err := s.repository.TotalUserStorage(userID)
+	if err != nil {
+		return err
+	}
+	if used+extra > maxStorageBytes {
+		return fmt.Errorf("storage quota exceeded")
+	}
+	return nil
+}
+
+// checkFileRead: pemilik file personal, member workspace, atau penerima share
+// dengan izin download/edit
+func (s *FileService) checkFileRead(userID uint, file *models.File) error {
+	if shared, err := s.repository.IsSharedWith(file.ID, userID); err == nil && shared {
+		return nil
+	}
+	if file.WorkspaceID == nil {
+		if file.UserID != userID {
+			return fmt.Errorf("unauthorized: file does not belong to user")
+		}
+		return nil
+	}
+	if _, err := s.workspaceRepo.FindMember(*file.WorkspaceID, userID); err != nil {
+		return fmt.Errorf("unauthorized: you are not a member of this workspace")
+	}
+	return nil
+}
+
+// MoveFile memindahkan file antara personal space dan workspace. Uploader tetap,
+// jadi pemakaian storage tidak berubah; kategori dibawa jika ada yang bernama
+// sama di tujuan.
+func (s *FileService) MoveFile(userID, fileID uint, req dto.FileTargetRequest) (*dto.FileResponse, error) {
+	file, err := s.repository.FindByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err := s.checkFileWrite(userID, file.UserID, file.WorkspaceID); err != nil {
+		return nil, err
+	}
+	if err := s.checkFileWrite(userID, userID, req.WorkspaceID); err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	sameScope := (file.WorkspaceID == nil && req.WorkspaceID == nil) ||
+		(file.WorkspaceID != nil && req.WorkspaceID != nil && *file.WorkspaceID == *req.WorkspaceID)
+	if sameScope {
+		return nil, fmt.Errorf("file is already in the target space")
+	}
+
+	// personal space ditentukan oleh uploader, bukan oleh user yang memindahkan
+	if req.WorkspaceID == nil && file.UserID != userID {
+		return nil, fmt.Errorf("unauthorized: only the uploader can move a file to their personal space")
+	}
+
+	if err := s.repository.BulkMove([]uint{fileID}, req.WorkspaceID, userID); err != nil {
+		return nil, fmt.Errorf("failed to move file: %w", err)
+	}
+	s.applyStripPolicy([]uint{fileID}, req.WorkspaceID)
+	s.publish(moveEvents(userID, *file, req.WorkspaceID)...)
+	return s.GetFileByID(fileID)
+}
+
+// CopyFile menyalin file (termasuk blob-nya) ke personal space atau workspace
+func (s *FileService) CopyFile(userID, fileID uint, req dto.FileTargetRequest) (*dto.FileResponse, error) {
+	file, err := s.repository.FindByID(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	if err := s.checkFileRead(userID, file); err != nil {
+		return nil, err
+	}
+	if file.ScanStatus == models.ScanInfected {
+		return nil, apperrors.ErrFileQuarantined
+	}
+	if err := s.checkFileWrite(userID, userID, req.WorkspaceID); err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	if err := s.checkQuota(userID, file.Size); err != nil {
+		return nil, err
+	}
+
+	newName := uuid.New().String() + filepath.Ext(file.Filepath)
+	newPath := filepath.Join(s.basePath, newName)
+	if err := copyBlob(file.Filepath, newPath); err != nil {
+		return nil, fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	metadata, size := file.Metadata, file.Size
+	if isImageMime(file.Mimetype) {
+		if metadata, size, err = s.prepareImage(newPath, req.WorkspaceID); err != nil {
+			os.Remove(newPath)
+			return nil, err
+		}
+	}
+
+	dup := &models.File{
+		Filename:     file.Filename,
+		OriginalName: file.OriginalName,
+		Filepath:     newPath,
+		Mimetype:     file.Mimetype,
+		Size:         size,
+		UserID:       userID,
+		WorkspaceID:  req.WorkspaceID,
+		UploadedAt:   time.Now(),
+		Metadata:     metadata,
+	}
+	// isi salinan sama dengan sumbernya, jadi hasil scan bersih ikut disalin
+	if file.ScanStatus == models.ScanClean {
+		dup.ScanStatus = models.ScanClean
+		dup.ScannedAt = file.ScannedAt
+	}
+	if err := s.repository.CopyFile(file.ID, dup); err != nil {
+		os.Remove(newPath)
+		return nil, fmt.Errorf("failed to store file metadata: %w", err)
+	}
+	if dup.ScanStatus != models.ScanClean {
+		s.scans.Enqueue(*dup)
+	}
+	s.thumbnails.Enqueue(*dup)
+	s.publish(fileEvent(models.EventFileUploaded, userID, dup))
+	return s.GetFileByID(dup.ID)
+}
+
+// publish meneruskan event ke subscriber; events boleh nil (mis. di command)
+func (s *FileService) publish(events ...Event) {
+	if s.events == nil {
+		return
+	}
+	for _, event := range events {
+		s.events.Publish(event)
+	}
+}
+
+// prepareImage mengekstrak metadata gambar dan, jika workspace tujuan
+// mewajibkan, menghapus GPS/EXIF personal dari blob. Ukuran baru dikembalikan
+// karena blok XMP/IPTC ikut dibuang.
+func (s *FileService) prepareImage(path string, workspaceID *uint) (*models.FileMetadata, int64, error) {
+	stripped := false
+	if s.stripsMetadata(workspaceID) {
+		ok, err := stripImageMetadata(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to strip image metadata: %w", err)
+		}
+		stripped = ok
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	metadata, err := extractImageMetadata(path)
+	if err != nil {
+		// format gambar yang tidak bisa dibaca disimpan tanpa metadata
+		return nil, info.Size(), nil
+	}
+	metadata.Stripped = stripped
+	return metadata, info.Size(), nil
+}
+
+func (s *FileService) stripsMetadata(workspaceID *uint) bool {
+	if workspaceID == nil {
+		return false
+	}
+	workspace, err := s.workspaceRepo.FindByID(*workspaceID)
+	return err == nil && workspace.StripImageMetadata
+}
+
+// applyStripPolicy membersihkan gambar yang baru dipindah ke workspace yang
+// mewajibkan strip. Kegagalan hanya di-log karena file sudah dipindah.
+func (s *FileService) applyStripPolicy(fileIDs []uint, workspaceID *uint) {
+	if !s.stripsMetadata(workspaceID) {
+		return
+	}
+	files, err := s.repository.FindByIDs(fileIDs)
+	if err != nil {
+		log.Printf("strip metadata: failed to load files: %v", err)
+		return
+	}
+	for _, f := range files {
+		if !isImageMime(f.Mimetype) || (f.Metadata != nil && f.Metadata.Stripped) {
+			continue
+		}
+		metadata, size, err := s.prepareImage(f.Filepath, workspaceID)
+		if err != nil {
+			log.Printf("strip metadata: file %d: %v", f.ID, err)
+			continue
+		}
+		if err := s.repository.UpdateMetadata(f.ID, metadata, size); err != nil {
+			log.Printf("strip metadata: file %d: %v", f.ID, err)
+		}
+	}
+}
+
+func copyBlob(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// GetDownloadPath mengembalikan path blob jika user boleh membaca file dan
+// file boleh diunduh menurut hasil scan malware dan kebijakan workspace-nya
+func (s *FileService) GetDownloadPath(userID, fileID uint) (string, error) {
+	file, err := s.repository.FindByID(fileID)
+	if err != nil {
+		return "", apperrors.ErrFileNotFound
+	}
+	if err := s.checkFileRead(userID, file); err != nil {
+		return "", fmt.Errorf("%w: %v", apperrors.ErrFileAccessDenied, err)
+	}
+	if err := checkScanPolicy(file, s.requiresCleanScan(file.WorkspaceID)); err != nil {
+		return "", err
+	}
+	return file.Filepath, nil
+}
+
+func (s *FileService) requiresCleanScan(workspaceID *uint) bool {
+	if workspaceID == nil {
+		return false
+	}
+	workspace, err := s.workspaceRepo.FindByID(*workspaceID)
+	return err == nil && workspace.RequireCleanScan
+}
+
+// GetThumbnail: akses dicek sebelum thumbnail dijadwalkan, supaya file milik
+// orang lain tidak ikut dirender
+func (s *FileService) GetThumbnail(userID, fileID uint, size, format string) (string, string, error) {
+	file, err := s.repository.FindByID(fileID)
+	if err != nil {
+		return "", "", apperrors.ErrFileNotFound
+	}
+	if err := s.checkFileRead(userID, file); err != nil {
+		return "", "", fmt.Errorf("%w: %v", apperrors.ErrFileAccessDenied, err)
+	}
+	// preview tunduk pada kebijakan yang sama dengan download
+	if err := checkScanPolicy(file, s.requiresCleanScan(file.WorkspaceID)); err != nil {
+		return "", "", err
+	}
+	return s.thumbnails.Get(*file, size, format)
+}
+
+func (s *FileService) ThumbnailPlaceholder(size, format string) ([]byte, error) {
+	return s.thumbnails.Placeholder(size, format)
+}
+
+// TransformImage mengikuti aturan download: akses baca dan kebijakan scan
+// workspace dicek sebelum gambar diproses
+func (s *FileService) TransformImage(userID, fileID uint, opts ImageTransformOptions) (string, error) {
+	file, err := s.repository.FindByID(fileID)
+	if err != nil {
+		return "", apperrors.ErrFileNotFound
+	}
+	if err := s.checkFileRead(userID, file); err != nil {
+		return "", fmt.Errorf("%w: %v", apperrors.ErrFileAccessDenied, err)
+	}
+	if err := checkScanPolicy(file, s.requiresCleanScan(file.WorkspaceID)); err != nil {
+		return "", err
+	}
+	return s.images.Transform(*file, opts)
+}
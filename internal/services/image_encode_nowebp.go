@@ -0,0 +1,20 @@
+//go:build !cgo
+
+package services
+
+import (
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// tanpa cgo tidak ada encoder WebP, permintaan WebP dilayani sebagai JPEG
+const webpSupported = false
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	if format == FormatPNG {
+		return imaging.Encode(w, img, imaging.PNG)
+	}
+	return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(quality))
+}
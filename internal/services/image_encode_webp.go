@@ -0,0 +1,27 @@
+//go:build cgo
+
+package services
+
+import (
+	"image"
+	"io"
+
+	"github.com/bep/gowebp/libwebp"
+	"github.com/bep/gowebp/libwebp/webpoptions"
+	"github.com/disintegration/imaging"
+)
+
+const webpSupported = true
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatWebP:
+		return libwebp.Encode(w, img, webpoptions.EncodingOptions{
+			Quality:        quality,
+			EncodingPreset: webpoptions.EncodingPresetPhoto,
+		})
+	case FormatPNG:
+		return imaging.Encode(w, img, imaging.PNG)
+	}
+	return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(quality))
+}
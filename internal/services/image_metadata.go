@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/big"
+	"os"
+	"strings"
+
+	"vasvault/internal/models"
+	"vasvault/pkg/imagemeta"
+
+	"github.com/disintegration/imaging"
+	"github.com/rwcarlsen/goexif/exif"
+	_ "golang.org/x/image/webp"
+)
+
+// extractImageMetadata membaca ukuran gambar, EXIF dan IPTC. Gambar tanpa
+// metadata tetap mendapat ukuran; format yang tidak dikenali mengembalikan error.
+func extractImageMetadata(path string) (*models.FileMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	meta := &models.FileMetadata{Width: config.Width, Height: config.Height}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	blocks, err := imagemeta.Read(f)
+	if err != nil {
+		// GIF, WebP, dll: hanya ukuran
+		return meta, nil
+	}
+
+	if len(blocks.Exif) > 0 {
+		if x, err := exif.Decode(bytes.NewReader(blocks.Exif)); err == nil {
+			applyExif(meta, x)
+		}
+	}
+	if iptc := imagemeta.ParseIPTC(blocks.IPTC); iptc != nil {
+		meta.Title = iptc.Title
+		meta.Caption = iptc.Caption
+		meta.Creator = iptc.Creator
+		meta.Copyright = iptc.Copyright
+		meta.City = iptc.City
+		meta.Country = iptc.Country
+		meta.Keywords = iptc.Keywords
+	}
+
+	// orientasi 5-8 memutar gambar 90 derajat
+	if meta.Orientation >= 5 && meta.Orientation <= 8 {
+		meta.Width, meta.Height = meta.Height, meta.Width
+	}
+	return meta, nil
+}
+
+func applyExif(meta *models.FileMetadata, x *exif.Exif) {
+	str := func(name exif.FieldName) string {
+		tag, err := x.Get(name)
+		if err != nil {
+			return ""
+		}
+		s, err := tag.StringVal()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+	}
+	rat := func(name exif.FieldName) *big.Rat {
+		tag, err := x.Get(name)
+		if err != nil {
+			return nil
+		}
+		r, err := tag.Rat(0)
+		if err != nil || r.Denom().Sign() == 0 {
+			return nil
+		}
+		return r
+	}
+
+	meta.CameraMake = str(exif.Make)
+	meta.CameraModel = str(exif.Model)
+	meta.LensModel = str(exif.LensModel)
+
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if o, err := tag.Int(0); err == nil && o >= 1 && o <= 8 {
+			meta.Orientation = o
+		}
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			meta.ISO = iso
+		}
+	}
+	if r := rat(exif.FocalLength); r != nil {
+		f, _ := r.Float64()
+		meta.FocalLength = fmt.Sprintf("%gmm", roundTo(f, 1))
+	}
+	if r := rat(exif.FNumber); r != nil {
+		f, _ := r.Float64()
+		meta.FNumber = fmt.Sprintf("f/%g", roundTo(f, 1))
+	}
+	if r := rat(exif.ExposureTime); r != nil {
+		if r.Cmp(big.NewRat(1, 1)) < 0 && r.Num().IsInt64() && r.Num().Int64() == 1 {
+			meta.ExposureTime = r.String() + "s"
+		} else {
+			f, _ := r.Float64()
+			meta.ExposureTime = fmt.Sprintf("%gs", roundTo(f, 4))
+		}
+	}
+	if t, err := x.DateTime(); err == nil {
+		meta.CapturedAt = &t
+	}
+
+	if lat, long, err := x.LatLong(); err == nil && (lat != 0 || long != 0) {
+		gps := &models.GPSInfo{Latitude: lat, Longitude: long}
+		if r := rat(exif.GPSAltitude); r != nil {
+			alt, _ := r.Float64()
+			if tag, err := x.Get(exif.GPSAltitudeRef); err == nil {
+				if ref, err := tag.Int(0); err == nil && ref == 1 {
+					alt = -alt // di bawah permukaan laut
+				}
+			}
+			gps.Altitude = &alt
+		}
+		meta.GPS = gps
+	}
+}
+
+func roundTo(f float64, decimals int) float64 {
+	pow := 1.0
+	for i := 0; i < decimals; i++ {
+		pow *= 10
+	}
+	if f < 0 {
+		return -float64(int64(-f*pow+0.5)) / pow
+	}
+	return float64(int64(f*pow+0.5)) / pow
+}
+
+// stripImageMetadata menulis ulang JPEG/PNG tanpa GPS dan EXIF personal.
+// false berarti formatnya tidak didukung dan file tidak diubah.
+func stripImageMetadata(path string) (bool, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	tmp := path + ".strip"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return false, err
+	}
+	if err := imagemeta.Strip(in, out); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		if err == imagemeta.ErrUnsupported {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	in.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// orientImage menerapkan orientasi EXIF sehingga gambar tampil tegak
+func orientImage(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vasvault/internal/models"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/sync/singleflight"
+)
+
+// Nilai yang diizinkan untuk transformasi. Dengan allow-list, client tidak bisa
+// membuat variasi ukuran tanpa batas yang memenuhi cache dan CPU.
+var (
+	allowedImageDimensions = []int{64, 128, 256, 320, 480, 640, 800, 1024, 1280, 1600, 1920, 2560}
+	allowedImageQualities  = []int{50, 60, 70, 80, 90}
+)
+
+// Mode fit untuk transformasi
+const (
+	ImageFitContain = "contain" // muat di dalam kotak, rasio dijaga, tanpa upscale
+	ImageFitCover   = "cover"   // penuhi kotak, kelebihan dipotong dari tengah
+	ImageFitFill    = "fill"    // tarik tepat ke ukuran kotak
+)
+
+const (
+	defaultImageQuality = 80
+
+	// gambar sumber di atas batas ini tidak di-decode (decompression bomb)
+	maxSourcePixels = 50 * 1000 * 1000
+
+	// lama menunggu slot decode sebelum request ditolak
+	imageSlotTimeout = 10 * time.Second
+
+	defaultImageCacheMB = 1024
+
+	// subdirektori di thumbs/<nama blob>/ untuk hasil transformasi
+	transformCacheDir = "transform"
+)
+
+type ImageTransformOptions struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+type ImageServiceInterface interface {
+	Transform(file models.File, opts ImageTransformOptions) (string, error)
+}
+
+type ImageService struct {
+	cacheRoot     string
+	maxCacheBytes int64
+	cacheBytes    atomic.Int64 // perkiraan, dikoreksi setiap eviction
+	evicting      sync.Mutex
+
+	slots chan struct{}
+	group singleflight.Group
+}
+
+// NewImageService: cache transformasi disimpan di <basePath>/thumbs bersama
+// thumbnail, sehingga ikut terhapus bersama file-nya
+func NewImageService(basePath string) ImageServiceInterface {
+	s := &ImageService{
+		cacheRoot:     filepath.Join(basePath, "thumbs"),
+		maxCacheBytes: imageCacheBytesFromEnv(),
+		slots:         make(chan struct{}, imageConcurrencyFromEnv()),
+	}
+	go func() {
+		total, _ := s.scanCache()
+		s.cacheBytes.Store(total)
+	}()
+	return s
+}
+
+func imageCacheBytesFromEnv() int64 {
+	raw := os.Getenv("IMAGE_CACHE_MAX_MB")
+	if raw == "" {
+		return defaultImageCacheMB << 20
+	}
+	mb, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || mb <= 0 {
+		log.Printf("invalid IMAGE_CACHE_MAX_MB %q, using %d", raw, defaultImageCacheMB)
+		return defaultImageCacheMB << 20
+	}
+	return mb << 20
+}
+
+func imageConcurrencyFromEnv() int {
+	raw := os.Getenv("IMAGE_TRANSFORM_CONCURRENCY")
+	if raw == "" {
+		return runtime.NumCPU()
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		log.Printf("invalid IMAGE_TRANSFORM_CONCURRENCY %q, using %d", raw, runtime.NumCPU())
+		return runtime.NumCPU()
+	}
+	return n
+}
+
+// Transform mengembalikan path hasil transformasi, dari cache jika ada
+func (s *ImageService) Transform(file models.File, opts ImageTransformOptions) (string, error) {
+	if !isImageMime(file.Mimetype) {
+		return "", fmt.Errorf("%w: only images can be transformed", apperrors.ErrInvalidTransform)
+	}
+	opts, err := normalizeImageOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	path := transformPath(file.Filepath, opts)
+	if _, err := os.Stat(path); err == nil {
+		// mtime dipakai sebagai waktu akses terakhir untuk eviction
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return path, nil
+	}
+
+	// request bersamaan untuk hasil yang sama hanya di-decode sekali
+	_, err, _ = s.group.Do(path, func() (interface{}, error) {
+		return nil, s.render(file, opts, path)
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *ImageService) render(file models.File, opts ImageTransformOptions, path string) error {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-time.After(imageSlotTimeout):
+		return apperrors.ErrImageBusy
+	}
+
+	if err := checkSourcePixels(file.Filepath); err != nil {
+		return err
+	}
+	src, err := openImage(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := writeImage(path, resizeImage(src, opts), opts.Format, opts.Quality); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if s.cacheBytes.Add(info.Size()) > s.maxCacheBytes {
+			go s.evict()
+		}
+	}
+	return nil
+}
+
+func normalizeImageOptions(opts ImageTransformOptions) (ImageTransformOptions, error) {
+	if opts.Width == 0 && opts.Height == 0 {
+		return opts, fmt.Errorf("%w: w or h is required", apperrors.ErrInvalidTransform)
+	}
+	for _, v := range []int{opts.Width, opts.Height} {
+		if v != 0 && !containsInt(allowedImageDimensions, v) {
+			return opts, fmt.Errorf("%w: w and h must be one of %v", apperrors.ErrInvalidTransform, allowedImageDimensions)
+		}
+	}
+
+	switch opts.Fit {
+	case "":
+		opts.Fit = ImageFitContain
+	case ImageFitContain:
+	case ImageFitCover, ImageFitFill:
+		if opts.Width == 0 || opts.Height == 0 {
+			return opts, fmt.Errorf("%w: fit=%s requires both w and h", apperrors.ErrInvalidTransform, opts.Fit)
+		}
+	default:
+		return opts, fmt.Errorf("%w: fit must be contain, cover or fill", apperrors.ErrInvalidTransform)
+	}
+
+	switch opts.Format {
+	case "":
+		opts.Format = FormatJPEG
+	case FormatJPEG, FormatPNG:
+	case FormatWebP:
+		if !webpSupported {
+			opts.Format = FormatJPEG
+		}
+	default:
+		return opts, fmt.Errorf("%w: format must be jpeg, png or webp", apperrors.ErrInvalidTransform)
+	}
+
+	if opts.Quality == 0 {
+		opts.Quality = defaultImageQuality
+	} else if !containsInt(allowedImageQualities, opts.Quality) {
+		return opts, fmt.Errorf("%w: q must be one of %v", apperrors.ErrInvalidTransform, allowedImageQualities)
+	}
+	if opts.Format == FormatPNG {
+		opts.Quality = 0 // PNG lossless, q tidak membuat variasi cache
+	}
+	return opts, nil
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSourcePixels(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > maxSourcePixels {
+		return apperrors.ErrImageTooLarge
+	}
+	return nil
+}
+
+func resizeImage(src image.Image, opts ImageTransformOptions) image.Image {
+	switch opts.Fit {
+	case ImageFitCover:
+		return imaging.Fill(src, opts.Width, opts.Height, imaging.Center, imaging.Lanczos)
+	case ImageFitFill:
+		return imaging.Resize(src, opts.Width, opts.Height, imaging.Lanczos)
+	}
+
+	// contain: sisi yang tidak diisi dibatasi ukuran sumber, jadi rasio tetap
+	bounds := src.Bounds()
+	width, height := opts.Width, opts.Height
+	if width == 0 {
+		width = bounds.Dx()
+	}
+	if height == 0 {
+		height = bounds.Dy()
+	}
+	return imaging.Fit(src, width, height, imaging.Lanczos)
+}
+
+func transformPath(blobPath string, opts ImageTransformOptions) string {
+	name := fmt.Sprintf("%dx%d-%s-q%d", opts.Width, opts.Height, opts.Fit, opts.Quality)
+	ext := ".jpg"
+	switch opts.Format {
+	case FormatWebP:
+		ext = ".webp"
+	case FormatPNG:
+		ext = ".png"
+	}
+	return filepath.Join(thumbnailDir(blobPath), transformCacheDir, name+ext)
+}
+
+type cachedImage struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// scanCache menghitung isi cache transformasi dari disk. Disk menjadi acuan,
+// jadi entri yang terhapus bersama file-nya tidak perlu dilacak.
+func (s *ImageService) scanCache() (int64, []cachedImage) {
+	var total int64
+	var entries []cachedImage
+	_ = filepath.WalkDir(s.cacheRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Base(filepath.Dir(path)) != transformCacheDir {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		entries = append(entries, cachedImage{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return total, entries
+}
+
+// evict menghapus hasil transformasi yang paling lama tidak diakses sampai
+// cache kembali di bawah 90% batas
+func (s *ImageService) evict() {
+	if !s.evicting.TryLock() {
+		return
+	}
+	defer s.evicting.Unlock()
+
+	total, entries := s.scanCache()
+	target := s.maxCacheBytes / 10 * 9
+	if total > s.maxCacheBytes {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+		for _, e := range entries {
+			if total <= target {
+				break
+			}
+			if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+				log.Printf("image cache: failed to remove %s: %v", e.path, err)
+				continue
+			}
+			total -= e.size
+		}
+	}
+	s.cacheBytes.Store(total)
+}
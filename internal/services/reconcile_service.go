@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+)
+
+const (
+	reconcileBatch = 500
+
+	// blob yang lebih muda dari ini bisa jadi upload yang barisnya belum dibuat
+	DefaultReconcileMinAge = time.Hour
+)
+
+// mode job rekonsiliasi harian, lihat STORAGE_RECONCILE
+const (
+	reconcileOff    = "off"
+	reconcileReport = "report"
+	reconcileFix    = "fix"
+)
+
+type ReconcileOptions struct {
+	Fix    bool
+	MinAge time.Duration
+}
+
+type ReconcileServiceInterface interface {
+	Reconcile(ctx context.Context, opts ReconcileOptions) (*dto.ReconcileReport, error)
+}
+
+// ReconcileService mencocokkan blob di storage dengan tabel files. Upload
+// menulis blob sebelum barisnya dan delete menghapus blob sebelum barisnya,
+// sehingga kegagalan di tengah meninggalkan sisa di salah satu sisi.
+type ReconcileService struct {
+	repository     repositories.FileRepositoryInterface
+	uploadPath     string
+	quarantinePath string
+}
+
+type reconcileJob struct {
+	Fix bool `json:"fix"`
+}
+
+// NewReconcileService: dengan jobs != nil rekonsiliasi juga dijadwalkan setiap
+// hari pukul 03:00 sesuai STORAGE_RECONCILE
+func NewReconcileService(repo repositories.FileRepositoryInterface, jobs *jobqueue.Queue, uploadPath, quarantinePath string) (ReconcileServiceInterface, error) {
+	service := &ReconcileService{
+		repository:     repo,
+		uploadPath:     uploadPath,
+		quarantinePath: quarantinePath,
+	}
+	if jobs == nil {
+		return service, nil
+	}
+
+	job := jobqueue.Register(jobs, "reconcile_storage", jobqueue.HandlerOptions{MaxAttempts: 1, Timeout: time.Hour}, service.runJob)
+	mode := reconcileModeFromEnv()
+	if mode == reconcileOff {
+		return service, nil
+	}
+	if err := job.Schedule("reconcile-storage", "0 3 * * *", reconcileJob{Fix: mode == reconcileFix}); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func reconcileModeFromEnv() string {
+	switch raw := os.Getenv("STORAGE_RECONCILE"); raw {
+	case "":
+		return reconcileReport
+	case reconcileOff, reconcileReport, reconcileFix:
+		return raw
+	default:
+		log.Printf("invalid STORAGE_RECONCILE %q, using %s", raw, reconcileReport)
+		return reconcileReport
+	}
+}
+
+func (s *ReconcileService) runJob(ctx context.Context, job reconcileJob) error {
+	report, err := s.Reconcile(ctx, ReconcileOptions{Fix: job.Fix, MinAge: DefaultReconcileMinAge})
+	if err != nil {
+		return err
+	}
+
+	for _, b := range report.OrphanBlobs {
+		log.Printf("reconcile: orphan blob %s (%d bytes)", b.Path, b.Size)
+	}
+	for _, f := range report.MissingBlobs {
+		log.Printf("reconcile: file %d points to missing blob %s", f.FileID, f.Filepath)
+	}
+	for _, t := range report.OrphanThumbnails {
+		log.Printf("reconcile: orphan thumbnail %s", t)
+	}
+	for _, e := range report.Errors {
+		log.Printf("reconcile: %s", e)
+	}
+	log.Printf("reconcile: %d orphan blobs, %d files with missing blobs, %d orphan thumbnails (fixed: %t)",
+		len(report.OrphanBlobs), len(report.MissingBlobs), len(report.OrphanThumbnails), report.Fixed)
+	return nil
+}
+
+// Reconcile melaporkan blob tanpa baris, baris tanpa blob dan thumbnail tanpa
+// blob. Dengan Fix ketiganya dihapus.
+func (s *ReconcileService) Reconcile(ctx context.Context, opts ReconcileOptions) (*dto.ReconcileReport, error) {
+	report := &dto.ReconcileReport{
+		Fixed:            opts.Fix,
+		StartedAt:        time.Now(),
+		OrphanBlobs:      []dto.OrphanBlob{},
+		MissingBlobs:     []dto.MissingBlobFile{},
+		OrphanThumbnails: []string{},
+	}
+
+	// folder upload yang tidak ter-mount tidak boleh dianggap kosong
+	if _, err := os.Stat(s.uploadPath); err != nil {
+		return nil, fmt.Errorf("upload folder is not available: %w", err)
+	}
+
+	// storage dibaca sebelum tabel: blob yang sedang di-upload atau di-rename
+	// sudah punya baris saat tabel dibaca
+	blobs, err := listBlobs(s.uploadPath, s.quarantinePath)
+	if err != nil {
+		return nil, err
+	}
+	thumbs, err := s.listThumbnails()
+	if err != nil {
+		return nil, err
+	}
+	referenced, err := s.referencedBlobs()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := report.StartedAt.Add(-opts.MinAge)
+	report.BlobsChecked = len(blobs)
+	for _, b := range blobs {
+		if !referenced[blobKey(b.Path)] && b.ModTime.Before(cutoff) {
+			report.OrphanBlobs = append(report.OrphanBlobs, b)
+		}
+	}
+	for _, t := range thumbs {
+		if !referenced[blobKey(t.parent)] && t.modTime.Before(cutoff) {
+			report.OrphanThumbnails = append(report.OrphanThumbnails, t.path)
+		}
+	}
+
+	var afterID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		files, err := s.repository.ListFilesAfter(afterID, reconcileBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			break
+		}
+		for _, f := range files {
+			report.FilesChecked++
+			if _, err := os.Stat(f.Filepath); err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					report.Errors = append(report.Errors, fmt.Sprintf("file %d: %v", f.ID, err))
+					continue
+				}
+				report.MissingBlobs = append(report.MissingBlobs, dto.MissingBlobFile{
+					FileID:      f.ID,
+					UserID:      f.UserID,
+					WorkspaceID: f.WorkspaceID,
+					Filepath:    f.Filepath,
+				})
+			}
+		}
+		afterID = files[len(files)-1].ID
+	}
+
+	if opts.Fix {
+		if err := s.fix(report); err != nil {
+			return nil, err
+		}
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// fix mengecek ulang setiap temuan sebelum menghapus, karena upload, rename
+// dan delete bisa berjalan selama rekonsiliasi
+func (s *ReconcileService) fix(report *dto.ReconcileReport) error {
+	referenced, err := s.referencedBlobs()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range report.OrphanBlobs {
+		if referenced[blobKey(b.Path)] {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		removeThumbnails(b.Path)
+	}
+	for _, t := range report.OrphanThumbnails {
+		if referenced[blobKey(thumbnailParent(s.uploadPath, t))] {
+			continue
+		}
+		if err := os.RemoveAll(t); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	if len(report.MissingBlobs) == 0 {
+		return nil
+	}
+	// semua blob hilang lebih mungkin berarti storage salah mount daripada data rusak
+	if len(report.MissingBlobs) == report.FilesChecked {
+		report.Errors = append(report.Errors, "every file is missing its blob, refusing to delete file rows; check the storage mount")
+		return nil
+	}
+
+	var ids []uint
+	var paths []string
+	for _, m := range report.MissingBlobs {
+		file, err := s.repository.FindByID(m.FileID)
+		if err != nil || file.Filepath != m.Filepath {
+			continue // sudah dihapus atau di-rename
+		}
+		if _, err := os.Stat(file.Filepath); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		ids = append(ids, file.ID)
+		paths = append(paths, file.Filepath)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := s.repository.PurgeFiles(ids); err != nil {
+		return fmt.Errorf("failed to delete file rows: %w", err)
+	}
+	for _, path := range paths {
+		removeThumbnails(path)
+	}
+	return nil
+}
+
+func (s *ReconcileService) referencedBlobs() (map[string]bool, error) {
+	paths, err := s.repository.ListBlobPaths()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		referenced[blobKey(p)] = true
+	}
+	return referenced, nil
+}
+
+// listBlobs: blob adalah file biasa langsung di dalam folder upload atau
+// karantina; subfolder (thumbs) dilewati
+func listBlobs(dirs ...string) ([]dto.OrphanBlob, error) {
+	var blobs []dto.OrphanBlob
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue // dihapus selama listing
+			}
+			blobs = append(blobs, dto.OrphanBlob{
+				Path:    filepath.Join(dir, e.Name()),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			})
+		}
+	}
+	return blobs, nil
+}
+
+type thumbnailEntry struct {
+	path    string
+	parent  string
+	modTime time.Time
+}
+
+// listThumbnails membaca thumbs/<nama blob>/ dan format lama
+// thumbs/<nama blob>.thumb.jpg
+func (s *ReconcileService) listThumbnails() ([]thumbnailEntry, error) {
+	root := filepath.Join(s.uploadPath, "thumbs")
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var thumbs []thumbnailEntry
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasSuffix(e.Name(), ".thumb.jpg") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(root, e.Name())
+		thumbs = append(thumbs, thumbnailEntry{
+			path:    path,
+			parent:  thumbnailParent(s.uploadPath, path),
+			modTime: info.ModTime(),
+		})
+	}
+	return thumbs, nil
+}
+
+func thumbnailParent(uploadPath, thumbPath string) string {
+	return filepath.Join(uploadPath, strings.TrimSuffix(filepath.Base(thumbPath), ".thumb.jpg"))
+}
+
+// blobKey menyamakan path relatif dan absolut
+func blobKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
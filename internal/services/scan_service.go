@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+	"vasvault/pkg/scanner"
+	apperrors "vasvault/pkg/utils"
+)
+
+// batas file pending/error yang dijadwalkan per run scan_backlog
+const scanBacklogBatch = 500
+
+// jeda sebelum job scan yang dead dicoba ulang oleh scan_backlog, supaya
+// clamd yang lama mati tidak membuat job terus berputar
+const scanRetryAfter = time.Hour
+
+type ScanServiceInterface interface {
+	Enqueue(file models.File)
+}
+
+type scanJob struct {
+	FileID uint `json:"file_id"`
+}
+
+// ScanService menscan blob lewat job queue. scanner nil berarti scan tidak
+// aktif: file tetap berstatus pending.
+type ScanService struct {
+	scanner        scanner.Scanner
+	repository     repositories.FileRepositoryInterface
+	quarantinePath string
+	jobs           *jobqueue.Queue
+	scanJob        jobqueue.Type[scanJob]
+}
+
+// NewScanService: quarantinePath harus di luar folder upload, supaya blob
+// karantina terpisah dari blob yang masih bisa diakses
+func NewScanService(s scanner.Scanner, repo repositories.FileRepositoryInterface, jobs *jobqueue.Queue, quarantinePath string) (ScanServiceInterface, error) {
+	service := &ScanService{
+		scanner:        s,
+		repository:     repo,
+		quarantinePath: quarantinePath,
+		jobs:           jobs,
+	}
+	if s == nil {
+		log.Printf("malware scanning disabled: CLAMD_ADDRESS is not set")
+		return service, nil
+	}
+
+	// clamd yang mati ditunggu dengan backoff; setelah 8 percobaan job
+	// menjadi dead dan file tetap berstatus error
+	service.scanJob = jobqueue.Register(jobs, "scan_file", jobqueue.HandlerOptions{
+		Queue:       "scan",
+		MaxAttempts: 8,
+		Timeout:     5 * time.Minute,
+	}, service.scan)
+
+	// file yang belum pernah dijadwalkan (upload sebelum scan diaktifkan) dan
+	// file error yang job-nya sudah dead
+	backlog := jobqueue.Register(jobs, "scan_backlog", jobqueue.HandlerOptions{Queue: "scan", MaxAttempts: 1}, service.scanBacklog)
+	if err := backlog.Schedule("scan-backlog", "@every 10m", struct{}{}); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// Enqueue menjadwalkan scan. Satu job per file, jadi file yang sudah
+// dijadwalkan tidak discan dua kali.
+func (s *ScanService) Enqueue(file models.File) {
+	if s.scanner == nil {
+		return
+	}
+	_, err := s.scanJob.Enqueue(scanJob{FileID: file.ID}, jobqueue.Unique(fmt.Sprintf("scan:%d", file.ID)))
+	if err != nil && !errors.Is(err, jobqueue.ErrDuplicate) {
+		log.Printf("scan: failed to enqueue file %d: %v", file.ID, err)
+	}
+}
+
+// scanBacklog menjadwalkan file pending dan error. Unique key job yang dead
+// tidak pernah dilepas, jadi job itu di-retry (attempt kembali dari nol)
+// daripada membuat job baru.
+func (s *ScanService) scanBacklog(ctx context.Context, _ struct{}) error {
+	files, err := s.repository.ListUnscanned(scanBacklogBatch)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		job, err := s.scanJob.Enqueue(scanJob{FileID: f.ID}, jobqueue.Unique(fmt.Sprintf("scan:%d", f.ID)))
+		if err == nil || !errors.Is(err, jobqueue.ErrDuplicate) {
+			if err != nil {
+				log.Printf("scan: failed to enqueue file %d: %v", f.ID, err)
+			}
+			continue
+		}
+		if job.Status != jobqueue.StatusDead || job.CompletedAt == nil || time.Since(*job.CompletedAt) < scanRetryAfter {
+			continue
+		}
+		if _, err := s.jobs.Retry(job.ID); err != nil {
+			log.Printf("scan: failed to retry job %d for file %d: %v", job.ID, f.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *ScanService) scan(ctx context.Context, job scanJob) error {
+	file, err := s.repository.FindByID(job.FileID)
+	if err != nil || (file.ScanStatus != models.ScanPending && file.ScanStatus != models.ScanError) {
+		return nil // file sudah dihapus atau sudah discan
+	}
+
+	result, err := s.scanBlob(ctx, file.Filepath)
+	if err != nil {
+		if err := s.repository.UpdateScanResult(file.ID, models.ScanError, "", file.Filepath); err != nil {
+			log.Printf("scan: file %d: failed to store result: %v", file.ID, err)
+		}
+		return err // dicoba ulang oleh job queue
+	}
+
+	status, path := models.ScanClean, file.Filepath
+	if result.Infected {
+		status = models.ScanInfected
+		log.Printf("scan: file %d is infected (%s), quarantining", file.ID, result.Signature)
+		if path, err = s.quarantine(file.Filepath); err != nil {
+			// blob tetap di tempatnya, tapi status infected sudah memblokir akses
+			log.Printf("scan: file %d: failed to quarantine: %v", file.ID, err)
+			path = file.Filepath
+		}
+	}
+	return s.repository.UpdateScanResult(file.ID, status, result.Signature, path)
+}
+
+func (s *ScanService) scanBlob(ctx context.Context, path string) (*scanner.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.scanner.Scan(ctx, f)
+}
+
+// quarantine memindahkan blob ke folder karantina dan menghapus thumbnail
+// serta hasil transformasi gambarnya
+func (s *ScanService) quarantine(blobPath string) (string, error) {
+	if err := os.MkdirAll(s.quarantinePath, 0o700); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(s.quarantinePath, filepath.Base(blobPath))
+	if err := os.Rename(blobPath, dst); err != nil {
+		// folder karantina bisa berada di filesystem lain
+		if err := copyBlob(blobPath, dst); err != nil {
+			return "", err
+		}
+		if err := os.Remove(blobPath); err != nil {
+			os.Remove(dst)
+			return "", err
+		}
+	}
+	if err := os.Chmod(dst, 0o600); err != nil {
+		log.Printf("scan: failed to chmod %s: %v", dst, err)
+	}
+	removeThumbnails(blobPath)
+	return dst, nil
+}
+
+// checkScanPolicy: file infected tidak pernah bisa diunduh. File yang belum
+// dinyatakan bersih hanya diblokir jika workspace-nya mewajibkan scan bersih.
+func checkScanPolicy(file *models.File, requireClean bool) error {
+	switch {
+	case file.ScanStatus == models.ScanInfected:
+		return apperrors.ErrFileQuarantined
+	case requireClean && file.ScanStatus != models.ScanClean:
+		return fmt.Errorf("%w (status: %s)", apperrors.ErrScanPending, file.ScanStatus)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/oidc"
+	apperrors "vasvault/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+type SSOServiceInterface interface {
+	BeginLogin(ctx context.Context) (authURL string, state string, err error)
+	CompleteLogin(ctx context.Context, code string, state string) (*dto.UserResponse, error)
+}
+
+type SSOService struct {
+	provider      *oidc.Provider
+	userRepo      repositories.UserRepositoryInterface
+	identityRepo  repositories.IdentityRepositoryInterface
+	autoProvision bool
+}
+
+func NewSSOService(provider *oidc.Provider, userRepo repositories.UserRepositoryInterface, identityRepo repositories.IdentityRepositoryInterface, autoProvision bool) SSOServiceInterface {
+	return &SSOService{
+		provider:      provider,
+		userRepo:      userRepo,
+		identityRepo:  identityRepo,
+		autoProvision: autoProvision,
+	}
+}
+
+// BeginLogin menyimpan state, nonce dan PKCE verifier lalu mengembalikan URL
+// login identity provider dan state-nya (untuk diikat ke browser lewat cookie)
+func (s *SSOService) BeginLogin(ctx context.Context) (string, string, error) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.identityRepo.CreateState(&models.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}); err != nil {
+		return "", "", fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+func (s *SSOService) CompleteLogin(ctx context.Context, code string, state string) (*dto.UserResponse, error) {
+	stored, err := s.identityRepo.ConsumeState(state)
+	if err != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	tokens, err := s.provider.Exchange(ctx, code, stored.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.provider.VerifyIDToken(ctx, tokens.IDToken, stored.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.VerifiedAt != nil,
+	}, nil
+}
+
+// resolveUser mencari user lewat identity yang sudah terhubung, lalu lewat email
+// yang sudah diverifikasi oleh provider, dan terakhir membuat user baru
+func (s *SSOService) resolveUser(claims *oidc.IDTokenClaims) (*models.User, error) {
+	issuer := s.provider.Issuer()
+
+	if identity, err := s.identityRepo.FindByProviderSubject(issuer, claims.Subject); err == nil {
+		return s.userRepo.FindByID(identity.UserID)
+	}
+
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		return nil, apperrors.ErrSSOEmailUnverified
+	}
+
+	user, err := s.userRepo.FindByEmail(claims.Email)
+	if err != nil {
+		if !s.autoProvision {
+			return nil, apperrors.ErrSSONotProvisioned
+		}
+		if user, err = s.provisionUser(claims); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.VerifiedAt == nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	if err := s.identityRepo.Create(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return user, nil
+}
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func (s *SSOService) provisionUser(claims *oidc.IDTokenClaims) (*models.User, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = usernameCleaner.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		if _, err := s.userRepo.FindByUsername(username); err != nil {
+			break
+		}
+		if i > 100 {
+			return nil, apperrors.ErrUsernameExists
+		}
+		username = base + strconv.Itoa(i)
+	}
+
+	// user SSO tidak punya password lokal, isi dengan hash dari string acak
+	random, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:   username,
+		Email:      claims.Email,
+		Password:   string(hash),
+		VerifiedAt: &now,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"vasvault/internal/models"
+	apperrors "vasvault/pkg/utils"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/disintegration/imaging"
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Ukuran kanvas sumber preview. Preset thumbnail di-resize dari sini, jadi
+// lebarnya cukup untuk preset terbesar.
+const (
+	previewWidth     = 1024
+	previewMaxHeight = 1448 // rasio A4
+	previewIconSize  = 512
+
+	textPreviewMaxBytes = 64 * 1024
+	textPreviewMaxLines = 40
+	textPreviewFontSize = 18
+)
+
+var (
+	previewBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	previewInk        = color.NRGBA{R: 0x1f, G: 0x29, B: 0x37, A: 0xff}
+	previewMuted      = color.NRGBA{R: 0x9c, G: 0xa3, B: 0xaf, A: 0xff}
+	previewRule       = color.NRGBA{R: 0xd1, G: 0xd5, B: 0xdb, A: 0xff}
+)
+
+// renderPreview menghasilkan gambar sumber untuk thumbnail sesuai jenis file:
+// gambar di-decode langsung, PDF dirender halaman pertamanya, teks/kode
+// dirender beberapa baris pertama dengan syntax highlighting, sisanya ikon.
+func renderPreview(file models.File) (image.Image, error) {
+	name := previewName(file)
+
+	switch {
+	case isImageMime(file.Mimetype):
+		// batas piksel yang sama dengan /image, dicek sebelum decode
+		if err := checkSourcePixels(file.Filepath); err != nil {
+			if errors.Is(err, apperrors.ErrImageTooLarge) {
+				return renderIcon(name, file.Mimetype), nil
+			}
+			return nil, err
+		}
+		return openImage(file)
+	case isPDF(file.Mimetype, name):
+		img, err := renderPDFPage(file.Filepath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, err
+			}
+			// PDF yang tidak bisa dibaca tetap dapat ikon
+			return renderIcon(name, file.Mimetype), nil
+		}
+		return img, nil
+	case isTextLike(file.Mimetype, name):
+		img, err := renderTextPreview(file.Filepath, name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, err
+			}
+			return renderIcon(name, file.Mimetype), nil
+		}
+		return img, nil
+	}
+
+	if _, err := os.Stat(file.Filepath); err != nil {
+		return nil, err
+	}
+	return renderIcon(name, file.Mimetype), nil
+}
+
+// openImage men-decode gambar dan memutarnya sesuai orientasi di metadata
+func openImage(file models.File) (image.Image, error) {
+	if file.Metadata == nil {
+		// file lama tanpa metadata: orientasi dibaca langsung dari EXIF
+		return imaging.Open(file.Filepath, imaging.AutoOrientation(true))
+	}
+	img, err := imaging.Open(file.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	return orientImage(img, file.Metadata.Orientation), nil
+}
+
+// previewName: nama yang disimpan bisa tanpa ekstensi setelah rename, jadi
+// ekstensi blob dipakai sebagai cadangan
+func previewName(file models.File) string {
+	if filepath.Ext(file.Filename) != "" {
+		return file.Filename
+	}
+	return file.Filename + filepath.Ext(file.Filepath)
+}
+
+func isImageMime(mimetype string) bool {
+	return strings.HasPrefix(strings.ToLower(mimetype), "image/")
+}
+
+func isPDF(mimetype, name string) bool {
+	return strings.EqualFold(mimetype, "application/pdf") || strings.EqualFold(filepath.Ext(name), ".pdf")
+}
+
+var textMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+	"application/x-yaml":     true,
+	"application/yaml":       true,
+	"application/toml":       true,
+	"application/sql":        true,
+}
+
+// isTextLike: browser sering mengirim application/octet-stream untuk source
+// file, jadi ekstensi yang dikenal chroma juga dihitung sebagai teks
+func isTextLike(mimetype, name string) bool {
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(mimetype, ";")[0]))
+	if strings.HasPrefix(mime, "text/") || textMimeTypes[mime] {
+		return true
+	}
+	return filepath.Ext(name) != "" && lexers.Match(name) != nil
+}
+
+// renderPDFPage menggambar ulang teks dan kotak di halaman pertama pada posisi
+// aslinya. Gambar raster dan path vektor di dalam PDF tidak dirender.
+func renderPDFPage(path string) (img image.Image, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// parser PDF memakai panic untuk input rusak
+	defer func() {
+		if r := recover(); r != nil {
+			img, err = nil, fmt.Errorf("invalid pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if reader.NumPage() < 1 {
+		return nil, fmt.Errorf("pdf has no pages")
+	}
+
+	page := reader.Page(1)
+	x0, y0, x1, y1 := 0.0, 0.0, 612.0, 792.0 // default US Letter
+	if box := pdfMediaBox(page); box.Len() == 4 {
+		x0, y0, x1, y1 = box.Index(0).Float64(), box.Index(1).Float64(), box.Index(2).Float64(), box.Index(3).Float64()
+	}
+	if x1 <= x0 || y1 <= y0 {
+		return nil, fmt.Errorf("invalid pdf media box")
+	}
+
+	content := page.Content()
+	if len(content.Text) == 0 && len(content.Rect) == 0 {
+		// biasanya hasil scan: isinya hanya gambar
+		return nil, fmt.Errorf("pdf page has no renderable content")
+	}
+
+	scale := previewWidth / (x1 - x0)
+	height := int((y1 - y0) * scale)
+	if height > previewMaxHeight {
+		height = previewMaxHeight
+	}
+	canvas := newCanvas(previewWidth, height)
+
+	toX := func(x float64) int { return int((x - x0) * scale) }
+	toY := func(y float64) int { return int((y1 - y) * scale) }
+
+	for _, r := range content.Rect {
+		outline(canvas, image.Rect(toX(r.Min.X), toY(r.Max.Y), toX(r.Max.X), toY(r.Min.Y)), previewRule)
+	}
+
+	faces := faceCache{}
+	d := &font.Drawer{Dst: canvas, Src: image.NewUniform(previewInk)}
+	for _, t := range content.Text {
+		size := t.FontSize * scale
+		if size < 1 {
+			continue
+		}
+		face, err := faces.face(pdfFontStyle(t.Font), size)
+		if err != nil {
+			return nil, err
+		}
+		d.Face = face
+
+		// font standar tanpa /Widths dilaporkan lebar 0 oleh parser: lanjutkan
+		// dari posisi glyph sebelumnya pada baseline yang sama
+		x, y := toX(t.X), toY(t.Y)
+		if t.W == 0 && d.Dot.Y.Round() == y && d.Dot.X.Round() > x {
+			x = d.Dot.X.Round()
+		}
+		d.Dot = fixed.P(x, y)
+		d.DrawString(t.S)
+	}
+	return canvas, nil
+}
+
+// pdfMediaBox: MediaBox boleh diwarisi dari node Pages di atasnya
+func pdfMediaBox(page pdf.Page) pdf.Value {
+	for v, depth := page.V, 0; !v.IsNull() && depth < 32; v, depth = v.Key("Parent"), depth+1 {
+		if box := v.Key("MediaBox"); !box.IsNull() {
+			return box
+		}
+	}
+	return pdf.Value{}
+}
+
+func pdfFontStyle(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "courier"), strings.Contains(lower, "mono"):
+		return "mono"
+	case strings.Contains(lower, "bold"):
+		return "bold"
+	case strings.Contains(lower, "italic"), strings.Contains(lower, "oblique"):
+		return "italic"
+	}
+	return "regular"
+}
+
+// renderTextPreview merender baris-baris pertama dengan warna dari chroma.
+// File yang ternyata biner dikembalikan sebagai error supaya jatuh ke ikon.
+func renderTextPreview(path, name string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf, err := io.ReadAll(io.LimitReader(f, textPreviewMaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return nil, fmt.Errorf("binary content")
+	}
+	// batas baca bisa memotong rune multi-byte di akhir buffer
+	for i := 0; i < utf8.UTFMax-1 && len(buf) > 0 && !utf8.Valid(buf); i++ {
+		buf = buf[:len(buf)-1]
+	}
+	if !utf8.Valid(buf) {
+		return nil, fmt.Errorf("content is not utf-8")
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(buf), "\r\n", "\n"), "\n")
+	if len(lines) > textPreviewMaxLines {
+		lines = lines[:textPreviewMaxLines]
+	}
+	source := strings.ReplaceAll(strings.Join(lines, "\n"), "\t", "    ")
+
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		lexer = lexers.Analyse(source)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+	style := styles.Get("github")
+
+	tokens, err := lexer.Tokenise(nil, source)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := faceCache{}.face("mono", textPreviewFontSize)
+	if err != nil {
+		return nil, err
+	}
+	lineHeight := textPreviewFontSize * 3 / 2
+	gutter := font.MeasureString(face, strconv.Itoa(len(lines))+"  ").Ceil() + 16
+	canvas := newCanvas(previewWidth, previewWidth)
+
+	drawer := &font.Drawer{Dst: canvas, Face: face}
+	line := 0
+	newLine := func() {
+		line++
+		drawer.Dot = fixed.P(gutter, 16+lineHeight*(line+1)-lineHeight/3)
+		if line < len(lines) {
+			number := &font.Drawer{Dst: canvas, Src: image.NewUniform(previewMuted), Face: face,
+				Dot: fixed.P(12, drawer.Dot.Y.Round())}
+			number.DrawString(strconv.Itoa(line + 1))
+		}
+	}
+	line = -1
+	newLine()
+
+	for token := tokens(); token != chroma.EOF; token = tokens() {
+		ink := color.Color(previewInk)
+		if entry := style.Get(token.Type); entry.Colour.IsSet() {
+			ink = color.NRGBA{R: entry.Colour.Red(), G: entry.Colour.Green(), B: entry.Colour.Blue(), A: 0xff}
+		}
+		drawer.Src = image.NewUniform(ink)
+
+		parts := strings.Split(token.Value, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				newLine()
+			}
+			if drawer.Dot.X.Round() < previewWidth {
+				drawer.DrawString(part)
+			}
+		}
+	}
+	return canvas, nil
+}
+
+var iconColors = map[string]color.NRGBA{
+	"pdf":      {R: 0xdc, G: 0x26, B: 0x26, A: 0xff},
+	"archive":  {R: 0xd9, G: 0x77, B: 0x06, A: 0xff},
+	"audio":    {R: 0x7c, G: 0x3a, B: 0xed, A: 0xff},
+	"video":    {R: 0xdb, G: 0x27, B: 0x77, A: 0xff},
+	"sheet":    {R: 0x16, G: 0xa3, B: 0x4a, A: 0xff},
+	"document": {R: 0x25, G: 0x63, B: 0xeb, A: 0xff},
+	"other":    {R: 0x64, G: 0x74, B: 0x8b, A: 0xff},
+}
+
+// renderIcon menggambar ikon dokumen generik dengan label ekstensi
+func renderIcon(name, mimetype string) image.Image {
+	canvas := newCanvas(previewIconSize, previewIconSize)
+	accent := iconColors[iconKind(name, mimetype)]
+
+	// lembar kertas dengan sudut terlipat
+	page := image.Rect(136, 64, 376, 448)
+	fold := 64
+	draw.Draw(canvas, page, image.NewUniform(color.NRGBA{R: 0xf3, G: 0xf4, B: 0xf6, A: 0xff}), image.Point{}, draw.Src)
+	outline(canvas, page, previewRule)
+	for i := 0; i < fold; i++ {
+		for x := page.Max.X - fold + i; x <= page.Max.X; x++ {
+			canvas.Set(x, page.Min.Y+i, previewBackground)
+		}
+		canvas.Set(page.Max.X-fold+i, page.Min.Y+i, previewRule)
+	}
+
+	band := image.Rect(page.Min.X-24, 272, page.Max.X-48, 352)
+	draw.Draw(canvas, band, image.NewUniform(accent), image.Point{}, draw.Src)
+
+	label := strings.ToUpper(strings.TrimPrefix(filepath.Ext(name), "."))
+	if label == "" || len(label) > 5 {
+		label = "FILE"
+	}
+	if face, err := (faceCache{}).face("bold", 44); err == nil {
+		d := &font.Drawer{Dst: canvas, Src: image.White, Face: face}
+		width := d.MeasureString(label).Round()
+		d.Dot = fixed.P(band.Min.X+(band.Dx()-width)/2, band.Max.Y-24)
+		d.DrawString(label)
+	}
+	return canvas
+}
+
+func iconKind(name, mimetype string) string {
+	mime := strings.ToLower(mimetype)
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case isPDF(mime, name):
+		return "pdf"
+	case strings.HasPrefix(mime, "audio/"):
+		return "audio"
+	case strings.HasPrefix(mime, "video/"):
+		return "video"
+	case strings.Contains(mime, "zip"), strings.Contains(mime, "compressed"), strings.Contains(mime, "tar"),
+		ext == ".zip", ext == ".gz", ext == ".tar", ext == ".7z", ext == ".rar":
+		return "archive"
+	case strings.Contains(mime, "spreadsheet"), strings.Contains(mime, "excel"), ext == ".csv", ext == ".xlsx", ext == ".xls":
+		return "sheet"
+	case strings.Contains(mime, "word"), strings.Contains(mime, "presentation"), strings.Contains(mime, "opendocument"):
+		return "document"
+	}
+	return "other"
+}
+
+func newCanvas(width, height int) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(previewBackground), image.Point{}, draw.Src)
+	return canvas
+}
+
+func outline(canvas *image.NRGBA, r image.Rectangle, c color.Color) {
+	r = r.Canon().Intersect(canvas.Bounds())
+	if r.Empty() {
+		return
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		canvas.Set(x, r.Min.Y, c)
+		canvas.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		canvas.Set(r.Min.X, y, c)
+		canvas.Set(r.Max.X-1, y, c)
+	}
+}
+
+var previewFontData = map[string][]byte{
+	"regular": goregular.TTF,
+	"bold":    gobold.TTF,
+	"italic":  goitalic.TTF,
+	"mono":    gomono.TTF,
+}
+
+var (
+	previewFonts   = map[string]*opentype.Font{}
+	previewFontsMu sync.Mutex
+)
+
+// faceCache menyimpan face per render. font.Face tidak aman dipakai bersamaan
+// oleh beberapa worker, jadi hanya font hasil parse yang dibagi.
+type faceCache map[string]font.Face
+
+// face mengembalikan face untuk font dan ukuran tertentu. Ukuran dibulatkan ke
+// 0.5pt supaya PDF dengan banyak ukuran font tidak membuat terlalu banyak face.
+func (c faceCache) face(name string, size float64) (font.Face, error) {
+	if size > 256 {
+		size = 256
+	}
+	size = float64(int(size*2+0.5)) / 2
+
+	key := fmt.Sprintf("%s-%.1f", name, size)
+	if face, ok := c[key]; ok {
+		return face, nil
+	}
+
+	parsed, err := previewFont(name)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, err
+	}
+	c[key] = face
+	return face, nil
+}
+
+func previewFont(name string) (*opentype.Font, error) {
+	previewFontsMu.Lock()
+	defer previewFontsMu.Unlock()
+
+	if parsed, ok := previewFonts[name]; ok {
+		return parsed, nil
+	}
+	parsed, err := opentype.Parse(previewFontData[name])
+	if err != nil {
+		return nil, err
+	}
+	previewFonts[name] = parsed
+	return parsed, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+
+	"github.com/disintegration/imaging"
+)
+
+// Format output gambar (thumbnail dan transformasi)
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+	FormatPNG  = "png"
+)
+
+const thumbnailQuality = 80
+
+// Status thumbnail yang dikembalikan ke handler
+const (
+	ThumbnailReady   = "ready"
+	ThumbnailPending = "pending"
+	ThumbnailFailed  = "failed"
+)
+
+// ThumbnailPreset: Crop=true dipotong tengah menjadi persegi, selain itu
+// di-fit ke dalam kotak tanpa upscale
+type ThumbnailPreset struct {
+	Name   string
+	Width  int
+	Height int
+	Crop   bool
+}
+
+var thumbnailPresets = map[string]ThumbnailPreset{
+	"small":  {Name: "small", Width: 200, Height: 200, Crop: true},
+	"medium": {Name: "medium", Width: 480, Height: 480},
+	"large":  {Name: "large", Width: 1024, Height: 1024},
+}
+
+const DefaultThumbnailSize = "small"
+
+type ThumbnailServiceInterface interface {
+	Enqueue(file models.File)
+	Get(file models.File, size, format string) (path string, status string, err error)
+	Placeholder(size, format string) ([]byte, error)
+}
+
+type thumbnailJob struct {
+	FileID uint `json:"file_id"`
+}
+
+type ThumbnailService struct {
+	repository  repositories.FileRepositoryInterface
+	jobs        *jobqueue.Queue
+	generateJob jobqueue.Type[thumbnailJob]
+
+	placeholders sync.Map
+}
+
+// NewThumbnailService: thumbnail dibuat oleh job "generate_thumbnails" di
+// queue "thumbnails". Render yang gagal tidak diulang (hasilnya akan sama),
+// job-nya langsung dead dan bisa diulang lewat admin API.
+func NewThumbnailService(repo repositories.FileRepositoryInterface, jobs *jobqueue.Queue) ThumbnailServiceInterface {
+	s := &ThumbnailService{repository: repo, jobs: jobs}
+	s.generateJob = jobqueue.Register(jobs, "generate_thumbnails", jobqueue.HandlerOptions{
+		Queue:       "thumbnails",
+		MaxAttempts: 1,
+		Timeout:     2 * time.Minute,
+	}, s.generate)
+	return s
+}
+
+// ThumbnailPresetByName mengembalikan preset, "" berarti preset default
+func ThumbnailPresetByName(name string) (ThumbnailPreset, bool) {
+	if name == "" {
+		name = DefaultThumbnailSize
+	}
+	preset, ok := thumbnailPresets[strings.ToLower(name)]
+	return preset, ok
+}
+
+// ThumbnailFormats: WebP hanya tersedia jika binary di-build dengan cgo
+func ThumbnailFormats() []string {
+	if webpSupported {
+		return []string{FormatJPEG, FormatWebP}
+	}
+	return []string{FormatJPEG}
+}
+
+// Enqueue menjadwalkan pembuatan semua preset. Satu job per file; file yang
+// sudah punya job tidak dijadwalkan ulang.
+func (s *ThumbnailService) Enqueue(file models.File) {
+	if _, err := s.enqueue(file); err != nil && !errors.Is(err, jobqueue.ErrDuplicate) {
+		log.Printf("thumbnail: failed to enqueue file %d: %v", file.ID, err)
+	}
+}
+
+func (s *ThumbnailService) enqueue(file models.File) (*jobqueue.Job, error) {
+	return s.generateJob.Enqueue(thumbnailJob{FileID: file.ID}, jobqueue.Unique(fmt.Sprintf("thumbnail:%d", file.ID)))
+}
+
+// Get mengembalikan path thumbnail jika sudah ada. Jika belum, file dijadwalkan
+// dan status pending dikembalikan.
+func (s *ThumbnailService) Get(file models.File, size, format string) (string, string, error) {
+	preset, ok := ThumbnailPresetByName(size)
+	if !ok {
+		return "", "", fmt.Errorf("unknown thumbnail size %q", size)
+	}
+	if format == FormatWebP && !webpSupported {
+		format = FormatJPEG
+	}
+
+	path := thumbnailPath(file.Filepath, preset.Name, format)
+	if _, err := os.Stat(path); err == nil {
+		return path, ThumbnailReady, nil
+	}
+
+	job, err := s.enqueue(file)
+	if err == nil {
+		return "", ThumbnailPending, nil
+	}
+	if !errors.Is(err, jobqueue.ErrDuplicate) {
+		return "", "", err
+	}
+
+	switch job.Status {
+	case jobqueue.StatusDead:
+		return "", ThumbnailFailed, nil
+	case jobqueue.StatusSucceeded:
+		// job sudah selesai tapi thumbnail tidak ada (mis. file dari sebelum
+		// WebP tersedia): render ulang
+		if _, err := s.jobs.Retry(job.ID); err != nil && !errors.Is(err, jobqueue.ErrNotRetryable) {
+			return "", "", err
+		}
+	}
+	return "", ThumbnailPending, nil
+}
+
+// Placeholder: gambar abu-abu polos seukuran preset, di-cache di memori
+func (s *ThumbnailService) Placeholder(size, format string) ([]byte, error) {
+	preset, ok := ThumbnailPresetByName(size)
+	if !ok {
+		return nil, fmt.Errorf("unknown thumbnail size %q", size)
+	}
+	if format == FormatWebP && !webpSupported {
+		format = FormatJPEG
+	}
+
+	key := preset.Name + "." + format
+	if cached, ok := s.placeholders.Load(key); ok {
+		return cached.([]byte), nil
+	}
+
+	img := imaging.New(preset.Width, preset.Height, color.NRGBA{R: 0xe5, G: 0xe7, B: 0xeb, A: 0xff})
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, img, format, thumbnailQuality); err != nil {
+		return nil, err
+	}
+	s.placeholders.Store(key, buf.Bytes())
+	return buf.Bytes(), nil
+}
+
+func (s *ThumbnailService) generate(ctx context.Context, job thumbnailJob) error {
+	file, err := s.repository.FindByID(job.FileID)
+	if err != nil {
+		return nil // file sudah dihapus
+	}
+
+	src, err := renderPreview(*file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // blob terhapus bersama file-nya
+		}
+		return fmt.Errorf("failed to render file %d: %w", file.ID, err)
+	}
+
+	dir := thumbnailDir(file.Filepath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, preset := range thumbnailPresets {
+		img := resizeThumbnail(src, preset)
+		for _, format := range ThumbnailFormats() {
+			if err := writeImage(thumbnailPath(file.Filepath, preset.Name, format), img, format, thumbnailQuality); err != nil {
+				log.Printf("thumbnail: file %d %s/%s: %v", file.ID, preset.Name, format, err)
+			}
+		}
+	}
+
+	// file dihapus selama thumbnail dibuat: jangan tinggalkan cache yatim
+	if _, err := os.Stat(file.Filepath); os.IsNotExist(err) {
+		removeThumbnails(file.Filepath)
+	}
+	return nil
+}
+
+func resizeThumbnail(src image.Image, preset ThumbnailPreset) image.Image {
+	if preset.Crop {
+		return imaging.Thumbnail(src, preset.Width, preset.Height, imaging.Lanczos)
+	}
+	return imaging.Fit(src, preset.Width, preset.Height, imaging.Lanczos)
+}
+
+// writeImage menulis ke file sementara lalu rename, supaya request yang
+// bersamaan tidak pernah melihat gambar setengah jadi
+func writeImage(path string, img image.Image, format string, quality int) error {
+	tmp := path + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := encodeImage(out, img, format, quality); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// thumbnailDir: thumbnail disimpan di samping blob, di thumbs/<nama blob>/,
+// sehingga blob baru (mis. file diganti) otomatis punya cache sendiri
+func thumbnailDir(blobPath string) string {
+	return filepath.Join(filepath.Dir(blobPath), "thumbs", filepath.Base(blobPath))
+}
+
+func thumbnailPath(blobPath, size, format string) string {
+	ext := ".jpg"
+	if format == FormatWebP {
+		ext = ".webp"
+	}
+	return filepath.Join(thumbnailDir(blobPath), size+ext)
+}
+
+// removeThumbnails menghapus semua cache thumbnail milik blob, termasuk
+// format lama thumbs/<nama blob>.thumb.jpg
+func removeThumbnails(blobPath string) {
+	dir := thumbnailDir(blobPath)
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("failed to remove %s: %v", dir, err)
+	}
+	legacy := dir + ".thumb.jpg"
+	if err := os.Remove(legacy); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove %s: %v", legacy, err)
+	}
+}
@@ -2,9 +2,13 @@ package services
 
 import (
 	"fmt"
+	"log"
+	"net/url"
+	"time"
 	"vasvault/internal/dto"
 	"vasvault/internal/models"
 	"vasvault/internal/repositories"
+	"vasvault/pkg/mailer"
 	"vasvault/pkg/utils"
 	apperrors "vasvault/pkg/utils"
 
@@ -18,14 +22,33 @@ type UserServiceInterface interface {
 	GetUserByID(id uint) (*dto.UserResponse, error)
 	UpdateUser(id uint, request dto.UpdateProfileRequest) (*dto.UserResponse, error)
 	Refresh(refreshToken string) (*dto.UserResponse, error)
+	ForgotPassword(request dto.ForgotPasswordRequest) error
+	ResetPassword(request dto.ResetPasswordRequest) error
+	VerifyEmail(request dto.VerifyEmailRequest) (*dto.UserResponse, error)
+	ResendVerification(id uint) error
 }
 
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 24 * time.Hour
+)
+
 type UserService struct {
-	repository repositories.UserRepositoryInterface
+	repository     repositories.UserRepositoryInterface
+	tokenRepo      repositories.UserTokenRepositoryInterface
+	invitationRepo repositories.InvitationRepository
+	mailer         mailer.Mailer
+	appURL         string
 }
 
-func NewUserService(repo repositories.UserRepositoryInterface) UserServiceInterface {
-	return &UserService{repository: repo}
+func NewUserService(repo repositories.UserRepositoryInterface, tokenRepo repositories.UserTokenRepositoryInterface, invitationRepo repositories.InvitationRepository, mail mailer.Mailer, appURL string) UserServiceInterface {
+	return &UserService{
+		repository:     repo,
+		tokenRepo:      tokenRepo,
+		invitationRepo: invitationRepo,
+		mailer:         mail,
+		appURL:         appURL,
+	}
 }
 
 func (s *UserService) Register(request dto.RegisterRequest) (*dto.UserResponse, error) {
@@ -51,10 +74,21 @@ func (s *UserService) Register(request dto.RegisterRequest) (*dto.UserResponse,
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	// undangan workspace untuk email ini sekarang milik akun baru
+	if err := s.invitationRepo.ClaimForUser(user.ID, user.Email); err != nil {
+		log.Printf("failed to claim invitations for user %d: %v", user.ID, err)
+	}
+
+	// registrasi tetap berhasil walaupun email gagal terkirim, user bisa minta kirim ulang
+	if err := s.sendVerification(user, user.Email); err != nil {
+		log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+	}
+
 	response := &dto.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Username: user.Username,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.VerifiedAt != nil,
 	}
 	return response, nil
 }
@@ -71,9 +105,10 @@ func (s *UserService) Login(request dto.LoginRequest) (*dto.UserResponse, error)
 	}
 
 	response := &dto.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Username: user.Username,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.VerifiedAt != nil,
 	}
 	return response, nil
 }
@@ -85,9 +120,10 @@ func (s *UserService) GetUserByID(id uint) (*dto.UserResponse, error) {
 	}
 
 	response := &dto.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Username: user.Username,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.VerifiedAt != nil,
 	}
 	return response, nil
 }
@@ -98,8 +134,13 @@ func (s *UserService) UpdateUser(id uint, request dto.UpdateProfileRequest) (*dt
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
 
-	if request.Email != "" {
-		user.Email = request.Email
+	// email baru baru dipakai setelah diverifikasi lewat link yang dikirim ke alamat tersebut
+	pendingEmail := ""
+	if request.Email != "" && request.Email != user.Email {
+		if _, err := s.repository.FindByEmail(request.Email); err == nil {
+			return nil, apperrors.ErrEmailExists
+		}
+		pendingEmail = request.Email
 	}
 
 	if request.Password != "" {
@@ -118,10 +159,18 @@ func (s *UserService) UpdateUser(id uint, request dto.UpdateProfileRequest) (*dt
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	if pendingEmail != "" {
+		if err := s.sendVerification(user, pendingEmail); err != nil {
+			return nil, err
+		}
+	}
+
 	response := &dto.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Username: user.Username,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.VerifiedAt != nil,
+		PendingEmail:  pendingEmail,
 	}
 	return response, nil
 }
@@ -147,9 +196,166 @@ func (s *UserService) Refresh(refreshToken string) (*dto.UserResponse, error) {
 	}
 
 	response := &dto.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Username: user.Username,
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: user.VerifiedAt != nil,
 	}
 	return response, nil
 }
+
+// ForgotPassword selalu berhasil untuk email yang tidak terdaftar supaya
+// endpoint ini tidak bisa dipakai untuk mengecek email mana yang punya akun
+func (s *UserService) ForgotPassword(request dto.ForgotPasswordRequest) error {
+	user, err := s.repository.FindByEmail(request.Email)
+	if err != nil {
+		return nil
+	}
+
+	if err := s.tokenRepo.InvalidateForUser(user.ID, models.TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to invalidate previous reset tokens: %w", err)
+	}
+
+	token, err := s.issueToken(user.ID, models.TokenPurposePasswordReset, user.Email, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(mailer.TemplatePasswordReset, user.Email, map[string]string{
+		"Username":  user.Username,
+		"Link":      s.link("/reset-password", token),
+		"ExpiresIn": "1 hour",
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(msg)
+}
+
+func (s *UserService) ResetPassword(request dto.ResetPasswordRequest) error {
+	token, err := s.tokenRepo.FindActiveByHash(utils.HashOpaqueToken(request.Token), models.TokenPurposePasswordReset)
+	if err != nil {
+		return apperrors.ErrInvalidToken
+	}
+	if err := s.tokenRepo.MarkUsed(token); err != nil {
+		return apperrors.ErrInvalidToken
+	}
+
+	user, err := s.repository.FindByID(token.UserID)
+	if err != nil {
+		return apperrors.ErrUserNotFound
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = string(hash)
+
+	// link reset dikirim ke email user, jadi email tersebut terbukti miliknya
+	if user.VerifiedAt == nil && token.Email == user.Email {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+
+	if err := s.repository.Update(user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+func (s *UserService) VerifyEmail(request dto.VerifyEmailRequest) (*dto.UserResponse, error) {
+	token, err := s.tokenRepo.FindActiveByHash(utils.HashOpaqueToken(request.Token), models.TokenPurposeEmailVerification)
+	if err != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	user, err := s.repository.FindByID(token.UserID)
+	if err != nil {
+		return nil, apperrors.ErrUserNotFound
+	}
+
+	if token.Email != user.Email {
+		if existing, err := s.repository.FindByEmail(token.Email); err == nil && existing.ID != user.ID {
+			return nil, apperrors.ErrEmailExists
+		}
+	}
+
+	if err := s.tokenRepo.MarkUsed(token); err != nil {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	now := time.Now()
+	user.Email = token.Email
+	user.VerifiedAt = &now
+	if err := s.repository.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return &dto.UserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		EmailVerified: true,
+	}, nil
+}
+
+func (s *UserService) ResendVerification(id uint) error {
+	user, err := s.repository.FindByID(id)
+	if err != nil {
+		return apperrors.ErrUserNotFound
+	}
+	if user.VerifiedAt != nil {
+		return apperrors.ErrAlreadyVerified
+	}
+	return s.sendVerification(user, user.Email)
+}
+
+// sendVerification mengirim link verifikasi ke email (bisa email baru saat ganti email)
+func (s *UserService) sendVerification(user *models.User, email string) error {
+	if err := s.tokenRepo.InvalidateForUser(user.ID, models.TokenPurposeEmailVerification); err != nil {
+		return fmt.Errorf("failed to invalidate previous verification tokens: %w", err)
+	}
+
+	token, err := s.issueToken(user.ID, models.TokenPurposeEmailVerification, email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(mailer.TemplateVerifyEmail, email, map[string]string{
+		"Username":  user.Username,
+		"Email":     email,
+		"Link":      s.link("/verify-email", token),
+		"ExpiresIn": "24 hours",
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+func (s *UserService) issueToken(userID uint, purpose string, email string, ttl time.Duration) (string, error) {
+	raw, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := &models.UserToken{
+		UserID:    userID,
+		TokenHash: hash,
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return raw, nil
+}
+
+func (s *UserService) link(path string, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
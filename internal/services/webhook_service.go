@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"vasvault/internal/dto"
+	"vasvault/internal/models"
+	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+	apperrors "vasvault/pkg/utils"
+	"vasvault/pkg/webhook"
+)
+
+const (
+	maxWebhooksPerScope = 20
+
+	// log pengiriman disimpan selama ini
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+type WebhookServiceInterface interface {
+	EventPublisher
+	CreateWebhook(userID uint, req dto.CreateWebhookRequest) (*dto.WebhookResponse, error)
+	ListWebhooks(userID uint, workspaceID *uint) ([]dto.WebhookResponse, error)
+	GetWebhook(userID uint, webhookID uint) (*dto.WebhookResponse, error)
+	UpdateWebhook(userID uint, webhookID uint, req dto.UpdateWebhookRequest) (*dto.WebhookResponse, error)
+	DeleteWebhook(userID uint, webhookID uint) error
+	PingWebhook(userID uint, webhookID uint) (*dto.WebhookDeliveryResponse, error)
+	ListDeliveries(userID uint, webhookID uint, limit int) ([]dto.WebhookDeliveryResponse, error)
+}
+
+type WebhookService struct {
+	repository    repositories.WebhookRepositoryInterface
+	workspaceRepo repositories.WorkspaceRepository
+	client        *webhook.Client
+	allowPrivate  bool
+	deliverJob    jobqueue.Type[webhookJob]
+}
+
+type webhookJob struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// NewWebhookService: WEBHOOK_TIMEOUT membatasi satu percobaan kirim,
+// WEBHOOK_ALLOW_PRIVATE=true mengizinkan URL ke alamat lokal/privat (development)
+func NewWebhookService(repo repositories.WebhookRepositoryInterface, workspaceRepo repositories.WorkspaceRepository, jobs *jobqueue.Queue) (WebhookServiceInterface, error) {
+	allowPrivate := os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+	service := &WebhookService{
+		repository:    repo,
+		workspaceRepo: workspaceRepo,
+		client:        webhook.NewClient(webhookTimeoutFromEnv(), allowPrivate),
+		allowPrivate:  allowPrivate,
+	}
+
+	// receiver yang mati dicoba ulang dengan backoff selama sekitar 1,5 jam
+	service.deliverJob = jobqueue.Register(jobs, "deliver_webhook", jobqueue.HandlerOptions{
+		Queue:       "webhooks",
+		MaxAttempts: 10,
+		Timeout:     time.Minute,
+	}, service.deliver)
+
+	prune := jobqueue.Register(jobs, "prune_webhook_deliveries", jobqueue.HandlerOptions{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		_, err := repo.DeleteDeliveriesBefore(time.Now().Add(-webhookDeliveryRetention))
+		return err
+	})
+	if err := prune.Schedule("prune-webhook-deliveries", "@daily", struct{}{}); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func webhookTimeoutFromEnv() time.Duration {
+	raw := os.Getenv("WEBHOOK_TIMEOUT")
+	if raw == "" {
+		return defaultWebhookTimeout
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		log.Printf("invalid WEBHOOK_TIMEOUT %q, using %s", raw, defaultWebhookTimeout)
+		return defaultWebhookTimeout
+	}
+	return d
+}
+
+// Publish mencatat satu delivery per subscription yang cocok dan
+// menjadwalkan pengirimannya. Error hanya di-log: aksi user tidak boleh gagal
+// karena webhook.
+func (s *WebhookService) Publish(event Event) {
+	subs, err := s.repository.FindSubscribers(event.OwnerID, event.WorkspaceID)
+	if err != nil {
+		log.Printf("webhook: failed to find subscribers for %s: %v", event.Type, err)
+		return
+	}
+	var targets []models.WebhookSubscription
+	for _, sub := range subs {
+		if sub.Handles(event.Type) {
+			targets = append(targets, sub)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	payload := newEventPayload(event)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("webhook: failed to encode %s: %v", event.Type, err)
+		return
+	}
+
+	for _, sub := range targets {
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        payload.ID,
+			Event:          event.Type,
+			Payload:        string(body),
+			Status:         models.DeliveryPending,
+		}
+		if err := s.repository.CreateDelivery(delivery); err != nil {
+			log.Printf("webhook %d: failed to store delivery: %v", sub.ID, err)
+			continue
+		}
+		if _, err := s.deliverJob.Enqueue(webhookJob{DeliveryID: delivery.ID}); err != nil {
+			log.Printf("webhook %d: failed to enqueue delivery %d: %v", sub.ID, delivery.ID, err)
+		}
+	}
+}
+
+// deliver dijalankan oleh job queue; setiap percobaan dicatat di delivery
+func (s *WebhookService) deliver(ctx context.Context, job webhookJob) error {
+	delivery, err := s.repository.FindDelivery(job.DeliveryID)
+	if err != nil || delivery.Status != models.DeliveryPending {
+		return nil
+	}
+
+	sub, err := s.repository.FindByID(delivery.SubscriptionID)
+	if err != nil || !sub.Active {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook was deleted or disabled"
+		return s.repository.UpdateDelivery(delivery)
+	}
+
+	// pembuat webhook workspace yang sudah dikeluarkan atau diturunkan dari
+	// admin tidak boleh terus menerima event workspace itu
+	if err := s.checkManage(sub.UserID, sub.UserID, sub.WorkspaceID); err != nil {
+		sub.Active = false
+		if err := s.repository.Update(sub); err != nil {
+			log.Printf("webhook %d: failed to disable: %v", sub.ID, err)
+		}
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook creator is no longer a workspace admin"
+		return s.repository.UpdateDelivery(delivery)
+	}
+
+	sendErr := s.send(ctx, sub, delivery)
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliverySucceeded
+	case errors.Is(sendErr, webhook.ErrBlockedAddress):
+		delivery.Status = models.DeliveryFailed
+		sendErr = jobqueue.Permanent(sendErr)
+	case jobqueue.LastAttempt(ctx):
+		delivery.Status = models.DeliveryFailed
+	}
+	if err := s.repository.UpdateDelivery(delivery); err != nil {
+		log.Printf("webhook %d: failed to update delivery %d: %v", sub.ID, delivery.ID, err)
+	}
+	return sendErr
+}
+
+// send melakukan satu percobaan dan mencatat hasilnya di delivery
+func (s *WebhookService) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) error {
+	resp, err := s.client.Send(ctx, webhook.Request{
+		URL:        sub.URL,
+		Secret:     sub.Secret,
+		Event:      delivery.Event,
+		DeliveryID: strconv.FormatUint(uint64(delivery.ID), 10),
+		Body:       []byte(delivery.Payload),
+	})
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.DeliveredAt = &now
+	delivery.ResponseStatus, delivery.ResponseBody, delivery.DurationMs, delivery.Error = 0, "", 0, ""
+	if resp != nil {
+		delivery.ResponseStatus = resp.StatusCode
+		delivery.ResponseBody = resp.Body
+		delivery.DurationMs = resp.Duration.Milliseconds()
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	return err
+}
+
+func (s *WebhookService) CreateWebhook(userID uint, req dto.CreateWebhookRequest) (*dto.WebhookResponse, error) {
+	if err := s.checkManage(userID, userID, req.WorkspaceID); err != nil {
+		return nil, err
+	}
+	if err := webhook.ValidateURL(req.URL, s.allowPrivate); err != nil {
+		return nil, fmt.Errorf("%w: %v", apperrors.ErrInvalidWebhook, err)
+	}
+	events, err := normalizeEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.listScope(userID, req.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooksPerScope {
+		return nil, fmt.Errorf("%w: at most %d webhooks per scope", apperrors.ErrInvalidWebhook, maxWebhooksPerScope)
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	sub := &models.WebhookSubscription{
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      strings.Join(events, ","),
+		Active:      true,
+	}
+	if err := s.repository.Create(sub); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	response := toWebhookResponse(sub)
+	response.Secret = secret
+	return &response, nil
+}
+
+func (s *WebhookService) ListWebhooks(userID uint, workspaceID *uint) ([]dto.WebhookResponse, error) {
+	if err := s.checkManage(userID, userID, workspaceID); err != nil {
+		return nil, err
+	}
+	subs, err := s.listScope(userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]dto.WebhookResponse, 0, len(subs))
+	for i := range subs {
+		response = append(response, toWebhookResponse(&subs[i]))
+	}
+	return response, nil
+}
+
+func (s *WebhookService) GetWebhook(userID uint, webhookID uint) (*dto.WebhookResponse, error) {
+	sub, err := s.find(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	response := toWebhookResponse(sub)
+	return &response, nil
+}
+
+func (s *WebhookService) UpdateWebhook(userID uint, webhookID uint, req dto.UpdateWebhookRequest) (*dto.WebhookResponse, error) {
+	sub, err := s.find(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL != nil {
+		if err := webhook.ValidateURL(*req.URL, s.allowPrivate); err != nil {
+			return nil, fmt.Errorf("%w: %v", apperrors.ErrInvalidWebhook, err)
+		}
+		sub.URL = *req.URL
+	}
+	if req.Events != nil {
+		events, err := normalizeEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		sub.Events = strings.Join(events, ",")
+	}
+	if req.Active != nil {
+		// admin yang mengaktifkan kembali webhook menjadi pembuatnya, karena
+		// pembuat lama mungkin sudah tidak berhak
+		if *req.Active && !sub.Active {
+			sub.UserID = userID
+		}
+		sub.Active = *req.Active
+	}
+
+	if err := s.repository.Update(sub); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	response := toWebhookResponse(sub)
+	return &response, nil
+}
+
+func (s *WebhookService) DeleteWebhook(userID uint, webhookID uint) error {
+	if _, err := s.find(userID, webhookID); err != nil {
+		return err
+	}
+	return s.repository.Delete(webhookID)
+}
+
+// PingWebhook mengirim event ping langsung (tanpa retry) dan mengembalikan
+// hasilnya; percobaan ini juga masuk ke log pengiriman
+func (s *WebhookService) PingWebhook(userID uint, webhookID uint) (*dto.WebhookDeliveryResponse, error) {
+	sub, err := s.find(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := newEventPayload(Event{
+		Type:        models.EventPing,
+		ActorID:     userID,
+		WorkspaceID: sub.WorkspaceID,
+		Data:        map[string]uint{"webhook_id": sub.ID},
+	})
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventID:        payload.ID,
+		Event:          models.EventPing,
+		Payload:        string(body),
+		Status:         models.DeliveryPending,
+	}
+	if err := s.repository.CreateDelivery(delivery); err != nil {
+		return nil, fmt.Errorf("failed to store delivery: %w", err)
+	}
+
+	delivery.Status = models.DeliverySucceeded
+	if err := s.send(context.Background(), sub, delivery); err != nil {
+		delivery.Status = models.DeliveryFailed
+	}
+	if err := s.repository.UpdateDelivery(delivery); err != nil {
+		return nil, fmt.Errorf("failed to store delivery: %w", err)
+	}
+	response := toDeliveryResponse(delivery)
+	return &response, nil
+}
+
+func (s *WebhookService) ListDeliveries(userID uint, webhookID uint, limit int) ([]dto.WebhookDeliveryResponse, error) {
+	if _, err := s.find(userID, webhookID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repository.ListDeliveries(webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]dto.WebhookDeliveryResponse, 0, len(deliveries))
+	for i := range deliveries {
+		response = append(response, toDeliveryResponse(&deliveries[i]))
+	}
+	return response, nil
+}
+
+func (s *WebhookService) find(userID uint, webhookID uint) (*models.WebhookSubscription, error) {
+	sub, err := s.repository.FindByID(webhookID)
+	if err != nil {
+		return nil, apperrors.ErrWebhookNotFound
+	}
+	if err := s.checkManage(userID, sub.UserID, sub.WorkspaceID); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// checkManage: webhook personal hanya untuk pembuatnya, webhook workspace
+// untuk admin dan owner workspace
+func (s *WebhookService) checkManage(userID uint, ownerID uint, workspaceID *uint) error {
+	if workspaceID == nil {
+		if ownerID != userID {
+			return apperrors.ErrWebhookNotFound
+		}
+		return nil
+	}
+	member, err := s.workspaceRepo.FindMember(*workspaceID, userID)
+	if err != nil || !models.RoleAtLeast(member.Role, models.RoleAdmin) {
+		return apperrors.ErrWebhookForbidden
+	}
+	return nil
+}
+
+func (s *WebhookService) listScope(userID uint, workspaceID *uint) ([]models.WebhookSubscription, error) {
+	if workspaceID != nil {
+		return s.repository.ListWorkspace(*workspaceID)
+	}
+	return s.repository.ListPersonal(userID)
+}
+
+func normalizeEvents(events []string) ([]string, error) {
+	var out []string
+	for _, e := range events {
+		if !containsString(models.WebhookEvents, e) {
+			return nil, fmt.Errorf("%w: unknown event %q", apperrors.ErrInvalidWebhook, e)
+		}
+		if !containsString(out, e) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func containsString(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func toWebhookResponse(sub *models.WebhookSubscription) dto.WebhookResponse {
+	return dto.WebhookResponse{
+		ID:          sub.ID,
+		WorkspaceID: sub.WorkspaceID,
+		URL:         sub.URL,
+		Events:      sub.EventList(),
+		Active:      sub.Active,
+		CreatedBy:   sub.UserID,
+		CreatedAt:   sub.CreatedAt,
+		UpdatedAt:   sub.UpdatedAt,
+	}
+}
+
+func toDeliveryResponse(d *models.WebhookDelivery) dto.WebhookDeliveryResponse {
+	return dto.WebhookDeliveryResponse{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		ResponseBody:   d.ResponseBody,
+		Error:          d.Error,
+		DurationMs:     d.DurationMs,
+		Payload:        json.RawMessage(d.Payload),
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
@@ -1,10 +1,27 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 	"vasvault/internal/dto"
 	"vasvault/internal/models"
 	"vasvault/internal/repositories"
+	"vasvault/pkg/jobqueue"
+	"vasvault/pkg/mailer"
+	"vasvault/pkg/utils"
+)
+
+const (
+	invitationTTL = 7 * 24 * time.Hour
+	transferTTL   = 7 * 24 * time.Hour
+
+	defaultDeleteGrace = 30 * 24 * time.Hour
 )
 
 type WorkspaceService interface {
@@ -12,22 +29,68 @@ type WorkspaceService interface {
 	GetMyWorkspaces(userID uint, search string) ([]dto.WorkspaceResponse, error)
 	GetWorkspaceDetail(userID uint, workspaceID uint) (*dto.WorkspaceDetailResponse, error)
 	UpdateWorkspace(userID uint, workspaceID uint, req dto.UpdateWorkspaceRequest) (*models.Workspace, error)
-	DeleteWorkspace(userID uint, workspaceID uint) error
+	DeleteWorkspace(userID uint, workspaceID uint, filePolicy string) (*dto.DeletedWorkspaceResponse, error)
+	ListDeletedWorkspaces(userID uint) ([]dto.DeletedWorkspaceResponse, error)
+	RestoreWorkspace(userID uint, workspaceID uint) (*models.Workspace, error)
+	PurgeDeletedWorkspaces() error
 	AddMember(requesterID uint, workspaceID uint, req dto.AddMemberRequest) error
 	UpdateMemberRole(requesterID uint, workspaceID uint, targetUserID uint, req dto.UpdateMemberRoleRequest) error
 	RemoveMember(requesterID uint, workspaceID uint, targetUserID uint) error
+	InviteMember(requesterID uint, workspaceID uint, req dto.InviteMemberRequest) (*dto.InvitationResponse, error)
+	ListWorkspaceInvitations(requesterID uint, workspaceID uint) ([]dto.InvitationResponse, error)
+	RevokeInvitation(requesterID uint, workspaceID uint, invitationID uint) error
+	ListMyInvitations(userID uint) ([]dto.InvitationResponse, error)
+	AcceptInvitation(userID uint, invitationID uint, req dto.RespondInvitationRequest) error
+	DeclineInvitation(userID uint, invitationID uint) error
+	LeaveWorkspace(userID uint, workspaceID uint) error
+	RequestOwnershipTransfer(requesterID uint, workspaceID uint, req dto.TransferOwnershipRequest) (*dto.OwnershipTransferResponse, error)
+	GetPendingTransfer(userID uint, workspaceID uint) (*dto.OwnershipTransferResponse, error)
+	RespondOwnershipTransfer(userID uint, workspaceID uint, accept bool) error
+	CancelOwnershipTransfer(requesterID uint, workspaceID uint) error
 }
 
 type workspaceService struct {
-	repo     repositories.WorkspaceRepository
-	userRepo repositories.UserRepositoryInterface
+	repo           repositories.WorkspaceRepository
+	userRepo       repositories.UserRepositoryInterface
+	invitationRepo repositories.InvitationRepository
+	mailer         mailer.Mailer
+	appURL         string
+	deleteGrace    time.Duration
+	events         EventPublisher
+}
+
+func NewWorkspaceService(repo repositories.WorkspaceRepository, userRepo repositories.UserRepositoryInterface, invitationRepo repositories.InvitationRepository, mail mailer.Mailer, appURL string, jobs *jobqueue.Queue, events EventPublisher) (WorkspaceService, error) {
+	service := &workspaceService{
+		repo:           repo,
+		userRepo:       userRepo,
+		invitationRepo: invitationRepo,
+		mailer:         mail,
+		appURL:         appURL,
+		deleteGrace:    deleteGraceFromEnv(),
+		events:         events,
+	}
+
+	purge := jobqueue.Register(jobs, "purge_workspaces", jobqueue.HandlerOptions{MaxAttempts: 1}, func(ctx context.Context, _ struct{}) error {
+		return service.PurgeDeletedWorkspaces()
+	})
+	if err := purge.Schedule("purge-workspaces", "@hourly", struct{}{}); err != nil {
+		return nil, err
+	}
+	return service, nil
 }
 
-func NewWorkspaceService(repo repositories.WorkspaceRepository, userRepo repositories.UserRepositoryInterface) WorkspaceService {
-	return &workspaceService{
-		repo:     repo,
-		userRepo: userRepo,
+// deleteGraceFromEnv membaca WORKSPACE_DELETE_GRACE (durasi Go, mis. "720h")
+func deleteGraceFromEnv() time.Duration {
+	raw := os.Getenv("WORKSPACE_DELETE_GRACE")
+	if raw == "" {
+		return defaultDeleteGrace
 	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		log.Printf("invalid WORKSPACE_DELETE_GRACE %q, using %s", raw, defaultDeleteGrace)
+		return defaultDeleteGrace
+	}
+	return d
 }
 func (s *workspaceService) CreateWorkspace(userID uint, req dto.CreateWorkspaceRequest) (*models.Workspace, error) {
 
@@ -119,11 +182,13 @@ func (s *workspaceService) GetWorkspaceDetail(userID uint, workspaceID uint) (*d
 	}
 
 	response := &dto.WorkspaceDetailResponse{
-		ID:          workspace.ID,
-		Name:        workspace.Name,
-		Description: workspace.Description,
-		OwnerID:     workspace.OwnerID,
-		Members:     memberResponses,
+		ID:                 workspace.ID,
+		Name:               workspace.Name,
+		Description:        workspace.Description,
+		OwnerID:            workspace.OwnerID,
+		StripImageMetadata: workspace.StripImageMetadata,
+		RequireCleanScan:   workspace.RequireCleanScan,
+		Members:            memberResponses,
 	}
 
 	return response, nil
@@ -155,6 +220,12 @@ func (s *workspaceService) UpdateWorkspace(userID uint, workspaceID uint, req dt
 		workspace.Name = req.Name
 	}
 	workspace.Description = req.Description
+	if req.StripImageMetadata != nil {
+		workspace.StripImageMetadata = *req.StripImageMetadata
+	}
+	if req.RequireCleanScan != nil {
+		workspace.RequireCleanScan = *req.RequireCleanScan
+	}
 
 	if err := s.repo.Update(workspace); err != nil {
 		return nil, err
@@ -163,18 +234,84 @@ func (s *workspaceService) UpdateWorkspace(userID uint, workspaceID uint, req dt
 	return workspace, nil
 }
 
-func (s *workspaceService) DeleteWorkspace(userID uint, workspaceID uint) error {
+func (s *workspaceService) DeleteWorkspace(userID uint, workspaceID uint, filePolicy string) (*dto.DeletedWorkspaceResponse, error) {
+
+	if filePolicy == "" {
+		filePolicy = models.WorkspaceFilesMove
+	}
+	if filePolicy != models.WorkspaceFilesMove && filePolicy != models.WorkspaceFilesTrash {
+		return nil, errors.New("invalid file policy: must be move or trash")
+	}
 
 	workspace, err := s.repo.FindByID(workspaceID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if workspace.OwnerID != userID {
-		return errors.New("unauthorized: only owner can delete workspace")
+		return nil, errors.New("unauthorized: only owner can delete workspace")
 	}
 
-	return s.repo.Delete(workspaceID)
+	purgeAt := time.Now().Add(s.deleteGrace)
+	if err := s.repo.DeleteWithPolicy(workspaceID, filePolicy, purgeAt); err != nil {
+		return nil, err
+	}
+
+	return &dto.DeletedWorkspaceResponse{
+		ID:         workspace.ID,
+		Name:       workspace.Name,
+		FilePolicy: filePolicy,
+		DeletedAt:  time.Now(),
+		PurgeAt:    purgeAt,
+	}, nil
+}
+
+func (s *workspaceService) ListDeletedWorkspaces(userID uint) ([]dto.DeletedWorkspaceResponse, error) {
+	workspaces, err := s.repo.FindDeletedByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []dto.DeletedWorkspaceResponse
+	for _, ws := range workspaces {
+		item := dto.DeletedWorkspaceResponse{
+			ID:         ws.ID,
+			Name:       ws.Name,
+			FilePolicy: ws.FilePolicy,
+			DeletedAt:  ws.DeletedAt.Time,
+		}
+		if ws.PurgeAt != nil {
+			item.PurgeAt = *ws.PurgeAt
+		}
+		response = append(response, item)
+	}
+	return response, nil
+}
+
+func (s *workspaceService) RestoreWorkspace(userID uint, workspaceID uint) (*models.Workspace, error) {
+	workspace, err := s.repo.Restore(workspaceID, userID)
+	if err != nil {
+		return nil, errors.New("deleted workspace not found or grace period has expired")
+	}
+	return workspace, nil
+}
+
+// PurgeDeletedWorkspaces menghapus permanen workspace yang grace period-nya habis
+func (s *workspaceService) PurgeDeletedWorkspaces() error {
+	workspaces, err := s.repo.FindPurgeable(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, ws := range workspaces {
+		blobs, err := s.repo.Purge(ws.ID)
+		if err != nil {
+			log.Printf("failed to purge workspace %d: %v", ws.ID, err)
+			continue
+		}
+		removeBlobs(blobs)
+	}
+	return nil
 }
 
 func (s *workspaceService) AddMember(requesterID uint, workspaceID uint, req dto.AddMemberRequest) error {
@@ -203,7 +340,11 @@ func (s *workspaceService) AddMember(requesterID uint, workspaceID uint, req dto
 		Role:        "viewer",
 	}
 
-	return s.repo.AddMember(&newMember)
+	if err := s.repo.AddMember(&newMember); err != nil {
+		return err
+	}
+	s.publish(memberEvent(models.EventMemberAdded, requesterID, workspaceID, newMember.UserID, newMember.Role))
+	return nil
 }
 
 func (s *workspaceService) UpdateMemberRole(requesterID uint, workspaceID uint, targetUserID uint, req dto.UpdateMemberRoleRequest) error {
@@ -245,5 +386,375 @@ func (s *workspaceService) RemoveMember(requesterID uint, workspaceID uint, targ
 		return errors.New("cannot remove workspace owner")
 	}
 
-	return s.repo.RemoveMember(workspaceID, targetUserID)
+	if err := s.repo.RemoveMember(workspaceID, targetUserID); err != nil {
+		return err
+	}
+	s.publish(memberEvent(models.EventMemberRemoved, requesterID, workspaceID, targetUserID, targetMember.Role))
+	return nil
+}
+
+func (s *workspaceService) InviteMember(requesterID uint, workspaceID uint, req dto.InviteMemberRequest) (*dto.InvitationResponse, error) {
+
+	requester, err := s.repo.FindMember(workspaceID, requesterID)
+	if err != nil {
+		return nil, errors.New("access denied: you are not a member of this workspace")
+	}
+	if requester.Role != "owner" && requester.Role != "admin" {
+		return nil, errors.New("unauthorized: only owner or admin can invite members")
+	}
+
+	workspace, err := s.repo.FindByID(workspaceID)
+	if err != nil {
+		return nil, errors.New("workspace not found")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.RoleViewer
+	}
+
+	var inviteeID *uint
+	if targetUser, err := s.userRepo.FindByEmail(req.Email); err == nil {
+		if _, err := s.repo.FindMember(workspaceID, targetUser.ID); err == nil {
+			return nil, errors.New("user is already a member of this workspace")
+		}
+		inviteeID = &targetUser.ID
+	}
+
+	rawToken, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// undangan yang masih pending untuk email yang sama diperbarui, bukan diduplikasi
+	invitation, err := s.invitationRepo.FindPending(workspaceID, req.Email)
+	if err != nil {
+		invitation = &models.WorkspaceInvitation{
+			WorkspaceID: workspaceID,
+			Email:       strings.ToLower(req.Email),
+			Status:      models.InvitationPending,
+		}
+	}
+	invitation.InviteeID = inviteeID
+	invitation.Role = role
+	invitation.InvitedByID = requesterID
+	invitation.TokenHash = tokenHash
+	invitation.ExpiresAt = time.Now().Add(invitationTTL)
+
+	if invitation.ID == 0 {
+		err = s.invitationRepo.Create(invitation)
+	} else {
+		err = s.invitationRepo.Update(invitation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inviter, _ := s.userRepo.FindByID(requesterID)
+	inviterName := ""
+	if inviter != nil {
+		inviterName = inviter.Username
+	}
+
+	msg, err := mailer.Render(mailer.TemplateInvitation, invitation.Email, map[string]string{
+		"InviterName":   inviterName,
+		"WorkspaceName": workspace.Name,
+		"Role":          role,
+		"Email":         invitation.Email,
+		"Link":          fmt.Sprintf("%s/invitations/%d?token=%s", s.appURL, invitation.ID, url.QueryEscape(rawToken)),
+		"ExpiresIn":     "7 days",
+	})
+	if err == nil {
+		err = s.mailer.Send(msg)
+	}
+	if err != nil {
+		// undangan tetap tersimpan, user terdaftar masih bisa melihatnya di GET /invitations
+		log.Printf("failed to send invitation %d: %v", invitation.ID, err)
+	}
+
+	response := toInvitationResponse(*invitation)
+	response.WorkspaceName = workspace.Name
+	response.InvitedBy = inviterName
+	return &response, nil
+}
+
+func (s *workspaceService) ListWorkspaceInvitations(requesterID uint, workspaceID uint) ([]dto.InvitationResponse, error) {
+
+	requester, err := s.repo.FindMember(workspaceID, requesterID)
+	if err != nil || (requester.Role != "owner" && requester.Role != "admin") {
+		return nil, errors.New("unauthorized")
+	}
+
+	invitations, err := s.invitationRepo.ListPendingByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.InvitationResponse
+	for _, inv := range invitations {
+		responses = append(responses, toInvitationResponse(inv))
+	}
+	return responses, nil
+}
+
+func (s *workspaceService) RevokeInvitation(requesterID uint, workspaceID uint, invitationID uint) error {
+
+	requester, err := s.repo.FindMember(workspaceID, requesterID)
+	if err != nil || (requester.Role != "owner" && requester.Role != "admin") {
+		return errors.New("unauthorized")
+	}
+
+	invitation, err := s.invitationRepo.FindByID(invitationID)
+	if err != nil || invitation.WorkspaceID != workspaceID {
+		return errors.New("invitation not found")
+	}
+	if invitation.Status != models.InvitationPending {
+		return errors.New("invitation is no longer pending")
+	}
+
+	now := time.Now()
+	invitation.Status = models.InvitationRevoked
+	invitation.RespondedAt = &now
+	return s.invitationRepo.Update(invitation)
+}
+
+func (s *workspaceService) ListMyInvitations(userID uint) ([]dto.InvitationResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.invitationRepo.ListPendingForUser(user.ID, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.InvitationResponse
+	for _, inv := range invitations {
+		responses = append(responses, toInvitationResponse(inv))
+	}
+	return responses, nil
+}
+
+func (s *workspaceService) AcceptInvitation(userID uint, invitationID uint, req dto.RespondInvitationRequest) error {
+	user, invitation, err := s.findInvitationForUser(userID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	// token dari email membuktikan kepemilikan email; tanpa token email akun harus sudah diverifikasi
+	tokenValid := req.Token != "" && utils.HashOpaqueToken(req.Token) == invitation.TokenHash
+	if !tokenValid && user.VerifiedAt == nil {
+		return errors.New("please verify your email address before accepting invitations")
+	}
+
+	now := time.Now()
+	invitation.Status = models.InvitationAccepted
+	invitation.RespondedAt = &now
+	invitation.InviteeID = &user.ID
+
+	if _, err := s.repo.FindMember(invitation.WorkspaceID, userID); err == nil {
+		_ = s.invitationRepo.Update(invitation)
+		return errors.New("you are already a member of this workspace")
+	}
+
+	member := &models.WorkspaceMember{
+		WorkspaceID: invitation.WorkspaceID,
+		UserID:      userID,
+		Role:        invitation.Role,
+	}
+	if err := s.invitationRepo.Accept(invitation, member); err != nil {
+		return err
+	}
+	s.publish(memberEvent(models.EventMemberAdded, userID, member.WorkspaceID, userID, member.Role))
+	return nil
+}
+
+func (s *workspaceService) DeclineInvitation(userID uint, invitationID uint) error {
+	_, invitation, err := s.findInvitationForUser(userID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invitation.Status = models.InvitationDeclined
+	invitation.RespondedAt = &now
+	return s.invitationRepo.Update(invitation)
+}
+
+func (s *workspaceService) findInvitationForUser(userID uint, invitationID uint) (*models.User, *models.WorkspaceInvitation, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invitation, err := s.invitationRepo.FindByID(invitationID)
+	if err != nil {
+		return nil, nil, errors.New("invitation not found")
+	}
+
+	isInvitee := (invitation.InviteeID != nil && *invitation.InviteeID == user.ID) || strings.EqualFold(invitation.Email, user.Email)
+	if !isInvitee {
+		return nil, nil, errors.New("invitation not found")
+	}
+	if invitation.Status != models.InvitationPending {
+		return nil, nil, errors.New("invitation is no longer pending")
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, nil, errors.New("invitation has expired")
+	}
+	if invitation.Workspace.ID == 0 {
+		return nil, nil, errors.New("workspace no longer exists")
+	}
+
+	return user, invitation, nil
+}
+
+func toInvitationResponse(inv models.WorkspaceInvitation) dto.InvitationResponse {
+	return dto.InvitationResponse{
+		ID:            inv.ID,
+		WorkspaceID:   inv.WorkspaceID,
+		WorkspaceName: inv.Workspace.Name,
+		Email:         inv.Email,
+		Role:          inv.Role,
+		Status:        inv.Status,
+		InvitedBy:     inv.InvitedBy.Username,
+		ExpiresAt:     inv.ExpiresAt,
+		CreatedAt:     inv.CreatedAt,
+	}
+}
+
+func (s *workspaceService) LeaveWorkspace(userID uint, workspaceID uint) error {
+
+	member, err := s.repo.FindMember(workspaceID, userID)
+	if err != nil {
+		return errors.New("you are not a member of this workspace")
+	}
+	if member.Role == models.RoleOwner {
+		return errors.New("the owner cannot leave the workspace, transfer ownership first")
+	}
+
+	// transfer yang ditujukan ke user ini tidak bisa diterima lagi
+	if transfer, err := s.repo.FindPendingTransfer(workspaceID); err == nil && transfer.ToUserID == userID {
+		now := time.Now()
+		transfer.Status = models.TransferCancelled
+		transfer.RespondedAt = &now
+		_ = s.repo.UpdateTransfer(transfer)
+	}
+
+	if err := s.repo.RemoveMember(workspaceID, userID); err != nil {
+		return err
+	}
+	s.publish(memberEvent(models.EventMemberRemoved, userID, workspaceID, userID, member.Role))
+	return nil
+}
+
+func (s *workspaceService) publish(event Event) {
+	if s.events != nil {
+		s.events.Publish(event)
+	}
+}
+
+func (s *workspaceService) RequestOwnershipTransfer(requesterID uint, workspaceID uint, req dto.TransferOwnershipRequest) (*dto.OwnershipTransferResponse, error) {
+
+	workspace, err := s.repo.FindByID(workspaceID)
+	if err != nil {
+		return nil, errors.New("workspace not found")
+	}
+	if workspace.OwnerID != requesterID {
+		return nil, errors.New("unauthorized: only owner can transfer ownership")
+	}
+	if req.UserID == requesterID {
+		return nil, errors.New("you already own this workspace")
+	}
+
+	if _, err := s.repo.FindMember(workspaceID, req.UserID); err != nil {
+		return nil, errors.New("new owner must be a member of this workspace")
+	}
+
+	// hanya boleh ada satu transfer pending per workspace
+	if existing, err := s.repo.FindPendingTransfer(workspaceID); err == nil {
+		now := time.Now()
+		existing.Status = models.TransferCancelled
+		existing.RespondedAt = &now
+		if err := s.repo.UpdateTransfer(existing); err != nil {
+			return nil, err
+		}
+	}
+
+	transfer := &models.OwnershipTransfer{
+		WorkspaceID: workspaceID,
+		FromUserID:  requesterID,
+		ToUserID:    req.UserID,
+		Status:      models.TransferPending,
+		ExpiresAt:   time.Now().Add(transferTTL),
+	}
+	if err := s.repo.CreateTransfer(transfer); err != nil {
+		return nil, err
+	}
+
+	created, err := s.repo.FindPendingTransfer(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return toTransferResponse(created), nil
+}
+
+func (s *workspaceService) GetPendingTransfer(userID uint, workspaceID uint) (*dto.OwnershipTransferResponse, error) {
+
+	if _, err := s.repo.FindMember(workspaceID, userID); err != nil {
+		return nil, errors.New("you are not a member of this workspace")
+	}
+
+	transfer, err := s.repo.FindPendingTransfer(workspaceID)
+	if err != nil {
+		return nil, errors.New("no pending ownership transfer")
+	}
+	return toTransferResponse(transfer), nil
+}
+
+func (s *workspaceService) RespondOwnershipTransfer(userID uint, workspaceID uint, accept bool) error {
+
+	transfer, err := s.repo.FindPendingTransfer(workspaceID)
+	if err != nil || transfer.ToUserID != userID {
+		return errors.New("no pending ownership transfer for you")
+	}
+
+	now := time.Now()
+	transfer.RespondedAt = &now
+
+	if !accept {
+		transfer.Status = models.TransferDeclined
+		return s.repo.UpdateTransfer(transfer)
+	}
+
+	transfer.Status = models.TransferAccepted
+	return s.repo.CompleteTransfer(transfer)
+}
+
+func (s *workspaceService) CancelOwnershipTransfer(requesterID uint, workspaceID uint) error {
+
+	transfer, err := s.repo.FindPendingTransfer(workspaceID)
+	if err != nil || transfer.FromUserID != requesterID {
+		return errors.New("no pending ownership transfer")
+	}
+
+	now := time.Now()
+	transfer.Status = models.TransferCancelled
+	transfer.RespondedAt = &now
+	return s.repo.UpdateTransfer(transfer)
+}
+
+func toTransferResponse(t *models.OwnershipTransfer) *dto.OwnershipTransferResponse {
+	return &dto.OwnershipTransferResponse{
+		ID:          t.ID,
+		WorkspaceID: t.WorkspaceID,
+		FromUserID:  t.FromUserID,
+		FromName:    t.FromUser.Username,
+		ToUserID:    t.ToUserID,
+		ToName:      t.ToUser.Username,
+		Status:      t.Status,
+		ExpiresAt:   t.ExpiresAt,
+		CreatedAt:   t.CreatedAt,
+	}
 }
@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 	"vasvault/internal/repositories"
 	"vasvault/internal/routes"
+	"vasvault/pkg/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,21 +18,24 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		fmt.Println("No .env file found, using system environment")
 	}
+	if err := utils.LoadJWTKeys(); err != nil {
+		panic(fmt.Sprintf("Failed to load JWT keys: %v", err))
+	}
 	db, err := repositories.Connect()
 	if err != nil {
 		panic("Failed to connect to database")
 	}
 
 	r := gin.Default()
-
-	// serve uploaded files statically (public path)
-	r.Static("/uploads", "./uploads")
+	if err := configureProxies(r); err != nil {
+		panic(fmt.Sprintf("Invalid TRUSTED_PROXIES: %v", err))
+	}
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Last-Event-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -39,3 +45,34 @@ func main() {
 		panic("Failed to run server")
 	}
 }
+
+// configureProxies decides where ClientIP (and so per-IP rate limits) comes
+// from. By default forwarding headers are ignored and the connection's
+// remote address is used. TRUSTED_PROXIES (comma-separated IPs or CIDRs)
+// trusts X-Forwarded-For from those proxies; TRUSTED_PLATFORM reads the
+// client IP from a platform header instead ("cloudflare", "google", "flyio"
+// or any header name).
+func configureProxies(r *gin.Engine) error {
+	var proxies []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		return err
+	}
+
+	switch platform := os.Getenv("TRUSTED_PLATFORM"); strings.ToLower(platform) {
+	case "":
+	case "cloudflare":
+		r.TrustedPlatform = gin.PlatformCloudflare
+	case "google":
+		r.TrustedPlatform = gin.PlatformGoogleAppEngine
+	case "flyio":
+		r.TrustedPlatform = gin.PlatformFlyIO
+	default:
+		r.TrustedPlatform = platform
+	}
+	return nil
+}
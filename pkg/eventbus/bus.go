@@ -0,0 +1,81 @@
+// Package eventbus fans messages out to subscribers in the same process.
+package eventbus
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Bus delivers every published message to all current subscribers. Publish
+// never blocks: a subscriber whose buffer is full is dropped and its channel
+// closed, so one slow reader cannot hold up the others.
+type Bus[T any] struct {
+	mu   sync.Mutex
+	subs map[*Subscription[T]]struct{}
+}
+
+func New[T any]() *Bus[T] {
+	return &Bus[T]{subs: make(map[*Subscription[T]]struct{})}
+}
+
+// Subscription receives messages on C until it is closed, either by Close or
+// because it fell behind (see Dropped).
+type Subscription[T any] struct {
+	C <-chan T
+
+	ch      chan T
+	bus     *Bus[T]
+	dropped atomic.Bool
+}
+
+// Subscribe returns a subscription that buffers up to buffer messages.
+func (b *Bus[T]) Subscribe(buffer int) *Subscription[T] {
+	ch := make(chan T, buffer)
+	s := &Subscription[T]{C: ch, ch: ch, bus: b}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Publish sends msg to every subscriber.
+func (b *Bus[T]) Publish(msg T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		select {
+		case s.ch <- msg:
+		default:
+			s.dropped.Store(true)
+			b.remove(s)
+		}
+	}
+}
+
+// Len returns the number of subscribers.
+func (b *Bus[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// remove must be called with b.mu held.
+func (b *Bus[T]) remove(s *Subscription[T]) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription[T]) Close() {
+	s.bus.mu.Lock()
+	s.bus.remove(s)
+	s.bus.mu.Unlock()
+}
+
+// Dropped reports whether the subscription was closed because its buffer
+// was full. Messages published after that point were missed.
+func (s *Subscription[T]) Dropped() bool {
+	return s.dropped.Load()
+}
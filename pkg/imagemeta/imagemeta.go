@@ -0,0 +1,188 @@
+// Package imagemeta reads and strips metadata blocks (EXIF, XMP, IPTC) in
+// JPEG and PNG files without decoding pixels.
+package imagemeta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	jpegSOI      = []byte{0xFF, 0xD8}
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+	exifHeader    = []byte("Exif\x00\x00")
+	xmpHeader     = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	xmpExtHeader  = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	photoshopHead = []byte("Photoshop 3.0\x00")
+)
+
+// ErrUnsupported is returned for anything that is not a JPEG or PNG.
+var ErrUnsupported = errors.New("imagemeta: unsupported image format")
+
+// Blocks holds the raw metadata found in an image.
+type Blocks struct {
+	Exif []byte // TIFF structure, starting with "II*\x00" or "MM\x00*"
+	IPTC []byte // IPTC-IIM datasets from the Photoshop APP13 segment
+}
+
+// Read returns the metadata blocks of a JPEG or PNG. Reading stops at the
+// first image data, so large files are never read completely.
+func Read(r io.Reader) (*Blocks, error) {
+	br := bufio.NewReader(r)
+	sig, err := br.Peek(len(pngSignature))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(sig, jpegSOI):
+		return readJPEG(br)
+	case bytes.HasPrefix(sig, pngSignature):
+		return readPNG(br)
+	}
+	return nil, ErrUnsupported
+}
+
+// Strip copies a JPEG or PNG from r to w without personal metadata:
+//   - in EXIF, the GPS directory and personal tags (owner, serial numbers,
+//     maker notes, comments) are zeroed in place, so orientation, camera model
+//     and capture time survive
+//   - XMP and IPTC blocks and JPEG comments are removed entirely
+//
+// EXIF that cannot be parsed is removed entirely. Pixel data is copied as is.
+func Strip(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	sig, err := br.Peek(len(pngSignature))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(sig, jpegSOI):
+		return stripJPEG(br, w)
+	case bytes.HasPrefix(sig, pngSignature):
+		return stripPNG(br, w)
+	}
+	return ErrUnsupported
+}
+
+// jpegSegment is one marker segment before the image scan.
+type jpegSegment struct {
+	marker  byte
+	payload []byte // nil for markers without a length field
+}
+
+// eachJPEGSegment calls fn for every segment up to and including SOS. After
+// SOS, the remaining entropy-coded data is left in r.
+func eachJPEGSegment(r *bufio.Reader, fn func(seg jpegSegment) error) error {
+	if _, err := r.Discard(len(jpegSOI)); err != nil {
+		return err
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != 0xFF {
+			return fmt.Errorf("imagemeta: invalid jpeg marker 0x%02x", b)
+		}
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF { // fill bytes
+			marker, err = r.ReadByte()
+		}
+		if err != nil {
+			return err
+		}
+
+		// markers without a length field
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD9) {
+			if err := fn(jpegSegment{marker: marker}); err != nil {
+				return err
+			}
+			if marker == 0xD9 {
+				return nil
+			}
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return err
+		}
+		if length < 2 {
+			return fmt.Errorf("imagemeta: invalid jpeg segment length")
+		}
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		if err := fn(jpegSegment{marker: marker, payload: payload}); err != nil {
+			return err
+		}
+		if marker == 0xDA { // SOS
+			return nil
+		}
+	}
+}
+
+func readJPEG(r *bufio.Reader) (*Blocks, error) {
+	blocks := &Blocks{}
+	err := eachJPEGSegment(r, func(seg jpegSegment) error {
+		switch {
+		case seg.marker == 0xE1 && bytes.HasPrefix(seg.payload, exifHeader) && blocks.Exif == nil:
+			blocks.Exif = seg.payload[len(exifHeader):]
+		case seg.marker == 0xED && bytes.HasPrefix(seg.payload, photoshopHead) && blocks.IPTC == nil:
+			blocks.IPTC = photoshopIPTC(seg.payload[len(photoshopHead):])
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func stripJPEG(r *bufio.Reader, w io.Writer) error {
+	if _, err := w.Write(jpegSOI); err != nil {
+		return err
+	}
+
+	err := eachJPEGSegment(r, func(seg jpegSegment) error {
+		switch {
+		case seg.marker == 0xE1 && bytes.HasPrefix(seg.payload, exifHeader):
+			if err := blankTIFF(seg.payload[len(exifHeader):]); err != nil {
+				return nil // unreadable EXIF: drop the segment
+			}
+		case seg.marker == 0xE1 && (bytes.HasPrefix(seg.payload, xmpHeader) || bytes.HasPrefix(seg.payload, xmpExtHeader)):
+			return nil
+		case seg.marker == 0xED && bytes.HasPrefix(seg.payload, photoshopHead):
+			return nil
+		case seg.marker == 0xFE: // comment
+			return nil
+		}
+
+		if _, err := w.Write([]byte{0xFF, seg.marker}); err != nil {
+			return err
+		}
+		if seg.payload == nil {
+			return nil
+		}
+		if err := binary.Write(w, binary.BigEndian, uint16(len(seg.payload)+2)); err != nil {
+			return err
+		}
+		_, err := w.Write(seg.payload)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
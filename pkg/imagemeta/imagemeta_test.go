@@ -0,0 +1,537 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// EXIF tags checked by the tests
+const (
+	tagMake             = 0x010F
+	tagOrientation      = 0x0112
+	tagArtist           = 0x013B
+	tagDateTimeOriginal = 0x9003
+	tagBodySerial       = 0xA431
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+)
+
+const (
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+const captureTime = "2024:05:01 10:00:00\x00"
+
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func ascii(s string) tiffEntry {
+	return tiffEntry{typ: typeASCII, count: uint32(len(s)), value: []byte(s)}
+}
+
+func (e tiffEntry) withTag(tag uint16) tiffEntry {
+	e.tag = tag
+	return e
+}
+
+func ifdSize(entries []tiffEntry) uint32 {
+	size := uint32(2 + 12*len(entries) + 4)
+	for _, e := range entries {
+		if len(e.value) > 4 {
+			size += uint32(len(e.value)+1) &^ 1
+		}
+	}
+	return size
+}
+
+// putIFD writes entries at offset, with out-of-line values right after the
+// directory
+func putIFD(b []byte, order binary.ByteOrder, offset uint32, entries []tiffEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+	order.PutUint16(b[offset:], uint16(len(entries)))
+	data := offset + 2 + 12*uint32(len(entries)) + 4
+	for i, e := range entries {
+		at := offset + 2 + 12*uint32(i)
+		order.PutUint16(b[at:], e.tag)
+		order.PutUint16(b[at+2:], e.typ)
+		order.PutUint32(b[at+4:], e.count)
+		if len(e.value) <= 4 {
+			copy(b[at+8:at+12], e.value)
+			continue
+		}
+		order.PutUint32(b[at+8:], data)
+		copy(b[data:], e.value)
+		data += uint32(len(e.value)+1) &^ 1
+	}
+}
+
+// buildExif returns a TIFF block with IFD0, an Exif sub-IFD and a GPS IFD
+// holding both kept and personal tags
+func buildExif(order binary.ByteOrder) []byte {
+	short := make([]byte, 2)
+	order.PutUint16(short, 6) // rotate 90° CW
+	latitude := make([]byte, 24)
+	for i, v := range []uint32{52, 1, 22, 1, 3812, 100} {
+		order.PutUint32(latitude[i*4:], v)
+	}
+
+	ifd0 := []tiffEntry{
+		ascii("Canon\x00").withTag(tagMake),
+		{tag: tagOrientation, typ: typeShort, count: 1, value: short},
+		ascii("Alice Example\x00").withTag(tagArtist),
+		{tag: tagExifIFD, typ: typeLong, count: 1, value: make([]byte, 4)},
+		{tag: tagGPSIFD, typ: typeLong, count: 1, value: make([]byte, 4)},
+	}
+	exif := []tiffEntry{
+		ascii(captureTime).withTag(tagDateTimeOriginal),
+		ascii("SN-0042-XYZ\x00").withTag(tagBodySerial),
+	}
+	gps := []tiffEntry{
+		ascii("N\x00").withTag(tagGPSLatitudeRef),
+		{tag: tagGPSLatitude, typ: typeRational, count: 3, value: latitude},
+	}
+
+	exifOffset := 8 + ifdSize(ifd0)
+	gpsOffset := exifOffset + ifdSize(exif)
+	order.PutUint32(ifd0[3].value, exifOffset)
+	order.PutUint32(ifd0[4].value, gpsOffset)
+
+	b := make([]byte, gpsOffset+ifdSize(gps))
+	if order == binary.ByteOrder(binary.LittleEndian) {
+		copy(b, "II*\x00")
+	} else {
+		copy(b, "MM\x00*")
+	}
+	order.PutUint32(b[4:], 8)
+	putIFD(b, order, 8, ifd0)
+	putIFD(b, order, exifOffset, exif)
+	putIFD(b, order, gpsOffset, gps)
+	return b
+}
+
+// readIFD returns the raw value of each tag in the IFD at offset
+func readIFD(t *testing.T, b []byte, offset uint32) map[uint16][]byte {
+	t.Helper()
+	order := binary.ByteOrder(binary.LittleEndian)
+	if string(b[:2]) == "MM" {
+		order = binary.BigEndian
+	}
+	if offset == 0 {
+		offset = order.Uint32(b[4:])
+	}
+
+	tags := make(map[uint16][]byte)
+	count := uint32(order.Uint16(b[offset:]))
+	for i := uint32(0); i < count; i++ {
+		at := offset + 2 + 12*i
+		size := tiffTypeSize[order.Uint16(b[at+2:])] * order.Uint32(b[at+4:])
+		if size <= 4 {
+			tags[order.Uint16(b[at:])] = b[at+8 : at+8+size]
+			continue
+		}
+		start := order.Uint32(b[at+8:])
+		tags[order.Uint16(b[at:])] = b[start : start+size]
+	}
+	return tags
+}
+
+func subIFD(t *testing.T, b []byte, ifd0 map[uint16][]byte, tag uint16) map[uint16][]byte {
+	t.Helper()
+	order := binary.ByteOrder(binary.LittleEndian)
+	if string(b[:2]) == "MM" {
+		order = binary.BigEndian
+	}
+	return readIFD(t, b, order.Uint32(ifd0[tag]))
+}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 32), 128, 255})
+		}
+	}
+	return img
+}
+
+func jpegSegmentBytes(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func iptcDataset(dataset byte, value string) []byte {
+	b := []byte{0x1C, 2, dataset, 0, 0}
+	binary.BigEndian.PutUint16(b[3:], uint16(len(value)))
+	return append(b, value...)
+}
+
+// photoshopSegment wraps IPTC datasets in an APP13 8BIM resource
+func photoshopSegment(iptc []byte) []byte {
+	res := append([]byte("8BIM\x04\x04\x00\x00"), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(res[8:], uint32(len(iptc)))
+	res = append(res, iptc...)
+	if len(iptc)%2 != 0 {
+		res = append(res, 0)
+	}
+	return append(append([]byte{}, photoshopHead...), res...)
+}
+
+// buildJPEG inserts EXIF, XMP, IPTC and a comment right after SOI of an
+// encoded image
+func buildJPEG(t *testing.T, exif []byte) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	iptc := append(iptcDataset(5, "Holiday"), iptcDataset(80, "Alice Example")...)
+	iptc = append(iptc, iptcDataset(90, "Amsterdam")...)
+
+	var out bytes.Buffer
+	out.Write(jpegSOI)
+	out.Write(jpegSegmentBytes(0xE1, append(append([]byte{}, exifHeader...), exif...)))
+	out.Write(jpegSegmentBytes(0xE1, append(append([]byte{}, xmpHeader...), `<x:xmpmeta>Alice Example</x:xmpmeta>`...)))
+	out.Write(jpegSegmentBytes(0xED, photoshopSegment(iptc)))
+	out.Write(jpegSegmentBytes(0xFE, []byte("shot at Alice Example's house")))
+	out.Write(encoded.Bytes()[len(jpegSOI):])
+	return out.Bytes()
+}
+
+// buildPNG inserts an eXIf chunk and two tEXt chunks after IHDR
+func buildPNG(t *testing.T, exif []byte) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	src := encoded.Bytes()
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+
+	var out bytes.Buffer
+	out.Write(src[:ihdrEnd])
+	for _, c := range []struct {
+		typ  string
+		data []byte
+	}{
+		{"eXIf", exif},
+		{"tEXt", []byte("Author\x00Alice Example")},
+		{"tEXt", []byte("Software\x00vasvault-test")},
+	} {
+		if err := writePNGChunk(&out, c.typ, c.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Write(src[ihdrEnd:])
+	return out.Bytes()
+}
+
+func strip(t *testing.T, in []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Strip(bytes.NewReader(in), &out); err != nil {
+		t.Fatalf("Strip: %v", err)
+	}
+	return out.Bytes()
+}
+
+func assertDecodes(t *testing.T, b []byte, format string) {
+	t.Helper()
+	img, got, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("stripped image does not decode: %v", err)
+	}
+	if got != format {
+		t.Fatalf("decoded as %s, want %s", got, format)
+	}
+	if img.Bounds() != testImage().Bounds() {
+		t.Fatalf("bounds = %v, want %v", img.Bounds(), testImage().Bounds())
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		build  func(t *testing.T, exif []byte) []byte
+		order  binary.ByteOrder
+	}{
+		{"jpeg little endian", "jpeg", buildJPEG, binary.LittleEndian},
+		{"jpeg big endian", "jpeg", buildJPEG, binary.BigEndian},
+		{"png little endian", "png", buildPNG, binary.LittleEndian},
+		{"png big endian", "png", buildPNG, binary.BigEndian},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.build(t, buildExif(tt.order))
+			out := strip(t, in)
+			assertDecodes(t, out, tt.format)
+
+			blocks, err := Read(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if blocks.Exif == nil {
+				t.Fatal("EXIF was removed, want it blanked in place")
+			}
+			if len(blocks.Exif) != len(buildExif(tt.order)) {
+				t.Fatalf("EXIF size changed: %d, want %d", len(blocks.Exif), len(buildExif(tt.order)))
+			}
+
+			ifd0 := readIFD(t, blocks.Exif, 0)
+			if got := string(ifd0[tagMake]); got != "Canon\x00" {
+				t.Errorf("Make = %q, want kept", got)
+			}
+			if got := tt.order.Uint16(ifd0[tagOrientation]); got != 6 {
+				t.Errorf("Orientation = %d, want 6", got)
+			}
+			if !isZero(ifd0[tagArtist]) {
+				t.Errorf("Artist = %q, want blanked", ifd0[tagArtist])
+			}
+
+			exif := subIFD(t, blocks.Exif, ifd0, tagExifIFD)
+			if got := string(exif[tagDateTimeOriginal]); got != captureTime {
+				t.Errorf("DateTimeOriginal = %q, want kept", got)
+			}
+			if !isZero(exif[tagBodySerial]) {
+				t.Errorf("BodySerialNumber = %q, want blanked", exif[tagBodySerial])
+			}
+
+			if gps := subIFD(t, blocks.Exif, ifd0, tagGPSIFD); len(gps) != 0 {
+				t.Errorf("GPS directory has %d entries, want 0", len(gps))
+			}
+			if bytes.Contains(out, buildExif(tt.order)[len(buildExif(tt.order))-24:]) {
+				t.Error("GPS coordinates still present in the output")
+			}
+
+			if blocks.IPTC != nil {
+				t.Error("IPTC block survived")
+			}
+			if bytes.Contains(out, []byte("Alice Example")) {
+				t.Error("personal text survived in XMP, comment or text chunk")
+			}
+		})
+	}
+}
+
+func TestStripKeepsNonPersonalPNGText(t *testing.T) {
+	out := strip(t, buildPNG(t, buildExif(binary.LittleEndian)))
+	if !bytes.Contains(out, []byte("Software\x00vasvault-test")) {
+		t.Fatal("Software text chunk was removed")
+	}
+}
+
+func TestRead(t *testing.T) {
+	exif := buildExif(binary.BigEndian)
+	blocks, err := Read(bytes.NewReader(buildJPEG(t, exif)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(blocks.Exif, exif) {
+		t.Fatal("EXIF block differs from the embedded one")
+	}
+
+	iptc := ParseIPTC(blocks.IPTC)
+	if iptc == nil {
+		t.Fatal("IPTC not found")
+	}
+	if iptc.Title != "Holiday" || iptc.Creator != "Alice Example" || iptc.City != "Amsterdam" {
+		t.Fatalf("unexpected IPTC: %+v", iptc)
+	}
+
+	blocks, err = Read(bytes.NewReader(buildPNG(t, exif)))
+	if err != nil {
+		t.Fatalf("Read png: %v", err)
+	}
+	if !bytes.Equal(blocks.Exif, exif) {
+		t.Fatal("PNG eXIf block differs from the embedded one")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for _, in := range [][]byte{nil, []byte("GIF89a"), []byte("\xFF")} {
+		if _, err := Read(bytes.NewReader(in)); err != ErrUnsupported {
+			t.Errorf("Read(%q) = %v, want ErrUnsupported", in, err)
+		}
+		if err := Strip(bytes.NewReader(in), &bytes.Buffer{}); err != ErrUnsupported {
+			t.Errorf("Strip(%q) = %v, want ErrUnsupported", in, err)
+		}
+	}
+}
+
+// TestStripInvalidExif: EXIF that cannot be walked safely is dropped, and
+// the image still decodes
+func TestStripInvalidExif(t *testing.T) {
+	valid := buildExif(binary.LittleEndian)
+	le := binary.LittleEndian
+
+	tests := []struct {
+		name   string
+		mutate func(b []byte) []byte
+	}{
+		{"bad byte order", func(b []byte) []byte { copy(b, "XX*\x00"); return b }},
+		{"header only", func(b []byte) []byte { return b[:6] }},
+		{"IFD0 offset out of range", func(b []byte) []byte { le.PutUint32(b[4:], 1<<30); return b }},
+		{"entry count past the end", func(b []byte) []byte { le.PutUint16(b[8:], 0xFFFF); return b }},
+		{"IFD chain loop", func(b []byte) []byte {
+			next := 8 + 2 + 12*5
+			le.PutUint32(b[next:], 8)
+			return b
+		}},
+		{"GPS pointer out of range", func(b []byte) []byte {
+			le.PutUint32(b[8+2+12*4+8:], uint32(len(b)+100))
+			return b
+		}},
+		{"unknown field type on personal tag", func(b []byte) []byte {
+			le.PutUint16(b[8+2+12*2+2:], 99) // Artist is the third entry
+			return b
+		}},
+		{"out-of-line value past the end", func(b []byte) []byte {
+			le.PutUint32(b[8+2+12*2+8:], uint32(len(b)-2))
+			return b
+		}},
+		{"truncated in the GPS directory", func(b []byte) []byte { return b[:len(b)-30] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exif := tt.mutate(append([]byte{}, valid...))
+			for format, build := range map[string]func(*testing.T, []byte) []byte{"jpeg": buildJPEG, "png": buildPNG} {
+				out := strip(t, build(t, exif))
+				assertDecodes(t, out, format)
+				blocks, err := Read(bytes.NewReader(out))
+				if err != nil {
+					t.Fatalf("%s: Read: %v", format, err)
+				}
+				if blocks.Exif != nil {
+					t.Fatalf("%s: invalid EXIF was kept", format)
+				}
+			}
+		})
+	}
+}
+
+// TestTruncatedAndCorrupt feeds every prefix and random byte flips of the
+// fixtures to Read, Strip and ParseIPTC. Errors are fine, panics are not.
+func TestTruncatedAndCorrupt(t *testing.T) {
+	fixtures := map[string][]byte{
+		"jpeg": buildJPEG(t, buildExif(binary.LittleEndian)),
+		"png":  buildPNG(t, buildExif(binary.BigEndian)),
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	for name, fixture := range fixtures {
+		var inputs [][]byte
+		for n := 0; n < len(fixture); n++ {
+			inputs = append(inputs, fixture[:n])
+		}
+		for i := 0; i < 500; i++ {
+			b := append([]byte{}, fixture...)
+			for j := 0; j < 1+rng.Intn(8); j++ {
+				b[2+rng.Intn(len(b)-2)] = byte(rng.Intn(256))
+			}
+			inputs = append(inputs, b)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			for _, in := range inputs {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("panic on %d byte input: %v", len(in), r)
+						}
+					}()
+					if blocks, err := Read(bytes.NewReader(in)); err == nil {
+						ParseIPTC(blocks.IPTC)
+					}
+					Strip(bytes.NewReader(in), &bytes.Buffer{})
+				}()
+			}
+		})
+	}
+}
+
+func TestPhotoshopIPTCMalformed(t *testing.T) {
+	iptc := iptcDataset(5, "Title")
+	valid := photoshopSegment(iptc)[len(photoshopHead):]
+
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{"valid", valid, iptc},
+		{"other resource first", append([]byte("8BIM\x04\x0c\x00\x00\x00\x00\x00\x01x\x00"), valid...), iptc},
+		{"size past the end", []byte("8BIM\x04\x04\x00\x00\x7f\xff\xff\xff"), nil},
+		{"negative size", []byte("8BIM\x04\x04\x00\x00\xff\xff\xff\xff"), nil},
+		{"long name", []byte("8BIM\x04\x04\xff\x00\x00\x00\x00\x00"), nil},
+		{"truncated", valid[:10], nil},
+		{"not 8BIM", []byte("XXXX\x04\x04\x00\x00\x00\x00\x00\x00"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := photoshopIPTC(tt.in); !bytes.Equal(got, tt.want) {
+				t.Fatalf("photoshopIPTC = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIPTC(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want *IPTC
+	}{
+		{"empty", nil, nil},
+		{"keywords", append(iptcDataset(25, "a"), iptcDataset(25, "b")...), &IPTC{Keywords: []string{"a", "b"}}},
+		{"latin-1 fallback", iptcDataset(90, "K\xf8benhavn"), &IPTC{City: "København"}},
+		{"other record ignored", []byte{0x1C, 1, 90, 0, 1, 'x'}, nil},
+		{"size past the end", []byte{0x1C, 2, 5, 0, 9, 'x'}, nil},
+		{"extended dataset", []byte{0x1C, 2, 5, 0x80, 4, 0, 0, 0, 1, 'x'}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseIPTC(tt.in)
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("ParseIPTC = %+v, want %+v", got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			if got.City != tt.want.City || len(got.Keywords) != len(tt.want.Keywords) {
+				t.Fatalf("ParseIPTC = %+v, want %+v", got, tt.want)
+			}
+			for i := range got.Keywords {
+				if got.Keywords[i] != tt.want.Keywords[i] {
+					t.Fatalf("keyword %d = %q, want %q", i, got.Keywords[i], tt.want.Keywords[i])
+				}
+			}
+		})
+	}
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return len(b) > 0
+}
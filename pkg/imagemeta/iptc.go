@@ -0,0 +1,115 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+// IPTC holds the commonly used IPTC-IIM application record fields.
+type IPTC struct {
+	Title     string
+	Caption   string
+	Creator   string
+	Copyright string
+	City      string
+	Country   string
+	Keywords  []string
+}
+
+// photoshopIPTC returns the IPTC-IIM resource (ID 0x0404) from the image
+// resource blocks of a Photoshop APP13 segment.
+func photoshopIPTC(b []byte) []byte {
+	for len(b) >= 12 && bytes.HasPrefix(b, []byte("8BIM")) {
+		id := binary.BigEndian.Uint16(b[4:6])
+
+		// Pascal string name, padded to an even length including the length byte
+		nameLen := int(b[6]) + 1
+		if nameLen%2 != 0 {
+			nameLen++
+		}
+		pos := 6 + nameLen
+		if len(b) < pos+4 {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(b[pos:]))
+		pos += 4
+		if size < 0 || len(b) < pos+size {
+			return nil
+		}
+		if id == 0x0404 {
+			return b[pos : pos+size]
+		}
+
+		pos += size
+		if size%2 != 0 {
+			pos++
+		}
+		if pos > len(b) {
+			return nil
+		}
+		b = b[pos:]
+	}
+	return nil
+}
+
+// ParseIPTC decodes IPTC-IIM datasets. Unknown datasets and records other
+// than the application record (2) are ignored.
+func ParseIPTC(b []byte) *IPTC {
+	iptc := &IPTC{}
+	found := false
+
+	for len(b) >= 5 && b[0] == 0x1C {
+		record, dataset := b[1], b[2]
+		size := int(binary.BigEndian.Uint16(b[3:5]))
+		if size&0x8000 != 0 {
+			break // extended datasets are only used for binary data
+		}
+		if len(b) < 5+size {
+			break
+		}
+		value := iptcString(b[5 : 5+size])
+		b = b[5+size:]
+
+		if record != 2 || value == "" {
+			continue
+		}
+		found = true
+		switch dataset {
+		case 5:
+			iptc.Title = value
+		case 25:
+			iptc.Keywords = append(iptc.Keywords, value)
+		case 80:
+			iptc.Creator = value
+		case 90:
+			iptc.City = value
+		case 101:
+			iptc.Country = value
+		case 116:
+			iptc.Copyright = value
+		case 120:
+			iptc.Caption = value
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return iptc
+}
+
+// iptcString decodes a value as UTF-8, falling back to Latin-1, which is what
+// older tools write when no coded character set is declared
+func iptcString(b []byte) string {
+	b = bytes.TrimRight(b, "\x00")
+	if utf8.Valid(b) {
+		return strings.TrimSpace(string(b))
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return strings.TrimSpace(string(runes))
+}
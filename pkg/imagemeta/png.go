@@ -0,0 +1,148 @@
+package imagemeta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// maxPNGMetaChunk limits how much of an ancillary chunk is loaded in memory.
+const maxPNGMetaChunk = 16 << 20
+
+// textual chunk keywords that carry XMP or personal data
+var pngStrippedKeywords = map[string]bool{
+	"XML:com.adobe.xmp":      true,
+	"Author":                 true,
+	"Comment":                true,
+	"Raw profile type exif":  true,
+	"Raw profile type APP1":  true,
+	"Raw profile type iptc":  true,
+	"Raw profile type xmp":   true,
+	"Raw profile type 8bim":  true,
+	"Raw profile type APP13": true,
+}
+
+type pngChunk struct {
+	typ    string
+	length uint32
+	data   []byte // only loaded for metadata chunks
+}
+
+// eachPNGChunk calls fn for every chunk. body yields the chunk data followed
+// by its CRC. Data is only loaded into c.data for metadata chunks, so image
+// data is streamed.
+func eachPNGChunk(r *bufio.Reader, fn func(c pngChunk, body io.Reader) error) error {
+	if _, err := r.Discard(len(pngSignature)); err != nil {
+		return err
+	}
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		c := pngChunk{length: binary.BigEndian.Uint32(header[:4]), typ: string(header[4:])}
+		body := io.LimitReader(r, int64(c.length)+4)
+
+		if isPNGMetaChunk(c.typ) {
+			if c.length > maxPNGMetaChunk {
+				return fmt.Errorf("imagemeta: %s chunk too large", c.typ)
+			}
+			buf := make([]byte, c.length+4)
+			if _, err := io.ReadFull(body, buf); err != nil {
+				return err
+			}
+			c.data = buf[:c.length]
+			body = bytes.NewReader(buf)
+		}
+
+		if err := fn(c, body); err != nil {
+			return err
+		}
+		// skip whatever fn did not read
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return err
+		}
+		if c.typ == "IEND" {
+			return nil
+		}
+	}
+}
+
+func isPNGMetaChunk(typ string) bool {
+	return typ == "eXIf" || typ == "tEXt" || typ == "iTXt" || typ == "zTXt"
+}
+
+var errStopPNG = errors.New("stop")
+
+func readPNG(r *bufio.Reader) (*Blocks, error) {
+	blocks := &Blocks{}
+	err := eachPNGChunk(r, func(c pngChunk, _ io.Reader) error {
+		switch c.typ {
+		case "eXIf":
+			blocks.Exif = c.data
+		case "IDAT":
+			return errStopPNG
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopPNG) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func stripPNG(r *bufio.Reader, w io.Writer) error {
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+
+	return eachPNGChunk(r, func(c pngChunk, body io.Reader) error {
+		switch c.typ {
+		case "eXIf":
+			if err := blankTIFF(c.data); err != nil {
+				return nil // unreadable EXIF: drop the chunk
+			}
+			return writePNGChunk(w, c.typ, c.data)
+		case "tEXt", "iTXt", "zTXt":
+			keyword := c.data
+			if i := bytes.IndexByte(keyword, 0); i >= 0 {
+				keyword = keyword[:i]
+			}
+			if pngStrippedKeywords[string(keyword)] {
+				return nil
+			}
+		}
+
+		var header [8]byte
+		binary.BigEndian.PutUint32(header[:4], c.length)
+		copy(header[4:], c.typ)
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, crc.Sum32())
+}
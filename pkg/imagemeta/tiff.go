@@ -0,0 +1,164 @@
+package imagemeta
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errInvalidTIFF = errors.New("imagemeta: invalid tiff structure")
+
+const (
+	tagExifIFD = 0x8769
+	tagGPSIFD  = 0x8825
+)
+
+// personal tags in IFD0 / IFD1
+var personalIFDTags = map[uint16]bool{
+	0x013B: true, // Artist
+	0x013C: true, // HostComputer
+	0x9C9C: true, // XPComment
+	0x9C9D: true, // XPAuthor
+}
+
+// personal tags in the Exif sub-IFD
+var personalExifTags = map[uint16]bool{
+	0x927C: true, // MakerNote (often holds serial numbers and location)
+	0x9286: true, // UserComment
+	0xA420: true, // ImageUniqueID
+	0xA430: true, // CameraOwnerName
+	0xA431: true, // BodySerialNumber
+	0xA435: true, // LensSerialNumber
+}
+
+// byte size per TIFF field type
+var tiffTypeSize = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+type tiffBlanker struct {
+	b       []byte
+	order   binary.ByteOrder
+	visited map[uint32]bool
+}
+
+// blankTIFF zeroes personal values in an EXIF TIFF structure in place. The
+// GPS directory is emptied. Offsets never change, so the block keeps its size
+// and all other tags stay readable.
+func blankTIFF(b []byte) error {
+	if len(b) < 8 {
+		return errInvalidTIFF
+	}
+	t := &tiffBlanker{b: b, visited: make(map[uint32]bool)}
+	switch string(b[:4]) {
+	case "II*\x00":
+		t.order = binary.LittleEndian
+	case "MM\x00*":
+		t.order = binary.BigEndian
+	default:
+		return errInvalidTIFF
+	}
+
+	// IFD0, then the chain (IFD1 describes the embedded thumbnail)
+	offset := t.order.Uint32(b[4:8])
+	for offset != 0 {
+		next, err := t.walk(offset, personalIFDTags)
+		if err != nil {
+			return err
+		}
+		offset = next
+	}
+	return nil
+}
+
+// walk blanks personal tags in the IFD at offset and returns the next IFD offset
+func (t *tiffBlanker) walk(offset uint32, personal map[uint16]bool) (uint32, error) {
+	count, err := t.entryCount(offset)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		entry := offset + 2 + i*12
+		tag := t.order.Uint16(t.b[entry:])
+
+		switch {
+		case tag == tagExifIFD:
+			if _, err := t.walk(t.order.Uint32(t.b[entry+8:]), personalExifTags); err != nil {
+				return 0, err
+			}
+		case tag == tagGPSIFD:
+			if err := t.clear(t.order.Uint32(t.b[entry+8:])); err != nil {
+				return 0, err
+			}
+		case personal[tag]:
+			if err := t.blankValue(entry); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	end := offset + 2 + count*12
+	if uint64(end)+4 > uint64(len(t.b)) {
+		return 0, nil
+	}
+	return t.order.Uint32(t.b[end:]), nil
+}
+
+// clear zeroes every value in the IFD and then the IFD itself, which leaves
+// an empty directory (count 0, no next IFD)
+func (t *tiffBlanker) clear(offset uint32) error {
+	count, err := t.entryCount(offset)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < count; i++ {
+		if err := t.blankValue(offset + 2 + i*12); err != nil {
+			return err
+		}
+	}
+	zero(t.b[offset : offset+2+count*12])
+	return nil
+}
+
+func (t *tiffBlanker) entryCount(offset uint32) (uint32, error) {
+	if t.visited[offset] {
+		return 0, errInvalidTIFF // loop in the IFD chain
+	}
+	t.visited[offset] = true
+
+	if uint64(offset)+2 > uint64(len(t.b)) {
+		return 0, errInvalidTIFF
+	}
+	count := uint32(t.order.Uint16(t.b[offset:]))
+	if uint64(offset)+2+uint64(count)*12 > uint64(len(t.b)) {
+		return 0, errInvalidTIFF
+	}
+	return count, nil
+}
+
+// blankValue zeroes the value of the 12-byte entry at offset, wherever it is
+// stored: inline in the entry or out of line
+func (t *tiffBlanker) blankValue(entry uint32) error {
+	size, ok := tiffTypeSize[t.order.Uint16(t.b[entry+2:])]
+	if !ok {
+		return errInvalidTIFF
+	}
+	total := uint64(size) * uint64(t.order.Uint32(t.b[entry+4:]))
+
+	if total <= 4 {
+		zero(t.b[entry+8 : entry+12])
+		return nil
+	}
+	start := uint64(t.order.Uint32(t.b[entry+8:]))
+	if start+total > uint64(len(t.b)) {
+		return errInvalidTIFF
+	}
+	zero(t.b[start : start+total])
+	return nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
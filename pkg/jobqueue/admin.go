@@ -0,0 +1,108 @@
+package jobqueue
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ListOptions filter List. Empty strings match everything.
+type ListOptions struct {
+	Queue  string
+	Type   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// List returns matching jobs, newest first, and the total number of matches.
+func (q *Queue) List(opts ListOptions) ([]Job, int64, error) {
+	db := q.db.Model(&Job{})
+	if opts.Queue != "" {
+		db = db.Where("queue = ?", opts.Queue)
+	}
+	if opts.Type != "" {
+		db = db.Where("type = ?", opts.Type)
+	}
+	if opts.Status != "" {
+		db = db.Where("status = ?", opts.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 50
+	}
+	var jobs []Job
+	err := db.Order("id DESC").Limit(opts.Limit).Offset(opts.Offset).Find(&jobs).Error
+	return jobs, total, err
+}
+
+func (q *Queue) Find(id uint) (*Job, error) {
+	var job Job
+	if err := q.db.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Retry runs a job again right away with a fresh set of attempts. Running
+// jobs cannot be retried.
+func (q *Queue) Retry(id uint) (*Job, error) {
+	result := q.db.Model(&Job{}).
+		Where("id = ? AND status <> ?", id, StatusRunning).
+		Updates(map[string]interface{}{
+			"status":       StatusQueued,
+			"attempts":     0,
+			"run_at":       time.Now(),
+			"last_error":   "",
+			"completed_at": nil,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	job, err := q.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return job, ErrNotRetryable
+	}
+	q.notify(job.Queue)
+	return job, nil
+}
+
+// QueueStats counts the jobs of one queue per status.
+type QueueStats struct {
+	Queue  string           `json:"queue"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+func (q *Queue) Stats() ([]QueueStats, error) {
+	var rows []struct {
+		Queue  string
+		Status string
+		Count  int64
+	}
+	err := q.db.Model(&Job{}).Select("queue, status, COUNT(*) AS count").
+		Group("queue, status").Order("queue").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []QueueStats
+	for _, row := range rows {
+		if len(stats) == 0 || stats[len(stats)-1].Queue != row.Queue {
+			stats = append(stats, QueueStats{Queue: row.Queue, Counts: make(map[string]int64)})
+		}
+		stats[len(stats)-1].Counts[row.Status] = row.Count
+	}
+	return stats, nil
+}
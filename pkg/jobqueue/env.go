@@ -0,0 +1,26 @@
+package jobqueue
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ConcurrencyFromEnv overrides queue concurrency from an env variable of the
+// form "thumbnails=4,scan=2". Invalid entries are logged and skipped.
+func (q *Queue) ConcurrencyFromEnv(name string) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	for _, entry := range strings.Split(value, ",") {
+		queue, raw, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		n, err := strconv.Atoi(raw)
+		if !ok || queue == "" || err != nil || n <= 0 {
+			log.Printf("jobqueue: invalid %s entry %q", name, entry)
+			continue
+		}
+		q.SetConcurrency(queue, n)
+	}
+}
@@ -0,0 +1,116 @@
+package jobqueue
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Job states. A failed attempt puts the job back to queued with a later
+// RunAt until MaxAttempts is reached; then it becomes dead and stays until it
+// is retried by hand.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Job is one unit of work, stored in the jobs table.
+type Job struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Queue       string     `gorm:"not null;index:idx_jobs_claim,priority:1" json:"queue"`
+	Type        string     `gorm:"not null;index" json:"type"`
+	Payload     RawJSON    `gorm:"type:jsonb;not null" json:"payload"`
+	Status      string     `gorm:"not null;index:idx_jobs_claim,priority:2" json:"status"`
+	RunAt       time.Time  `gorm:"not null;index:idx_jobs_claim,priority:3" json:"run_at"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null" json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	UniqueKey   *string    `gorm:"uniqueIndex" json:"unique_key,omitempty"`
+	LockedBy    string     `json:"locked_by,omitempty"`
+	LockedAt    *time.Time `json:"locked_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// RawJSON is a JSON document stored in a jsonb column.
+type RawJSON []byte
+
+func (j RawJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+func (j *RawJSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = RawJSON(v)
+	case nil:
+		*j = nil
+	default:
+		return fmt.Errorf("jobqueue: cannot scan %T into RawJSON", value)
+	}
+	return nil
+}
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *RawJSON) UnmarshalJSON(b []byte) error {
+	*j = append((*j)[:0], b...)
+	return nil
+}
+
+var (
+	ErrNotFound     = errors.New("jobqueue: job not found")
+	ErrDuplicate    = errors.New("jobqueue: a job with this unique key already exists")
+	ErrUnknownType  = errors.New("jobqueue: no handler registered for job type")
+	ErrNotRetryable = errors.New("jobqueue: a running job cannot be retried")
+)
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying: the job goes straight to
+// dead.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type jobKey struct{}
+
+// FromContext returns the job being run, for handlers that need the attempt
+// number or job ID.
+func FromContext(ctx context.Context) (*Job, bool) {
+	job, ok := ctx.Value(jobKey{}).(*Job)
+	return job, ok
+}
+
+// LastAttempt reports whether a failure of the running job is final. Handlers
+// use it to record a failure only once no retry follows.
+func LastAttempt(ctx context.Context) bool {
+	job, ok := FromContext(ctx)
+	return !ok || job.Attempts >= job.MaxAttempts
+}
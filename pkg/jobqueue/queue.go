@@ -0,0 +1,442 @@
+package jobqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	mathrand "math/rand/v2"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultQueue       = "default"
+	defaultMaxAttempts = 5
+	defaultTimeout     = 10 * time.Minute
+
+	// how often an idle worker looks for due jobs
+	pollInterval = 2 * time.Second
+	// running jobs refresh LockedAt; a job whose lock is older than
+	// staleAfter belongs to a crashed worker and is queued again
+	heartbeatInterval = 30 * time.Second
+	staleAfter        = 5 * time.Minute
+	maintenanceEvery  = time.Minute
+	// succeeded jobs are kept this long for inspection
+	succeededRetention = 7 * 24 * time.Hour
+)
+
+// HandlerOptions configure how jobs of one type are run. Zero values get the
+// defaults: queue "default", 5 attempts, 10 minute timeout and exponential
+// backoff from 10 seconds up to 1 hour.
+type HandlerOptions struct {
+	Queue       string
+	MaxAttempts int
+	Timeout     time.Duration
+	Backoff     func(attempt int) time.Duration
+}
+
+type handler struct {
+	fn   func(ctx context.Context, payload []byte) error
+	opts HandlerOptions
+}
+
+// Queue stores jobs in PostgreSQL and runs them. Several processes can share
+// one table: jobs are claimed with SELECT ... FOR UPDATE SKIP LOCKED, so each
+// job runs on one worker at a time.
+type Queue struct {
+	db       *gorm.DB
+	workerID string
+
+	mu          sync.Mutex
+	handlers    map[string]handler
+	concurrency map[string]int
+	schedules   []schedule
+	wake        map[string]chan struct{}
+	started     bool
+}
+
+func New(db *gorm.DB) *Queue {
+	return &Queue{
+		db:          db,
+		workerID:    newWorkerID(),
+		handlers:    make(map[string]handler),
+		concurrency: make(map[string]int),
+		wake:        make(map[string]chan struct{}),
+	}
+}
+
+func newWorkerID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Type is a registered job type with payload T. It enqueues payloads of the
+// right type only.
+type Type[T any] struct {
+	queue *Queue
+	name  string
+}
+
+// Register adds the handler for a job type. It must be called before Start.
+// Payloads are JSON encoded, so T needs exported fields.
+func Register[T any](q *Queue, name string, opts HandlerOptions, fn func(ctx context.Context, payload T) error) Type[T] {
+	if opts.Queue == "" {
+		opts.Queue = DefaultQueue
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ExponentialBackoff(10*time.Second, time.Hour)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		panic("jobqueue: Register called after Start")
+	}
+	q.handlers[name] = handler{
+		opts: opts,
+		fn: func(ctx context.Context, raw []byte) error {
+			var payload T
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				return Permanent(fmt.Errorf("decode payload: %w", err))
+			}
+			return fn(ctx, payload)
+		},
+	}
+	if _, ok := q.wake[opts.Queue]; !ok {
+		q.wake[opts.Queue] = make(chan struct{}, 1)
+	}
+	return Type[T]{queue: q, name: name}
+}
+
+func (t Type[T]) Name() string { return t.name }
+
+// Enqueue stores a new job. With Unique, an existing job with the same key is
+// returned together with ErrDuplicate.
+func (t Type[T]) Enqueue(payload T, opts ...EnqueueOption) (*Job, error) {
+	return t.queue.enqueue(t.queue.db, t.name, payload, opts)
+}
+
+// EnqueueTx stores the job in tx, so it is only visible (and run) when tx
+// commits.
+func (t Type[T]) EnqueueTx(tx *gorm.DB, payload T, opts ...EnqueueOption) (*Job, error) {
+	return t.queue.enqueue(tx, t.name, payload, opts)
+}
+
+// Schedule enqueues payload on a cron schedule (see ParseSchedule). Every
+// process may declare the same schedule: each run is enqueued once.
+func (t Type[T]) Schedule(name, spec string, payload T) error {
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	q := t.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return fmt.Errorf("jobqueue: Schedule called after Start")
+	}
+	q.schedules = append(q.schedules, schedule{name: name, jobType: t.name, spec: sched, payload: raw})
+	return nil
+}
+
+type enqueueOptions struct {
+	runAt     time.Time
+	uniqueKey string
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+// At delays the job until t.
+func At(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// After delays the job by d.
+func After(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+// Unique prevents a second job with the same key. Keys stay taken until the
+// job is cleaned up (succeeded jobs after 7 days; dead jobs never).
+func Unique(key string) EnqueueOption {
+	return func(o *enqueueOptions) { o.uniqueKey = key }
+}
+
+func (q *Queue) enqueue(db *gorm.DB, jobType string, payload any, opts []EnqueueOption) (*Job, error) {
+	q.mu.Lock()
+	h, ok := q.handlers[jobType]
+	q.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, jobType)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("jobqueue: encode payload: %w", err)
+	}
+	o := enqueueOptions{runAt: time.Now()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	job := &Job{
+		Queue:       h.opts.Queue,
+		Type:        jobType,
+		Payload:     raw,
+		Status:      StatusQueued,
+		RunAt:       o.runAt,
+		MaxAttempts: h.opts.MaxAttempts,
+	}
+	if o.uniqueKey == "" {
+		if err := db.Create(job).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		job.UniqueKey = &o.uniqueKey
+		result := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "unique_key"}}, DoNothing: true}).Create(job)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			var existing Job
+			if err := db.Where("unique_key = ?", o.uniqueKey).First(&existing).Error; err != nil {
+				return nil, err
+			}
+			return &existing, ErrDuplicate
+		}
+	}
+
+	if !job.RunAt.After(time.Now()) {
+		q.notify(job.Queue)
+	}
+	return job, nil
+}
+
+// notify wakes an idle worker of this process; workers of other processes
+// pick the job up on their next poll
+func (q *Queue) notify(queue string) {
+	q.mu.Lock()
+	ch := q.wake[queue]
+	q.mu.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// SetConcurrency sets the number of workers for a queue (default 1).
+func (q *Queue) SetConcurrency(queue string, n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.concurrency[queue] = n
+}
+
+// Start runs the workers of every queue that has handlers, the schedules and
+// the maintenance loop.
+func (q *Queue) Start() {
+	q.mu.Lock()
+	q.started = true
+	workers := make(map[string]int)
+	for _, h := range q.handlers {
+		n := q.concurrency[h.opts.Queue]
+		if n <= 0 {
+			n = 1
+		}
+		workers[h.opts.Queue] = n
+	}
+	schedules := q.schedules
+	q.mu.Unlock()
+
+	for queue, n := range workers {
+		for i := 0; i < n; i++ {
+			go q.work(queue)
+		}
+	}
+	for _, s := range schedules {
+		go q.runSchedule(s)
+	}
+	go q.maintain()
+}
+
+func (q *Queue) work(queue string) {
+	for {
+		job, err := q.claim(queue)
+		if err != nil {
+			log.Printf("jobqueue: claim from %s: %v", queue, err)
+		}
+		if job == nil {
+			select {
+			case <-q.wake[queue]:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		q.run(job)
+	}
+}
+
+func (q *Queue) claim(queue string) (*Job, error) {
+	now := time.Now()
+	var job Job
+	err := q.db.Raw(`UPDATE jobs
+		SET status = ?, attempts = attempts + 1, locked_by = ?, locked_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE queue = ? AND status = ? AND run_at <= ?
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		StatusRunning, q.workerID, now, now, queue, StatusQueued, now).Scan(&job).Error
+	if err != nil || job.ID == 0 {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *Queue) run(job *Job) {
+	q.mu.Lock()
+	h, ok := q.handlers[job.Type]
+	q.mu.Unlock()
+	if !ok {
+		q.fail(job, Permanent(fmt.Errorf("%w: %s", ErrUnknownType, job.Type)), 0)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), jobKey{}, job), h.opts.Timeout)
+	defer cancel()
+
+	stop := make(chan struct{})
+	go q.heartbeat(job.ID, stop)
+	err := call(ctx, h.fn, job.Payload)
+	close(stop)
+
+	if err != nil {
+		log.Printf("jobqueue: %s job %d attempt %d/%d failed: %v", job.Type, job.ID, job.Attempts, job.MaxAttempts, err)
+		q.fail(job, err, h.opts.Backoff(job.Attempts))
+		return
+	}
+	now := time.Now()
+	q.finish(job, map[string]interface{}{
+		"status":       StatusSucceeded,
+		"last_error":   "",
+		"completed_at": now,
+	})
+}
+
+// call runs the handler, turning a panic into a permanent error
+func call(ctx context.Context, fn func(context.Context, []byte) error, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v\n%s", r, debug.Stack()))
+		}
+	}()
+	return fn(ctx, payload)
+}
+
+func (q *Queue) fail(job *Job, err error, backoff time.Duration) {
+	now := time.Now()
+	if isPermanent(err) || job.Attempts >= job.MaxAttempts {
+		q.finish(job, map[string]interface{}{
+			"status":       StatusDead,
+			"last_error":   err.Error(),
+			"completed_at": now,
+		})
+		return
+	}
+	q.finish(job, map[string]interface{}{
+		"status":     StatusQueued,
+		"last_error": err.Error(),
+		"run_at":     now.Add(backoff),
+	})
+}
+
+// finish releases the lock. The locked_by check skips the update if the job
+// was taken over after this worker was presumed dead.
+func (q *Queue) finish(job *Job, updates map[string]interface{}) {
+	updates["locked_by"] = ""
+	updates["locked_at"] = nil
+	err := q.db.Model(&Job{}).Where("id = ? AND locked_by = ?", job.ID, q.workerID).Updates(updates).Error
+	if err != nil {
+		log.Printf("jobqueue: failed to update job %d: %v", job.ID, err)
+	}
+}
+
+func (q *Queue) heartbeat(jobID uint, stop <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := q.db.Model(&Job{}).Where("id = ? AND locked_by = ?", jobID, q.workerID).
+				Update("locked_at", time.Now()).Error
+			if err != nil {
+				log.Printf("jobqueue: heartbeat for job %d: %v", jobID, err)
+			}
+		}
+	}
+}
+
+// maintain requeues jobs of crashed workers and removes old succeeded jobs
+func (q *Queue) maintain() {
+	for {
+		now := time.Now()
+		err := q.db.Exec(`UPDATE jobs
+			SET status = CASE WHEN attempts >= max_attempts THEN ? ELSE ? END,
+				completed_at = CASE WHEN attempts >= max_attempts THEN ?::timestamptz ELSE NULL END,
+				last_error = ?, run_at = ?, locked_by = '', locked_at = NULL, updated_at = ?
+			WHERE status = ? AND locked_at < ?`,
+			StatusDead, StatusQueued, now, "worker stopped responding", now, now,
+			StatusRunning, now.Add(-staleAfter)).Error
+		if err != nil {
+			log.Printf("jobqueue: failed to requeue stale jobs: %v", err)
+		}
+
+		err = q.db.Where("status = ? AND completed_at < ?", StatusSucceeded, now.Add(-succeededRetention)).
+			Delete(&Job{}).Error
+		if err != nil {
+			log.Printf("jobqueue: failed to clean up jobs: %v", err)
+		}
+		time.Sleep(maintenanceEvery)
+	}
+}
+
+// ExponentialBackoff doubles the delay after every attempt, from base up to
+// max, with up to 20% jitter so failed jobs do not retry in lockstep.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := float64(base) * math.Pow(2, float64(attempt-1))
+		if d > float64(max) {
+			d = float64(max)
+		}
+		return time.Duration(d * (1 + 0.2*mathrand.Float64()))
+	}
+}
@@ -0,0 +1,389 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The queue relies on Postgres (SKIP LOCKED, ON CONFLICT, jsonb), so these
+// tests need a real database. Set JOBQUEUE_TEST_DATABASE_URL to run them;
+// every test gets its own schema, which is dropped afterwards.
+const testDSNEnv = "JOBQUEUE_TEST_DATABASE_URL"
+
+type testPayload struct {
+	N int `json:"n"`
+}
+
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", testDSNEnv)
+	}
+
+	schema := fmt.Sprintf("jobqueue_test_%d", time.Now().UnixNano())
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+	config.RuntimeParams["search_path"] = schema
+	sqlDB := stdlib.OpenDB(*config)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := db.AutoMigrate(&Job{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	t.Cleanup(func() {
+		sqlDB.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if raw, err := admin.DB(); err == nil {
+			raw.Close()
+		}
+	})
+	return db
+}
+
+// runNext claims and runs the next due job of queue, as a worker would
+func runNext(t *testing.T, q *Queue, queue string) *Job {
+	t.Helper()
+	job, err := q.claim(queue)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if job == nil {
+		t.Fatal("claim found no job")
+	}
+	q.run(job)
+	return reload(t, q, job.ID)
+}
+
+func reload(t *testing.T, q *Queue, id uint) *Job {
+	t.Helper()
+	job, err := q.Find(id)
+	if err != nil {
+		t.Fatalf("Find(%d): %v", id, err)
+	}
+	return job
+}
+
+// makeDue moves a backed-off job's run_at to now
+func makeDue(t *testing.T, q *Queue, id uint) {
+	t.Helper()
+	if err := q.db.Model(&Job{}).Where("id = ?", id).Update("run_at", time.Now()).Error; err != nil {
+		t.Fatalf("update run_at: %v", err)
+	}
+}
+
+func fixedBackoff(d time.Duration) func(int) time.Duration {
+	return func(int) time.Duration { return d }
+}
+
+func TestClaimOneWorkerPerJob(t *testing.T) {
+	db := testDB(t)
+
+	// several processes share the table; each gets its own worker ID
+	queues := make([]*Queue, 8)
+	var jobType Type[testPayload]
+	for i := range queues {
+		queues[i] = New(db)
+		jobType = Register(queues[i], "work", HandlerOptions{}, func(context.Context, testPayload) error { return nil })
+	}
+	const jobs = 20
+	for i := 0; i < jobs; i++ {
+		if _, err := jobType.Enqueue(testPayload{N: i}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = make(map[uint]string)
+		wg      sync.WaitGroup
+	)
+	for _, q := range queues {
+		wg.Add(1)
+		go func(q *Queue) {
+			defer wg.Done()
+			for {
+				job, err := q.claim(DefaultQueue)
+				if err != nil {
+					t.Errorf("claim: %v", err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				if other, ok := claimed[job.ID]; ok {
+					t.Errorf("job %d claimed by %s and %s", job.ID, other, q.workerID)
+				}
+				claimed[job.ID] = q.workerID
+				mu.Unlock()
+			}
+		}(q)
+	}
+	wg.Wait()
+
+	if len(claimed) != jobs {
+		t.Fatalf("claimed %d jobs, want %d", len(claimed), jobs)
+	}
+	for id, worker := range claimed {
+		job := reload(t, queues[0], id)
+		if job.Status != StatusRunning || job.Attempts != 1 || job.LockedBy != worker || job.LockedAt == nil {
+			t.Errorf("job %d = status %s attempts %d locked by %q, want running, 1, %q", id, job.Status, job.Attempts, job.LockedBy, worker)
+		}
+	}
+}
+
+func TestClaimSkipsFutureAndOtherQueues(t *testing.T) {
+	q := New(testDB(t))
+	later := Register(q, "later", HandlerOptions{}, func(context.Context, testPayload) error { return nil })
+	other := Register(q, "other", HandlerOptions{Queue: "other"}, func(context.Context, testPayload) error { return nil })
+
+	if _, err := later.Enqueue(testPayload{}, After(time.Hour)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if _, err := other.Enqueue(testPayload{}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	job, err := q.claim(DefaultQueue)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if job != nil {
+		t.Fatalf("claimed %s job %d, want none due on the default queue", job.Type, job.ID)
+	}
+	job, err = q.claim("other")
+	if err != nil || job == nil || job.Type != "other" {
+		t.Fatalf("claim(other) = %+v, %v, want the other job", job, err)
+	}
+}
+
+func TestSuccess(t *testing.T) {
+	q := New(testDB(t))
+	var got testPayload
+	jobType := Register(q, "ok", HandlerOptions{}, func(_ context.Context, p testPayload) error {
+		got = p
+		return nil
+	})
+	if _, err := jobType.Enqueue(testPayload{N: 42}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	job := runNext(t, q, DefaultQueue)
+	if got.N != 42 {
+		t.Fatalf("handler got %+v, want N=42", got)
+	}
+	if job.Status != StatusSucceeded || job.CompletedAt == nil || job.LockedBy != "" || job.LockedAt != nil {
+		t.Fatalf("job = %+v, want succeeded, completed and unlocked", job)
+	}
+}
+
+func TestRetryBackoffThenDead(t *testing.T) {
+	q := New(testDB(t))
+	const backoff = time.Minute
+	var lastAttempt []bool
+	jobType := Register(q, "flaky", HandlerOptions{MaxAttempts: 3, Backoff: fixedBackoff(backoff)}, func(ctx context.Context, _ testPayload) error {
+		lastAttempt = append(lastAttempt, LastAttempt(ctx))
+		return errors.New("boom")
+	})
+	enqueued, err := jobType.Enqueue(testPayload{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		start := time.Now()
+		job := runNext(t, q, DefaultQueue)
+		if job.Status != StatusQueued || job.Attempts != attempt || job.LastError != "boom" {
+			t.Fatalf("after attempt %d: status %s attempts %d error %q, want queued, %d, boom", attempt, job.Status, job.Attempts, job.LastError, attempt)
+		}
+		if job.RunAt.Before(start.Add(backoff-time.Second)) || job.RunAt.After(time.Now().Add(backoff+time.Second)) {
+			t.Fatalf("after attempt %d: run_at %v, want about %v from now", attempt, job.RunAt, backoff)
+		}
+		if next, err := q.claim(DefaultQueue); err != nil || next != nil {
+			t.Fatalf("claimed a job during its backoff: %+v, %v", next, err)
+		}
+		makeDue(t, q, enqueued.ID)
+	}
+
+	job := runNext(t, q, DefaultQueue)
+	if job.Status != StatusDead || job.Attempts != 3 || job.CompletedAt == nil {
+		t.Fatalf("after last attempt: status %s attempts %d, want dead after 3", job.Status, job.Attempts)
+	}
+	if want := []bool{false, false, true}; fmt.Sprint(lastAttempt) != fmt.Sprint(want) {
+		t.Fatalf("LastAttempt per attempt = %v, want %v", lastAttempt, want)
+	}
+}
+
+func TestPermanentFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(context.Context, testPayload) error
+	}{
+		{name: "permanent error", fn: func(context.Context, testPayload) error { return Permanent(errors.New("bad input")) }},
+		{name: "panic", fn: func(context.Context, testPayload) error { panic("handler bug") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(testDB(t))
+			jobType := Register(q, "fail", HandlerOptions{MaxAttempts: 5}, tt.fn)
+			if _, err := jobType.Enqueue(testPayload{}); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			job := runNext(t, q, DefaultQueue)
+			if job.Status != StatusDead || job.Attempts != 1 || job.LastError == "" {
+				t.Fatalf("job = status %s attempts %d error %q, want dead after 1 attempt", job.Status, job.Attempts, job.LastError)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	q := New(testDB(t))
+	jobType := Register(q, "slow", HandlerOptions{MaxAttempts: 1, Timeout: 50 * time.Millisecond}, func(ctx context.Context, _ testPayload) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if _, err := jobType.Enqueue(testPayload{}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	job := runNext(t, q, DefaultQueue)
+	if job.Status != StatusDead || job.LastError != context.DeadlineExceeded.Error() {
+		t.Fatalf("job = status %s error %q, want dead with deadline exceeded", job.Status, job.LastError)
+	}
+}
+
+func TestUniqueKey(t *testing.T) {
+	q := New(testDB(t))
+	jobType := Register(q, "unique", HandlerOptions{MaxAttempts: 1}, func(context.Context, testPayload) error {
+		return errors.New("boom")
+	})
+
+	first, err := jobType.Enqueue(testPayload{N: 1}, Unique("file:1"))
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	dup, err := jobType.Enqueue(testPayload{N: 2}, Unique("file:1"))
+	if !errors.Is(err, ErrDuplicate) || dup == nil || dup.ID != first.ID {
+		t.Fatalf("second Enqueue = %+v, %v, want job %d and ErrDuplicate", dup, err, first.ID)
+	}
+	if _, err := jobType.Enqueue(testPayload{N: 3}, Unique("file:2")); err != nil {
+		t.Fatalf("Enqueue with another key: %v", err)
+	}
+
+	// the key stays taken after the job is dead
+	var job *Job
+	for job == nil || job.ID != first.ID {
+		job = runNext(t, q, DefaultQueue)
+	}
+	if job.Status != StatusDead {
+		t.Fatalf("job status = %s, want dead", job.Status)
+	}
+	dup, err = jobType.Enqueue(testPayload{}, Unique("file:1"))
+	if !errors.Is(err, ErrDuplicate) || dup.Status != StatusDead {
+		t.Fatalf("Enqueue after dead = %+v, %v, want the dead job and ErrDuplicate", dup, err)
+	}
+}
+
+func TestManualRetry(t *testing.T) {
+	q := New(testDB(t))
+	fail := true
+	jobType := Register(q, "retry", HandlerOptions{MaxAttempts: 1}, func(context.Context, testPayload) error {
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	enqueued, err := jobType.Enqueue(testPayload{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if job := runNext(t, q, DefaultQueue); job.Status != StatusDead {
+		t.Fatalf("job status = %s, want dead", job.Status)
+	}
+
+	retried, err := q.Retry(enqueued.ID)
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if retried.Status != StatusQueued || retried.Attempts != 0 || retried.LastError != "" || retried.CompletedAt != nil {
+		t.Fatalf("retried job = %+v, want queued with fresh attempts", retried)
+	}
+
+	fail = false
+	if job := runNext(t, q, DefaultQueue); job.Status != StatusSucceeded || job.Attempts != 1 {
+		t.Fatalf("after retry: status %s attempts %d, want succeeded after 1", job.Status, job.Attempts)
+	}
+}
+
+func TestRetryRunningJob(t *testing.T) {
+	q := New(testDB(t))
+	jobType := Register(q, "busy", HandlerOptions{}, func(context.Context, testPayload) error { return nil })
+	enqueued, err := jobType.Enqueue(testPayload{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if _, err := q.claim(DefaultQueue); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := q.Retry(enqueued.ID); !errors.Is(err, ErrNotRetryable) {
+		t.Fatalf("Retry of a running job = %v, want ErrNotRetryable", err)
+	}
+	if _, err := q.Retry(enqueued.ID + 1000); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Retry of a missing job = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEnqueueUnknownType(t *testing.T) {
+	q := New(testDB(t))
+	unregistered := Type[testPayload]{queue: q, name: "nobody"}
+	if _, err := unregistered.Enqueue(testPayload{}); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("Enqueue = %v, want ErrUnknownType", err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Second, time.Hour)
+	tests := []struct {
+		attempt int
+		min     time.Duration
+	}{
+		{attempt: 1, min: 10 * time.Second},
+		{attempt: 2, min: 20 * time.Second},
+		{attempt: 3, min: 40 * time.Second},
+		{attempt: 10, min: time.Hour},
+		{attempt: 50, min: time.Hour},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := backoff(tt.attempt)
+			if max := tt.min + tt.min/5; got < tt.min || got > max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.min, max)
+			}
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "vasvault"
+	testKid      = "key-1"
+)
+
+// mockIssuer is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that enforces PKCE for codes issued through authorize.
+type mockIssuer struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	// discoveryIssuer overrides the issuer in the discovery document
+	discoveryIssuer string
+	// claims are merged over the default ID token claims
+	claims jwt.MapClaims
+	// signKey signs ID tokens instead of key when set
+	signKey *rsa.PrivateKey
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> S256 challenge
+	nonces     map[string]string // code -> nonce
+}
+
+var (
+	keyOnce sync.Once
+	keyA    *rsa.PrivateKey
+	keyB    *rsa.PrivateKey
+)
+
+func testKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PrivateKey) {
+	keyOnce.Do(func() {
+		var err error
+		if keyA, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+		if keyB, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return keyA, keyB
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, _ := testKeys(t)
+	m := &mockIssuer{
+		t:          t,
+		key:        key,
+		challenges: make(map[string]string),
+		nonces:     make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.srv.URL
+		if m.discoveryIssuer != "" {
+			issuer = m.discoveryIssuer
+		}
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                issuer,
+			AuthorizationEndpoint: m.srv.URL + "/authorize",
+			TokenEndpoint:         m.srv.URL + "/token",
+			JWKSURI:               m.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		pub := m.key.PublicKey
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+func (m *mockIssuer) provider() *Provider {
+	return NewProvider(Config{
+		Issuer:      m.srv.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://app.test/api/v1/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	})
+}
+
+// authorize plays the browser and the login page: it reads the
+// authorization request and returns a code bound to its PKCE challenge.
+func (m *mockIssuer) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID {
+		m.t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+	code := "code-" + q.Get("state")
+	m.mu.Lock()
+	m.challenges[code] = q.Get("code_challenge")
+	m.nonces[code] = q.Get("nonce")
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	code := r.PostForm.Get("code")
+	m.mu.Lock()
+	challenge, ok := m.challenges[code]
+	nonce := m.nonces[code]
+	delete(m.challenges, code)
+	m.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		http.Error(w, `{"error":"invalid_grant","error_description":"PKCE verification failed"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.srv.URL,
+		"sub":            "user-123",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": "true",
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	signKey := m.key
+	if m.signKey != nil {
+		signKey = m.signKey
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = testKid
+	idToken, err := tok.SignedString(signKey)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	json.NewEncoder(w).Encode(TokenResponse{AccessToken: "at", TokenType: "Bearer", IDToken: idToken, ExpiresIn: 300})
+}
+
+// login runs the full flow and returns the verified claims. verifier
+// replaces the PKCE verifier and nonce the expected nonce when not empty.
+func login(t *testing.T, m *mockIssuer, verifier, nonce string) (*IDTokenClaims, error) {
+	t.Helper()
+	ctx := context.Background()
+	p := m.provider()
+
+	realVerifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	realNonce, _ := RandomString()
+	state, _ := RandomString()
+	authURL, err := p.AuthCodeURL(ctx, state, realNonce, challenge)
+	if err != nil {
+		return nil, err
+	}
+	code := m.authorize(authURL)
+
+	if verifier == "" {
+		verifier = realVerifier
+	}
+	if nonce == "" {
+		nonce = realNonce
+	}
+	tokens, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, err
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+func TestLoginFlow(t *testing.T) {
+	m := newMockIssuer(t)
+	claims, err := login(t, m, "", "")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "alice@example.com" || !bool(claims.EmailVerified) {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestLoginFailures(t *testing.T) {
+	_, otherKey := testKeys(t)
+
+	tests := []struct {
+		name     string
+		setup    func(m *mockIssuer)
+		verifier string
+		nonce    string
+		wantErr  string
+	}{
+		{
+			name:     "wrong PKCE verifier",
+			verifier: "not-the-verifier",
+			wantErr:  "PKCE verification failed",
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "another-nonce",
+			wantErr: "nonce mismatch",
+		},
+		{
+			name:    "wrong audience",
+			setup:   func(m *mockIssuer) { m.claims = jwt.MapClaims{"aud": "someone-else"} },
+			wantErr: "audience",
+		},
+		{
+			name: "multiple audiences without azp",
+			setup: func(m *mockIssuer) {
+				m.claims = jwt.MapClaims{"aud": []string{testClientID, "other"}}
+			},
+			wantErr: "authorized party mismatch",
+		},
+		{
+			name: "multiple audiences with foreign azp",
+			setup: func(m *mockIssuer) {
+				m.claims = jwt.MapClaims{"aud": []string{testClientID, "other"}, "azp": "other"}
+			},
+			wantErr: "authorized party mismatch",
+		},
+		{
+			name:    "wrong issuer",
+			setup:   func(m *mockIssuer) { m.claims = jwt.MapClaims{"iss": "https://evil.example"} },
+			wantErr: "issuer",
+		},
+		{
+			name: "expired",
+			setup: func(m *mockIssuer) {
+				m.claims = jwt.MapClaims{"exp": time.Now().Add(-2 * clockSkew).Unix()}
+			},
+			wantErr: "expired",
+		},
+		{
+			name:    "missing expiry",
+			setup:   func(m *mockIssuer) { m.claims = jwt.MapClaims{"exp": nil} },
+			wantErr: "exp",
+		},
+		{
+			name:    "signed with unknown key",
+			setup:   func(m *mockIssuer) { m.signKey = otherKey },
+			wantErr: "signature",
+		},
+		{
+			name:    "discovery issuer mismatch",
+			setup:   func(m *mockIssuer) { m.discoveryIssuer = "https://evil.example" },
+			wantErr: "issuer mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			_, err := login(t, m, tt.verifier, tt.nonce)
+			if err == nil {
+				t.Fatal("login succeeded, want error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMultipleAudiencesWithOwnAZP(t *testing.T) {
+	m := newMockIssuer(t)
+	m.claims = jwt.MapClaims{"aud": []string{testClientID, "other"}, "azp": testClientID}
+	if _, err := login(t, m, "", ""); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+}
+
+func TestCodeCannotBeReused(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+	p := m.provider()
+
+	verifier, challenge, _ := NewPKCE()
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := m.authorize(authURL)
+	if _, err := p.Exchange(ctx, code, verifier); err != nil {
+		t.Fatalf("first exchange failed: %v", err)
+	}
+	if _, err := p.Exchange(ctx, code, verifier); err == nil {
+		t.Fatal("second exchange succeeded, want invalid_grant")
+	}
+}
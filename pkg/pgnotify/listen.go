@@ -0,0 +1,103 @@
+// Package pgnotify receives PostgreSQL NOTIFY messages over a database/sql
+// pool that uses the pgx driver.
+package pgnotify
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Handler receives the events of a Listen loop. Both functions are called
+// from the listening goroutine, one at a time.
+type Handler struct {
+	// Connected is called after every successful LISTEN, including
+	// reconnects. Notifications sent while the connection was down are lost,
+	// so this is the place to catch up from the source table.
+	Connected func()
+	// Notify is called with the payload of each notification.
+	Notify func(payload string)
+}
+
+// Listen takes one connection out of db, LISTENs on channel and calls h for
+// every notification until ctx is cancelled. Connection errors are logged
+// and retried with backoff. The connection is discarded afterwards instead
+// of going back to the pool, since it is still subscribed.
+func Listen(ctx context.Context, db *sql.DB, channel string, h Handler) {
+	backoff := minBackoff
+	for {
+		start := time.Now()
+		err := listenOnce(ctx, db, channel, h)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff
+		}
+		log.Printf("pgnotify: listener on %q stopped: %v, reconnecting in %s", channel, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func listenOnce(ctx context.Context, db *sql.DB, channel string, h Handler) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var listenErr error
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			listenErr = fmt.Errorf("pgnotify: driver connection is %T, not pgx", driverConn)
+			return driver.ErrBadConn
+		}
+		listenErr = wait(ctx, c.Conn(), channel, h)
+		// never reuse a connection that is still LISTENing
+		return driver.ErrBadConn
+	})
+	if listenErr != nil {
+		return listenErr
+	}
+	if err != nil && !errors.Is(err, driver.ErrBadConn) {
+		return err
+	}
+	return nil
+}
+
+func wait(ctx context.Context, conn *pgx.Conn, channel string, h Handler) error {
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	if h.Connected != nil {
+		h.Connected()
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if h.Notify != nil {
+			h.Notify(n.Payload)
+		}
+	}
+}
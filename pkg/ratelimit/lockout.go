@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"strings"
+	"time"
+)
+
+// Lockout blocks an account after Threshold consecutive failures. Every
+// further failure doubles the block duration, starting at Base and capped at
+// Max. Failures are forgotten after Window without another failure.
+type Lockout struct {
+	store     Store
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+func NewLockout(store Store) *Lockout {
+	return &Lockout{
+		store:     store,
+		Threshold: 5,
+		Base:      30 * time.Second,
+		Max:       time.Hour,
+		Window:    24 * time.Hour,
+	}
+}
+
+// LockedFor returns how long the account stays locked, 0 if it is not.
+func (l *Lockout) LockedFor(account string) (time.Duration, error) {
+	return l.store.BlockedFor(l.lockKey(account))
+}
+
+// Fail records a failed attempt and returns the lock duration if the account
+// is now locked.
+func (l *Lockout) Fail(account string) (time.Duration, error) {
+	failures, err := l.store.Increment(l.failKey(account), l.Window)
+	if err != nil {
+		return 0, err
+	}
+	if failures < int64(l.Threshold) {
+		return 0, nil
+	}
+
+	d := l.Base
+	for i := int64(l.Threshold); i < failures && d < l.Max; i++ {
+		d *= 2
+	}
+	if d > l.Max {
+		d = l.Max
+	}
+
+	if err := l.store.Block(l.lockKey(account), d); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+func (l *Lockout) Succeed(account string) error {
+	return l.store.Reset(l.failKey(account), l.lockKey(account))
+}
+
+func (l *Lockout) failKey(account string) string {
+	return "lockout:fail:" + strings.ToLower(account)
+}
+
+func (l *Lockout) lockKey(account string) string {
+	return "lockout:lock:" + strings.ToLower(account)
+}
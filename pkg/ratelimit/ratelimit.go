@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second up to
+// Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Per returns a limit of n requests per period, allowing all n in a burst.
+func Per(n int, period time.Duration) Limit {
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+}
+
+// ParseLimit parses values like "10/m", "100/h" or "5/s".
+func ParseLimit(value string) (Limit, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+
+	var period time.Duration
+	switch strings.ToLower(parts[1]) {
+	case "s", "sec", "second":
+		period = time.Second
+	case "m", "min", "minute":
+		period = time.Minute
+	case "h", "hour":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit period %q", parts[1])
+	}
+
+	return Per(n, period), nil
+}
+
+// LimitFromEnv reads a limit from the given env variable, falling back to def
+// when it is unset or invalid.
+func LimitFromEnv(name string, def Limit) Limit {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	limit, err := ParseLimit(value)
+	if err != nil {
+		return def
+	}
+	return limit
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // only set when not allowed
+	ResetAfter time.Duration // time until the bucket is full again
+}
+
+// Store keeps rate limit buckets and lockout counters. Implementations must
+// be safe for concurrent use.
+type Store interface {
+	Allow(key string, limit Limit) (Result, error)
+
+	Increment(key string, ttl time.Duration) (int64, error)
+	Block(key string, d time.Duration) error
+	BlockedFor(key string) (time.Duration, error)
+	Reset(keys ...string) error
+}
+
+// NewStoreFromEnv returns a redis store when RATE_LIMIT_STORE=redis, otherwise
+// an in-memory store.
+func NewStoreFromEnv() (Store, error) {
+	switch strings.ToLower(os.Getenv("RATE_LIMIT_STORE")) {
+	case "redis":
+		addr := os.Getenv("REDIS_ADDR")
+		if addr == "" {
+			addr = "localhost:6379"
+		}
+		db, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+		return NewRedisStore(addr, os.Getenv("REDIS_PASSWORD"), db), nil
+	case "", "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", os.Getenv("RATE_LIMIT_STORE"))
+	}
+}
+
+// bucketResult describes a bucket left with tokens after a take attempt.
+func bucketResult(allowed bool, tokens float64, limit Limit) Result {
+	res := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(tokens),
+		ResetAfter: time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	return res
+}
+
+// HeaderSeconds formats d for Retry-After style headers, rounding up so
+// clients never retry too early.
+func HeaderSeconds(d time.Duration) string {
+	secs := int64(d / time.Second)
+	if d%time.Second != 0 {
+		secs++
+	}
+	return strconv.FormatInt(secs, 10)
+}
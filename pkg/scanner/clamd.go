@@ -0,0 +1,175 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 2 * time.Minute
+
+	// clamd reads INSTREAM data in chunks; each is prefixed with its length
+	chunkSize = 64 << 10
+)
+
+// ErrSizeLimit is returned when the stream is larger than clamd's
+// StreamMaxLength.
+var ErrSizeLimit = errors.New("scanner: stream exceeds clamd size limit")
+
+// Clamd talks to a ClamAV daemon using the INSTREAM command, so the daemon
+// does not need access to the files on disk.
+type Clamd struct {
+	Network string // tcp or unix
+	Address string
+	Timeout time.Duration
+}
+
+// NewClamd parses an address of the form tcp://host:port, unix:///path or
+// plain host:port.
+func NewClamd(address string) (*Clamd, error) {
+	c := &Clamd{Network: "tcp", Address: address, Timeout: defaultTimeout}
+	switch {
+	case strings.HasPrefix(address, "tcp://"):
+		c.Address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "unix://"):
+		c.Network = "unix"
+		c.Address = strings.TrimPrefix(address, "unix://")
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("scanner: unsupported clamd address %q", address)
+	}
+	if c.Address == "" {
+		return nil, fmt.Errorf("scanner: empty clamd address")
+	}
+	return c, nil
+}
+
+// Ping checks that the daemon is reachable.
+func (c *Clamd) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("scanner: unexpected clamd reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams r to clamd and parses the verdict.
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	reply, err := c.command(ctx, "INSTREAM", r)
+	if err != nil {
+		return nil, err
+	}
+	return parseReply(reply)
+}
+
+// command sends a null-terminated command ("z" prefix), followed by the
+// chunked stream if body is not nil, and returns the single-line reply.
+func (c *Clamd) command(ctx context.Context, name string, body io.Reader) (string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return "", fmt.Errorf("scanner: connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// cancellation unblocks pending reads and writes
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if _, err := conn.Write([]byte("z" + name + "\x00")); err != nil {
+		return "", fmt.Errorf("scanner: send command: %w", err)
+	}
+
+	if body != nil {
+		if err := writeChunks(conn, body); err != nil {
+			// clamd closes the connection after replying when the stream is
+			// over its limit, so a reply may still be waiting
+			if reply, rerr := readReply(conn); rerr == nil && reply != "" {
+				return reply, nil
+			}
+			if ctx.Err() != nil {
+				return "", fmt.Errorf("scanner: %w", ctx.Err())
+			}
+			return "", fmt.Errorf("scanner: send stream: %w", err)
+		}
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("scanner: %w", ctx.Err())
+		}
+		return "", fmt.Errorf("scanner: read reply: %w", err)
+	}
+	return reply, nil
+}
+
+func writeChunks(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// zero-length chunk ends the stream
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && (err != io.EOF || len(reply) == 0) {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseReply understands the INSTREAM replies:
+//
+//	stream: OK
+//	stream: Win.Test.EICAR_HDB-1 FOUND
+//	INSTREAM size limit exceeded. ERROR
+func parseReply(reply string) (*Result, error) {
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return &Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if i := strings.Index(signature, ": "); i >= 0 {
+			signature = signature[i+2:]
+		}
+		return &Result{Infected: true, Signature: signature}, nil
+	case strings.Contains(reply, "size limit exceeded"):
+		return nil, ErrSizeLimit
+	default:
+		return nil, fmt.Errorf("scanner: clamd error: %s", reply)
+	}
+}
@@ -0,0 +1,327 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startFake(t *testing.T) *FakeClamd {
+	t.Helper()
+	fake, err := ListenFakeClamd("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { fake.Close() })
+	return fake
+}
+
+func newTestClamd(t *testing.T, address string) *Clamd {
+	t.Helper()
+	c, err := NewClamd(address)
+	if err != nil {
+		t.Fatalf("NewClamd(%q): %v", address, err)
+	}
+	c.Timeout = 5 * time.Second
+	return c
+}
+
+// rawServer accepts one connection, hands it to handle and returns what the
+// client sent
+func rawServer(t *testing.T, handle func(conn net.Conn, r *bufio.Reader)) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn, bufio.NewReader(conn))
+	}()
+	return "tcp://" + l.Addr().String()
+}
+
+func TestNewClamd(t *testing.T) {
+	tests := []struct {
+		in      string
+		network string
+		address string
+		wantErr bool
+	}{
+		{in: "tcp://localhost:3310", network: "tcp", address: "localhost:3310"},
+		{in: "localhost:3310", network: "tcp", address: "localhost:3310"},
+		{in: "unix:///var/run/clamav/clamd.ctl", network: "unix", address: "/var/run/clamav/clamd.ctl"},
+		{in: "http://localhost:3310", wantErr: true},
+		{in: "tcp://", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		c, err := NewClamd(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewClamd(%q) succeeded, want error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewClamd(%q): %v", tt.in, err)
+			continue
+		}
+		if c.Network != tt.network || c.Address != tt.address {
+			t.Errorf("NewClamd(%q) = %s %s, want %s %s", tt.in, c.Network, c.Address, tt.network, tt.address)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	fake := startFake(t)
+	if err := newTestClamd(t, fake.Addr()).Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestPingUnix(t *testing.T) {
+	fake, err := ListenFakeClamd("unix", filepath.Join(t.TempDir(), "clamd.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer fake.Close()
+	if err := newTestClamd(t, fake.Addr()).Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	// EICAR placed across the boundary between the first two chunks
+	straddling := bytes.Repeat([]byte("a"), chunkSize-10)
+	straddling = append(straddling, EICAR...)
+	straddling = append(straddling, bytes.Repeat([]byte("b"), chunkSize)...)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		infected  bool
+		signature string
+	}{
+		{name: "empty", content: nil},
+		{name: "clean", content: []byte("hello world")},
+		{name: "clean over several chunks", content: bytes.Repeat([]byte("x"), 3*chunkSize+17)},
+		{name: "eicar", content: []byte(EICAR), infected: true, signature: "Eicar-Test-Signature"},
+		{name: "eicar across chunks", content: straddling, infected: true, signature: "Eicar-Test-Signature"},
+	}
+
+	fake := startFake(t)
+	c := newTestClamd(t, fake.Addr())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := c.Scan(context.Background(), bytes.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if res.Infected != tt.infected || res.Signature != tt.signature {
+				t.Fatalf("Scan = %+v, want infected=%v signature=%q", res, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestScanSizeLimit(t *testing.T) {
+	fake := startFake(t)
+	fake.MaxStreamLength = 1000
+
+	_, err := newTestClamd(t, fake.Addr()).Scan(context.Background(), bytes.NewReader(make([]byte, 5000)))
+	if !errors.Is(err, ErrSizeLimit) {
+		t.Fatalf("Scan = %v, want ErrSizeLimit", err)
+	}
+}
+
+// TestInstreamFraming checks the bytes on the wire: the null-terminated
+// command, length-prefixed chunks of at most chunkSize, and a zero-length
+// terminator
+func TestInstreamFraming(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), (chunkSize*2)/10+5)
+	type frame struct {
+		command string
+		chunks  []int
+		data    []byte
+		err     error
+	}
+	got := make(chan frame, 1)
+
+	addr := rawServer(t, func(conn net.Conn, r *bufio.Reader) {
+		var f frame
+		defer func() { got <- f }()
+
+		command, err := r.ReadString(0)
+		if err != nil {
+			f.err = err
+			return
+		}
+		f.command = command
+		for {
+			var size [4]byte
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				f.err = err
+				return
+			}
+			n := int(binary.BigEndian.Uint32(size[:]))
+			if n == 0 {
+				break
+			}
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				f.err = err
+				return
+			}
+			f.chunks = append(f.chunks, n)
+			f.data = append(f.data, chunk...)
+		}
+		conn.Write([]byte("stream: OK\x00"))
+	})
+
+	res, err := newTestClamd(t, addr).Scan(context.Background(), bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if res.Infected {
+		t.Fatal("clean stream reported infected")
+	}
+
+	f := <-got
+	if f.err != nil {
+		t.Fatalf("server read: %v", f.err)
+	}
+	if f.command != "zINSTREAM\x00" {
+		t.Fatalf("command = %q, want zINSTREAM\\0", f.command)
+	}
+	if len(f.chunks) != 3 {
+		t.Fatalf("got %d chunks %v, want 3", len(f.chunks), f.chunks)
+	}
+	for _, n := range f.chunks {
+		if n > chunkSize {
+			t.Fatalf("chunk of %d bytes exceeds %d", n, chunkSize)
+		}
+	}
+	if !bytes.Equal(f.data, content) {
+		t.Fatal("reassembled stream differs from the input")
+	}
+}
+
+func TestErrorReplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr string
+	}{
+		{name: "unknown command", reply: "UNKNOWN COMMAND\x00", wantErr: "clamd error: UNKNOWN COMMAND"},
+		{name: "generic error", reply: "stream: Can't allocate memory ERROR\x00", wantErr: "clamd error"},
+		{name: "size limit", reply: "INSTREAM size limit exceeded. ERROR\x00", wantErr: ErrSizeLimit.Error()},
+		{name: "connection closed", reply: "", wantErr: "read reply"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := rawServer(t, func(conn net.Conn, r *bufio.Reader) {
+				r.ReadString(0)
+				io.Copy(io.Discard, io.LimitReader(r, 4+int64(len("data"))+4))
+				conn.Write([]byte(tt.reply))
+			})
+
+			res, err := newTestClamd(t, addr).Scan(context.Background(), strings.NewReader("data"))
+			if err == nil {
+				t.Fatalf("Scan = %+v, want error", res)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPingUnexpectedReply(t *testing.T) {
+	addr := rawServer(t, func(conn net.Conn, r *bufio.Reader) {
+		r.ReadString(0)
+		conn.Write([]byte("NOPE\x00"))
+	})
+	if err := newTestClamd(t, addr).Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "unexpected clamd reply") {
+		t.Fatalf("Ping = %v, want unexpected reply error", err)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	addr := rawServer(t, func(conn net.Conn, r *bufio.Reader) {
+		<-release // never replies
+	})
+
+	c := newTestClamd(t, addr)
+	c.Timeout = 100 * time.Millisecond
+	start := time.Now()
+	_, err := c.Scan(context.Background(), strings.NewReader("data"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Scan = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Scan took %v after the timeout", elapsed)
+	}
+}
+
+func TestScanUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if _, err := newTestClamd(t, addr).Scan(context.Background(), strings.NewReader("data")); err == nil || !strings.Contains(err.Error(), "connect to clamd") {
+		t.Fatalf("Scan = %v, want connect error", err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{reply: "stream: OK"},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", infected: true, signature: "Win.Test.EICAR_HDB-1"},
+		{reply: "Eicar-Test-Signature FOUND", infected: true, signature: "Eicar-Test-Signature"},
+		{reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+		{reply: "stream: lstat() failed ERROR", wantErr: true},
+		{reply: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		res, err := parseReply(tt.reply)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseReply(%q) = %+v, want error", tt.reply, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseReply(%q): %v", tt.reply, err)
+			continue
+		}
+		if res.Infected != tt.infected || res.Signature != tt.signature {
+			t.Errorf("parseReply(%q) = %+v, want infected=%v signature=%q", tt.reply, res, tt.infected, tt.signature)
+		}
+	}
+}
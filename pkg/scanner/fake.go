@@ -0,0 +1,149 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// EICAR is the standard antivirus test file. Every scanner, including
+// FakeClamd, reports it as infected.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// FakeClamd is a minimal clamd server for development and tests. It speaks
+// the PING and INSTREAM commands and reports a stream as infected when it
+// contains one of the configured patterns.
+type FakeClamd struct {
+	// Signatures maps a signature name to a byte pattern. It must not be
+	// changed after the server started.
+	Signatures map[string]string
+	// MaxStreamLength mimics StreamMaxLength, default 25 MiB.
+	MaxStreamLength int
+
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// ListenFakeClamd starts a fake clamd on network ("tcp" or "unix") and
+// address. Use "127.0.0.1:0" to pick a free port and Addr to read it back.
+func ListenFakeClamd(network, address string) (*FakeClamd, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	f := &FakeClamd{
+		Signatures:      map[string]string{"Eicar-Test-Signature": EICAR},
+		MaxStreamLength: 25 << 20,
+		listener:        l,
+	}
+	f.wg.Add(1)
+	go f.serve()
+	return f, nil
+}
+
+// Addr returns the address in the form accepted by NewClamd.
+func (f *FakeClamd) Addr() string {
+	addr := f.listener.Addr()
+	return addr.Network() + "://" + addr.String()
+}
+
+func (f *FakeClamd) Close() error {
+	err := f.listener.Close()
+	f.wg.Wait()
+	return err
+}
+
+func (f *FakeClamd) serve() {
+	defer f.wg.Done()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("fake clamd: accept: %v", err)
+			}
+			return
+		}
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			defer conn.Close()
+			f.handle(conn)
+		}()
+	}
+}
+
+func (f *FakeClamd) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	// "z" commands end with a null byte, "n" commands with a newline
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	delim := byte(0)
+	if prefix == 'n' {
+		delim = '\n'
+	} else if prefix != 'z' {
+		r.UnreadByte()
+		delim = '\n'
+	}
+	command, err := r.ReadString(delim)
+	if err != nil {
+		return
+	}
+	reply := func(s string) { conn.Write(append([]byte(s), delim)) }
+
+	switch strings.TrimRight(command, "\x00\n") {
+	case "PING":
+		reply("PONG")
+	case "VERSION":
+		reply("ClamAV 0.0.0/fake")
+	case "INSTREAM":
+		data, err := f.readStream(r)
+		if err != nil {
+			if errors.Is(err, errFakeSizeLimit) {
+				reply("INSTREAM size limit exceeded. ERROR")
+			}
+			return
+		}
+		for name, pattern := range f.Signatures {
+			if bytes.Contains(data, []byte(pattern)) {
+				reply("stream: " + name + " FOUND")
+				return
+			}
+		}
+		reply("stream: OK")
+	default:
+		reply("UNKNOWN COMMAND")
+	}
+}
+
+var errFakeSizeLimit = errors.New("size limit exceeded")
+
+func (f *FakeClamd) readStream(r io.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint32(size[:]))
+		if n == 0 {
+			return data, nil
+		}
+		if len(data)+n > f.MaxStreamLength {
+			return nil, errFakeSizeLimit
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+}
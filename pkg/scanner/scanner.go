@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Result is the verdict for one scanned stream.
+type Result struct {
+	Infected  bool
+	Signature string // name of the matched signature, empty when clean
+}
+
+// Scanner checks content for malware. An error means no verdict could be
+// reached; it never means the content is infected.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// NewFromEnv returns a clamd scanner for CLAMD_ADDRESS, e.g.
+// tcp://localhost:3310 or unix:///var/run/clamav/clamd.ctl. It returns nil
+// when CLAMD_ADDRESS is not set, which disables scanning.
+//
+// CLAMD_TIMEOUT (Go duration, default 2m) bounds a single scan including the
+// upload of the stream to clamd.
+func NewFromEnv() (Scanner, error) {
+	address := os.Getenv("CLAMD_ADDRESS")
+	if address == "" {
+		return nil, nil
+	}
+
+	timeout := defaultTimeout
+	if raw := os.Getenv("CLAMD_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid CLAMD_TIMEOUT %q", raw)
+		}
+		timeout = d
+	}
+
+	clamd, err := NewClamd(address)
+	if err != nil {
+		return nil, err
+	}
+	clamd.Timeout = timeout
+	return clamd, nil
+}
@@ -7,4 +7,23 @@ var (
 	ErrUsernameExists     = errors.New("username already taken")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrAlreadyVerified    = errors.New("email address is already verified")
+	ErrSSOEmailUnverified = errors.New("identity provider did not return a verified email")
+	ErrSSONotProvisioned  = errors.New("no account exists for this email")
+	ErrExportNotReady     = errors.New("export is not ready or has expired")
+	ErrCategoryCycle      = errors.New("cannot move a category under itself or one of its subcategories")
+	ErrBulkAborted        = errors.New("no changes were applied because some items failed")
+	ErrFileNotFound       = errors.New("file not found")
+	ErrInvalidTransform   = errors.New("invalid image transformation")
+	ErrImageTooLarge      = errors.New("image is too large to transform")
+	ErrImageBusy          = errors.New("image service is busy, try again later")
+	ErrFileQuarantined    = errors.New("file is quarantined because malware was found")
+	ErrScanPending        = errors.New("file has not passed the malware scan yet")
+	ErrUploadRejected     = errors.New("upload rejected")
+	ErrFileForbidden      = errors.New("not allowed to change this file")
+	ErrFileAccessDenied   = errors.New("you do not have access to this file")
+	ErrWebhookNotFound    = errors.New("webhook not found")
+	ErrWebhookForbidden   = errors.New("workspace admin role required to manage webhooks")
+	ErrInvalidWebhook     = errors.New("invalid webhook")
 )
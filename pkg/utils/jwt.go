@@ -2,17 +2,16 @@ package utils
 
 import (
 	"fmt"
-	"os"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
 	Username  string `json:"username"`
 	ID        uint   `json:"id"`
 	TokenType string `json:"token_type"`
-	jwt.StandardClaims
+	jwt.RegisteredClaims
 }
 
 type TokenPair struct {
@@ -20,22 +19,33 @@ type TokenPair struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
-func GenerateAccessToken(username string, ID uint) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY")
-	expirationTime := time.Now().Add(time.Minute * 15).Unix()
+func signToken(username string, ID uint, tokenType string, ttl time.Duration) (string, error) {
+	r, err := currentKeyRing()
+	if err != nil {
+		return "", err
+	}
 
+	now := time.Now()
 	claims := &Claims{
 		Username:  username,
 		ID:        ID,
-		TokenType: "access",
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime,
-			IssuedAt:  time.Now().Unix(),
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    r.issuer,
+			Subject:   fmt.Sprint(ID),
+			Audience:  jwt.ClaimStrings{r.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secretKey))
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.kid
+	return token.SignedString(r.active.private)
+}
+
+func GenerateAccessToken(username string, ID uint) (string, error) {
+	signedToken, err := signToken(username, ID, "access", time.Minute*15)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign access token: %w", err)
 	}
@@ -44,21 +54,7 @@ func GenerateAccessToken(username string, ID uint) (string, error) {
 }
 
 func GenerateRefreshToken(username string, ID uint) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY")
-	expirationTime := time.Now().Add(time.Hour * 24 * 7).Unix()
-
-	claims := &Claims{
-		Username:  username,
-		ID:        ID,
-		TokenType: "refresh",
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime,
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secretKey))
+	signedToken, err := signToken(username, ID, "refresh", time.Hour*24*7)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign refresh token: %w", err)
 	}
@@ -84,16 +80,28 @@ func GenerateTokenPair(username string, ID uint) (*TokenPair, error) {
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
-	secretKey := os.Getenv("SECRET_KEY")
+	r, err := currentKeyRing()
+	if err != nil {
+		return nil, err
+	}
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := r.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(secretKey), nil
-	})
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(r.issuer),
+		jwt.WithAudience(r.audience),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -148,21 +156,7 @@ func RefreshAccessToken(refreshToken string) (string, error) {
 }
 
 func GenerateToken(username string, ID uint) (string, error) {
-	secretKey := os.Getenv("SECRET_KEY")
-	expirationTime := time.Now().Add(time.Hour * 24).Unix()
-
-	claims := &Claims{
-		Username:  username,
-		ID:        ID,
-		TokenType: "access",
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime,
-			IssuedAt:  time.Now().Unix(),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(secretKey))
+	signedToken, err := signToken(username, ID, "access", time.Hour*24)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
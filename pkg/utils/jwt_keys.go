@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one entry of the key ring. private is nil for keys that are
+// only kept to verify tokens signed before a rotation.
+type signingKey struct {
+	kid     string
+	private crypto.Signer
+	public  crypto.PublicKey
+	method  jwt.SigningMethod
+}
+
+type keyRing struct {
+	active   *signingKey
+	keys     map[string]*signingKey
+	issuer   string
+	audience string
+}
+
+var (
+	ring     *keyRing
+	ringErr  error
+	ringOnce sync.Once
+)
+
+// LoadJWTKeys loads the key ring from JWT_KEYS_DIR (default ./keys). Every
+// <kid>.pem file in the directory (RSA or Ed25519, private or public) is
+// accepted for verification; JWT_ACTIVE_KID selects the private key used for
+// signing. When the directory has no keys an Ed25519 key is generated.
+//
+// Rotation: add the new key file, point JWT_ACTIVE_KID at it, and remove the
+// old file once tokens signed with it have expired.
+func LoadJWTKeys() error {
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeyRing()
+	})
+	return ringErr
+}
+
+func currentKeyRing() (*keyRing, error) {
+	if err := LoadJWTKeys(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+func loadKeyRing() (*keyRing, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "./keys"
+	}
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "vasvault"
+	}
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		audience = "vasvault"
+	}
+
+	r := &keyRing{keys: make(map[string]*signingKey), issuer: issuer, audience: audience}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := readPEMKey(path, kid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jwt key %s: %w", path, err)
+		}
+		r.keys[kid] = key
+	}
+
+	if len(r.keys) == 0 {
+		key, err := generateEd25519Key(dir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("no JWT keys found in %s, generated new signing key %q", dir, key.kid)
+		r.keys[key.kid] = key
+	}
+
+	activeKid := os.Getenv("JWT_ACTIVE_KID")
+	if activeKid == "" {
+		var signers []string
+		for kid, key := range r.keys {
+			if key.private != nil {
+				signers = append(signers, kid)
+			}
+		}
+		if len(signers) != 1 {
+			return nil, errors.New("JWT_ACTIVE_KID must be set when there is not exactly one private key")
+		}
+		activeKid = signers[0]
+	}
+
+	active, ok := r.keys[activeKid]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("active jwt key %q not found or has no private key", activeKid)
+	}
+	r.active = active
+
+	return r, nil
+}
+
+func readPEMKey(path, kid string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.public, key.method = k, &k.PublicKey, jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.private, key.public, key.method = k, k.Public(), jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		key.public, key.method = k, jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.public, key.method = k, jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+	return key, nil
+}
+
+func generateEd25519Key(dir string) (*signingKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate jwt key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	kid := time.Now().Format("20060102") + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create jwt key dir: %w", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pemBytes, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write jwt key: %w", err)
+	}
+
+	return &signingKey{kid: kid, private: priv, public: pub, method: jwt.SigningMethodEdDSA}, nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns all verification keys so other services can validate
+// tokens issued by vasvault.
+func PublicJWKS() (*JWKSet, error) {
+	r, err := currentKeyRing()
+	if err != nil {
+		return nil, err
+	}
+
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := &JWKSet{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := r.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
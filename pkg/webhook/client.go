@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	// only the start of the response is kept for the delivery log
+	maxResponseBody = 4 << 10
+
+	EventHeader    = "X-Vasvault-Event"
+	DeliveryHeader = "X-Vasvault-Delivery"
+)
+
+// ErrBlockedAddress is returned for URLs that resolve to loopback, private,
+// link-local or other non-public addresses while AllowPrivate is off.
+var ErrBlockedAddress = errors.New("webhook: destination address is not allowed")
+
+// Request is one delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID string
+	Body       []byte
+}
+
+// Response is what the receiver answered. It is also returned together with
+// an error for non-2xx answers, so the status can be logged.
+type Response struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+}
+
+// StatusError is returned when the receiver answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook: receiver returned status %d", e.StatusCode)
+}
+
+// Client sends signed deliveries. Redirects are not followed.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client with the given per-request timeout (10s when 0).
+// Unless allowPrivate is set, connections to non-public addresses are refused
+// after DNS resolution, so a webhook cannot be pointed at internal services.
+func NewClient(timeout time.Duration, allowPrivate bool) *Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Client{http: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send POSTs the body with the event, delivery and signature headers.
+func (c *Client) Send(ctx context.Context, r Request) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "vasvault-webhooks/1.0")
+	req.Header.Set(EventHeader, r.Event)
+	req.Header.Set(DeliveryHeader, r.DeliveryID)
+	req.Header.Set(SignatureHeader, Sign(r.Secret, time.Now(), r.Body))
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	// the rest is drained so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	result := &Response{StatusCode: resp.StatusCode, Body: string(body), Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return result, nil
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL. Hosts given as IP
+// literals are checked against the same rules as the dialer; host names are
+// only checked when the delivery connects.
+func ValidateURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("webhook: invalid url: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("webhook: url must use http or https")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("webhook: url has no host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook: url must not contain credentials")
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("webhook: invalid port %q", port)
+		}
+	}
+	if allowPrivate {
+		return nil
+	}
+	if u.Hostname() == "localhost" {
+		return ErrBlockedAddress
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublic(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// ranges not covered by the net.IP helpers
+var blockedNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),      // "this network"; 0.x.x.x reaches the local host on Linux
+	mustCIDR("100.64.0.0/10"),  // carrier-grade NAT
+	mustCIDR("64:ff9b::/96"),   // NAT64, embeds an IPv4 address that may be private
+	mustCIDR("64:ff9b:1::/48"), // local-use NAT64
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"file.uploaded"}`)
+	now := time.Now()
+	valid := Sign(secret, now, body)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name        string
+		secret      string
+		header      string
+		body        []byte
+		noTolerance bool
+		wantErr     error
+	}{
+		{name: "valid", header: valid},
+		{name: "extra whitespace", header: strings.ReplaceAll(valid, ",", ", ")},
+		{name: "one of several signatures", header: "t=" + ts + ",v1=deadbeef," + valid[strings.Index(valid, "v1="):]},
+		{name: "old signature without tolerance", header: Sign(secret, now.Add(-time.Hour), body), noTolerance: true},
+		{name: "tampered body", header: valid, body: []byte(`{"type":"file.deleted"}`), wantErr: ErrInvalidSignature},
+		{name: "wrong secret", secret: "whsec_other", header: valid, wantErr: ErrInvalidSignature},
+		{name: "timestamp changed", header: strings.Replace(valid, "t="+ts, "t="+strconv.FormatInt(now.Unix()+1, 10), 1), wantErr: ErrInvalidSignature},
+		{name: "too old", header: Sign(secret, now.Add(-10*time.Minute), body), wantErr: ErrExpiredSignature},
+		{name: "too far in the future", header: Sign(secret, now.Add(10*time.Minute), body), wantErr: ErrExpiredSignature},
+		{name: "empty header", header: "", wantErr: ErrInvalidSignature},
+		{name: "missing timestamp", header: valid[strings.Index(valid, "v1="):], wantErr: ErrInvalidSignature},
+		{name: "missing signature", header: "t=" + ts, wantErr: ErrInvalidSignature},
+		{name: "non-numeric timestamp", header: strings.Replace(valid, "t="+ts, "t=yesterday", 1), wantErr: ErrInvalidSignature},
+		{name: "unknown version only", header: "t=" + ts + ",v0=" + valid[strings.Index(valid, "v1=")+3:], wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := secret
+			if tt.secret != "" {
+				s = tt.secret
+			}
+			b := body
+			if tt.body != nil {
+				b = tt.body
+			}
+			tolerance := 5 * time.Minute
+			if tt.noTolerance {
+				tolerance = 0
+			}
+			err := Verify(s, tt.header, b, tolerance)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignFormat(t *testing.T) {
+	got := Sign("secret", time.Unix(1700000000, 0), []byte("body"))
+	// echo -n "1700000000.body" | openssl dgst -sha256 -hmac secret
+	want := "t=1700000000,v1=42ac6f0448c1d9c3e1e82b9726248f58fef84afffcbad5188246e96070e0ea46"
+	if got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret: %v", err)
+	}
+	b, _ := NewSecret()
+	if !strings.HasPrefix(a, "whsec_") || len(a) != len("whsec_")+64 {
+		t.Fatalf("NewSecret = %q, want whsec_ and 64 hex chars", a)
+	}
+	if a == b {
+		t.Fatal("NewSecret returned the same secret twice")
+	}
+}